@@ -59,6 +59,8 @@ type Application struct {
 	historyView     *HistoryView
 	entry           *widgets.QLineEdit
 	queryModeCombo  *widgets.QComboBox
+	dictGroupCombo  *widgets.QComboBox
+	groupedCheck    *widgets.QCheckBox
 	favoritesWidget *qfavorites.FavoritesWidget
 
 	favoriteButton       *FavoriteButton
@@ -149,6 +151,8 @@ func (app *Application) runDictManager() bool {
 }
 
 func (app *Application) resetQuery() {
+	app.queryArgs.runner.Cancel()
+	app.queryArgs.SetDidYouMean("")
 	app.entry.SetText("")
 	app.resultList.Clear()
 	app.headerLabel.SetText("")
@@ -240,8 +244,19 @@ func (app *Application) Run() {
 		"Regex",
 		"Glob",
 		"Word Match",
+		"Definition",
+		"Exact",
+		"Ends with",
+		"Contains",
 	})
 
+	app.dictGroupCombo = widgets.NewQComboBox(nil)
+	app.dictGroupCombo.SetToolTip("Dictionary group")
+	updateDictGroupCombo(app.dictGroupCombo)
+
+	app.groupedCheck = widgets.NewQCheckBox2("Group", nil)
+	app.groupedCheck.SetToolTip("Group results by headword")
+
 	okButton := widgets.NewQPushButton2(" OK ", nil)
 
 	app.queryFavoriteButton = NewFavoriteButton(func(checked bool) {
@@ -297,6 +312,8 @@ func (app *Application) Run() {
 	queryBoxLayout.AddWidget(queryLabel, 0, 0)
 	queryBoxLayout.AddWidget(app.entry, 0, 0)
 	queryBoxLayout.AddWidget(app.queryModeCombo, 0, 0)
+	queryBoxLayout.AddWidget(app.dictGroupCombo, 0, 0)
+	queryBoxLayout.AddWidget(app.groupedCheck, 0, 0)
 	queryBoxLayout.AddWidget(app.queryFavoriteButton, 0, 0)
 	queryBoxLayout.AddWidget(okButton, 0, 0)
 
@@ -340,6 +357,7 @@ func (app *Application) Run() {
 		if err != nil {
 			slog.Error("error in loading frequency table: " + err.Error())
 		}
+		dictmgr.SetLookupFrequency(frequencyTable.Counts)
 	}
 	// TODO: save the width of 2 columns
 
@@ -447,7 +465,13 @@ func (app *Application) Run() {
 
 	leftPanel := widgets.NewQWidget(nil, 0)
 	leftPanelLayout := widgets.NewQVBoxLayout2(leftPanel)
-	leftPanelLayout.AddWidget(widgets.NewQLabel2("Results", nil, 0), 0, 0)
+	resultsLabel := widgets.NewQLabel2(resultsLabelText, nil, 0)
+	leftPanelLayout.AddWidget(resultsLabel, 0, 0)
+	didYouMeanLabel := widgets.NewQLabel(nil, 0)
+	didYouMeanLabel.SetWordWrap(true)
+	didYouMeanLabel.ConnectLinkActivated(app.doQuery)
+	didYouMeanLabel.Hide()
+	leftPanelLayout.AddWidget(didYouMeanLabel, 0, 0)
 	app.resultList = NewResultListWidget(
 		app.articleView,
 		app.headerLabel,
@@ -463,6 +487,13 @@ func (app *Application) Run() {
 		PostQuery:   app.postQuery,
 		Entry:       app.entry,
 		ModeCombo:   app.queryModeCombo,
+
+		DictGroupCombo: app.dictGroupCombo,
+
+		ResultsLabel:    resultsLabel,
+		DidYouMeanLabel: didYouMeanLabel,
+
+		runner: newQueryRunner(),
 	}
 
 	app.headerLabel.doQuery = app.doQuery
@@ -490,6 +521,7 @@ func (app *Application) Run() {
 		queryLabel,
 		app.entry,
 		app.queryModeCombo,
+		app.dictGroupCombo,
 		okButton,
 		app.headerLabel,
 		app.articleView,
@@ -561,6 +593,17 @@ func (app *Application) setupSettings(qs *core.QSettings, mainSplitter *widgets.
 		}
 		go qsettings.SaveSearchSettings(qs, app.queryModeCombo)
 	})
+	app.dictGroupCombo.ConnectCurrentIndexChanged(func(i int) {
+		text := app.entry.Text()
+		if text != "" {
+			onQuery(text, app.queryArgs, false)
+		}
+		go qsettings.SaveSearchDictGroup(qs, comboDictGroup(app.dictGroupCombo))
+	})
+	app.groupedCheck.ConnectToggled(func(checked bool) {
+		app.resultList.SetGrouped(checked)
+		go qsettings.SaveSearchGrouped(qs, app.groupedCheck)
+	})
 
 	qsettings.RestoreSplitterSizes(qs, mainSplitter, QS_mainSplitter)
 	qsettings.RestoreMainWinGeometry(app.QApplication, qs, app.window)
@@ -579,6 +622,10 @@ func (app *Application) setupSettings(qs *core.QSettings, mainSplitter *widgets.
 	qsettings.SetupSplitterSizesSave(qs, mainSplitter, QS_mainSplitter)
 
 	qsettings.RestoreSearchSettings(qs, app.queryModeCombo)
+	qsettings.RestoreSearchGrouped(qs, app.groupedCheck)
+	qsettings.RestoreSearchDictGroup(qs, func(group string) {
+		setComboDictGroup(app.dictGroupCombo, group)
+	})
 }
 
 func (app *Application) setupHandlers() {
@@ -600,11 +647,14 @@ func (app *Application) setupHandlers() {
 	})
 
 	app.reloadDictsButton.ConnectClicked(func(checked bool) {
+		queryArgs.runner.Cancel()
 		qdictmgr.InitDicts(conf, true)
 		app.dictManager = nil
+		updateDictGroupCombo(app.dictGroupCombo)
 		onQuery(entry.Text(), queryArgs, false)
 	})
 	app.closeDictsButton.ConnectClicked(func(checked bool) {
+		queryArgs.runner.Cancel()
 		dictmgr.CloseDicts()
 	})
 	app.openConfigButton.ConnectClicked(func(checked bool) {
@@ -623,13 +673,15 @@ func (app *Application) setupHandlers() {
 		frequencyTable.SaveNoError()
 	})
 	app.randomEntryButton.ConnectClicked(func(checked bool) {
-		res := dictmgr.RandomEntry(conf, resultFlags)
+		res := dictmgr.RandomEntry(conf, queryArgs.DictGroup(), resultFlags)
 		if res == nil {
 			return
 		}
+		queryArgs.runner.Cancel()
+		queryArgs.SetDidYouMean("")
 		query := res.F_Terms[0]
 		entry.SetText(query)
-		queryArgs.ResultList.SetResults([]common.SearchResultIface{res})
+		queryArgs.ResultList.SetResults([]common.SearchResultIface{res}, nil)
 		queryArgs.AddHistoryAndFrequency(query)
 		app.postQuery(query)
 	})
@@ -646,6 +698,7 @@ func (app *Application) setupHandlers() {
 		app.historyView.ClearHistory()
 		frequencyTable.Clear()
 		frequencyTable.SaveNoError()
+		dictmgr.SetLookupFrequency(nil)
 	})
 	app.saveFavoritesButton.ConnectClicked(func(checked bool) {
 		err := app.favoritesWidget.Save()
@@ -2,6 +2,7 @@ package application
 
 import (
 	"log/slog"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"github.com/ilius/ayandict/v2/pkg/dictmgr"
+	"github.com/ilius/ayandict/v2/pkg/headerlib"
 	"github.com/ilius/ayandict/v2/pkg/mp3duration"
 	common "github.com/ilius/go-dict-commons"
 	"github.com/ilius/qt/core"
@@ -170,6 +172,31 @@ func (view *ArticleView) SetResult(res common.SearchResultIface) {
 	}
 }
 
+// SetGroup shows definitions of all results of group, each under its header
+func (view *ArticleView) SetGroup(group *dictmgr.ResultGroup) {
+	view.dictName = group.Results[0].DictName()
+	parts := make([]string, 0, len(group.Results))
+	for _, res := range group.Results {
+		header, err := headerlib.GetHeader(headerTpl, res)
+		if err != nil {
+			slog.Error("error formatting header: " + err.Error())
+		}
+		parts = append(parts, header+"\n<br/>\n"+strings.Join(
+			res.DefinitionsHTML(),
+			"\n<br/>\n",
+		))
+	}
+	text := strings.Join(parts, "\n<hr/>\n")
+	text2 := text
+	if definitionStyleString != "" {
+		text2 = definitionStyleString + text2
+	}
+	view.SetHtml(text2)
+	if conf.Audio && conf.AudioAutoPlay > 0 {
+		go view.autoPlay(text, conf.AudioAutoPlay)
+	}
+}
+
 func (view *ArticleView) createContextMenu() *widgets.QMenu {
 	menu := widgets.NewQMenu(view.QTextBrowser)
 	menu.AddAction("Query").ConnectTriggered(func(checked bool) {
@@ -297,6 +324,16 @@ func (view *ArticleView) setupAnchorClicked() {
 			}
 			return
 		}
+		if qUrl.Scheme() == queryScheme {
+			// see queryLink
+			query, err := url.QueryUnescape(qUrl.Path(core.QUrl__FullyEncoded))
+			if err != nil {
+				slog.Error("bad query link", "err", err, "url", qUrl.ToString(core.QUrl__None))
+				return
+			}
+			view.doQuery(query)
+			return
+		}
 		path := qUrl.Path(core.QUrl__FullyDecoded)
 		switch qUrl.Scheme() {
 		case "":
@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/ilius/ayandict/v2/pkg/config"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr"
 	"github.com/ilius/ayandict/v2/pkg/dictmgr/qdictmgr"
 	"github.com/ilius/ayandict/v2/pkg/headerlib"
 	"github.com/ilius/qt/core"
@@ -40,6 +41,7 @@ func LoadConfig() bool {
 		return false
 	}
 	conf = newConf
+	dictmgr.ClearQueryCache()
 
 	{
 		err := readArticleStyle(conf.ArticleStyle)
@@ -97,8 +99,10 @@ func (app *Application) ReloadConfig() {
 		app.ReloadUserStyle()
 	}
 	if shouldReloadDicts(currentDirList, conf.DirectoryList) {
+		app.queryArgs.runner.Cancel()
 		qdictmgr.InitDicts(conf, true)
 		app.dictManager = nil
+		updateDictGroupCombo(app.dictGroupCombo)
 	}
 	app.headerLabel.ReloadConfig()
 	audioCache.ReloadConfig()
@@ -0,0 +1,43 @@
+package application
+
+import (
+	"github.com/ilius/ayandict/v2/pkg/dictmgr"
+	"github.com/ilius/qt/core"
+	"github.com/ilius/qt/widgets"
+)
+
+// first item of dictionary group combo, to search all dictionaries
+const allDictsLabel = "All Dictionaries"
+
+// comboDictGroup returns name of selected dictionary group,
+// or empty string if all dictionaries are selected
+func comboDictGroup(combo *widgets.QComboBox) string {
+	if combo.CurrentIndex() < 1 {
+		return ""
+	}
+	return combo.CurrentText()
+}
+
+func setComboDictGroup(combo *widgets.QComboBox, group string) {
+	index := 0
+	if group != "" {
+		index = max(combo.FindText(
+			group,
+			core.Qt__MatchExactly|core.Qt__MatchCaseSensitive,
+		), 0)
+	}
+	combo.SetCurrentIndex(index)
+}
+
+// updateDictGroupCombo fills combo with dictionary groups, and keeps
+// the selected group if it still exists. Combo is hidden if there is no group
+func updateDictGroupCombo(combo *widgets.QComboBox) {
+	group := comboDictGroup(combo)
+	names := dictmgr.DictGroupNames()
+	combo.BlockSignals(true)
+	combo.Clear()
+	combo.AddItems(append([]string{allDictsLabel}, names...))
+	setComboDictGroup(combo, group)
+	combo.BlockSignals(false)
+	combo.SetVisible(len(names) > 0)
+}
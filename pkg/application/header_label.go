@@ -1,9 +1,12 @@
 package application
 
 import (
+	"fmt"
+	"html"
 	"log/slog"
 	"strings"
 
+	"github.com/ilius/ayandict/v2/pkg/dictmgr"
 	"github.com/ilius/ayandict/v2/pkg/headerlib"
 	common "github.com/ilius/go-dict-commons"
 	"github.com/ilius/qt/core"
@@ -68,6 +71,19 @@ func (label *HeaderLabel) SetResult(res common.SearchResultIface) {
 	label.SetText(header)
 }
 
+// SetGroup shows headword of group, headers of each result
+// are shown in article
+func (label *HeaderLabel) SetGroup(group *dictmgr.ResultGroup) {
+	label.result = group.Results[0]
+	label.SetText(fmt.Sprintf(
+		"<div dir=\"ltr\" style=\"font-size: xx-large;font-weight:bold;\">%s</div>"+
+			"<font color='#777'>%d results from %d dictionaries</font>",
+		html.EscapeString(group.Term),
+		len(group.Results),
+		len(group.DictNames()),
+	))
+}
+
 func (label *HeaderLabel) addQueryAction(menu *widgets.QMenu, term string) {
 	menu.AddAction("Query: " + term).ConnectTriggered(func(checked bool) {
 		res := label.result
@@ -1,8 +1,12 @@
 package application
 
 import (
+	"context"
 	"fmt"
+	"html"
 	"log/slog"
+	"net/url"
+	"slices"
 	"strings"
 	"time"
 
@@ -17,6 +21,11 @@ const resultFlags = uint32(
 		common.ResultFlag_FixWordLink |
 		common.ResultFlag_ColorMapping)
 
+const resultsLabelText = "Results"
+
+// queryScheme is the URL scheme of links that run a query
+const queryScheme = "query"
+
 type QueryArgs struct {
 	ArticleView *ArticleView
 	ResultList  *ResultListWidget
@@ -25,6 +34,19 @@ type QueryArgs struct {
 	PostQuery   func(string)
 	Entry       *widgets.QLineEdit
 	ModeCombo   *widgets.QComboBox
+
+	DictGroupCombo *widgets.QComboBox
+
+	ResultsLabel    *widgets.QLabel
+	DidYouMeanLabel *widgets.QLabel
+
+	runner *queryRunner
+}
+
+// DictGroup returns name of selected dictionary group,
+// or empty string for all dictionaries
+func (w *QueryArgs) DictGroup() string {
+	return comboDictGroup(w.DictGroupCombo)
 }
 
 func (w *QueryArgs) AddHistoryAndFrequency(query string) {
@@ -33,18 +55,82 @@ func (w *QueryArgs) AddHistoryAndFrequency(query string) {
 	}
 	if !conf.MostFrequentDisable {
 		frequencyTable.Add(query, 1)
+		dictmgr.AddLookupFrequency(query, 1)
 		if conf.MostFrequentAutoSave {
 			frequencyTable.SaveNoError()
 		}
 	}
 }
 
-func (w *QueryArgs) SetNoResult(query string) {
-	w.ArticleView.SetHtml(fmt.Sprintf("No results for %#v", query))
+// queryLink returns href of a link that runs the given query when
+// clicked in article view, see ArticleView.setupAnchorClicked
+func queryLink(query string) string {
+	return queryScheme + ":" + url.QueryEscape(query)
+}
+
+// SetNoResult shows that query has no results, with the reason
+// (notice) if known, and suggestions if any
+func (w *QueryArgs) SetNoResult(query string, suggestions []string, notice string) {
+	text := html.EscapeString(fmt.Sprintf("No results for %#v", query))
+	if notice != "" {
+		text += "<br/><br/>" + html.EscapeString(notice)
+	}
+	if len(suggestions) > 0 {
+		links := make([]string, len(suggestions))
+		for i, suggestion := range suggestions {
+			links[i] = fmt.Sprintf(
+				"<a href=\"%s\">%s</a>",
+				queryLink(suggestion),
+				html.EscapeString(suggestion),
+			)
+		}
+		text += "<br/><br/>Did you mean: " + strings.Join(links, ", ")
+	}
+	w.ArticleView.SetHtml(text)
 	w.HeaderLabel.SetText("")
 	w.AddHistoryAndFrequency(query)
 }
 
+// SetResultsStatus shows detected query direction (if any), whether
+// or not results are partial because some dictionaries did not finish
+// searching in time, and notice of lookup (if any)
+func (w *QueryArgs) SetResultsStatus(timedOut []string, direction string, notice string) {
+	text := resultsLabelText
+	toolTip := []string{}
+	if direction != "" {
+		text += " [" + direction + "]"
+		toolTip = append(toolTip, "Detected query direction: "+direction)
+	}
+	if len(timedOut) > 0 {
+		text += " (partial)"
+		toolTip = append(
+			toolTip,
+			"Search timed out on these dictionaries:\n"+strings.Join(timedOut, "\n"),
+		)
+	}
+	if notice != "" {
+		text += " (!)"
+		toolTip = append(toolTip, notice)
+	}
+	w.ResultsLabel.SetText(text)
+	w.ResultsLabel.SetToolTip(strings.Join(toolTip, "\n\n"))
+}
+
+// SetDidYouMean shows a link for running the given query instead
+// or hides it if query is empty
+func (w *QueryArgs) SetDidYouMean(query string) {
+	if query == "" {
+		w.DidYouMeanLabel.Hide()
+		return
+	}
+	w.DidYouMeanLabel.SetText(fmt.Sprintf(
+		"Did you mean <a href=\"%s\">%s</a>?",
+		html.EscapeString(query),
+		html.EscapeString(query),
+	))
+	w.DidYouMeanLabel.Show()
+}
+
 func NewResultListWidget(
 	articleView *ArticleView,
 	headerLabel *HeaderLabel,
@@ -78,6 +164,13 @@ type ResultListWidget struct {
 
 	results []common.SearchResultIface
 
+	// dictOrder is the order of dictionaries used by lookup of results
+	dictOrder map[string]int
+
+	// grouped: show one item for each headword, see dictmgr.GroupResults
+	grouped bool
+	groups  []*dictmgr.ResultGroup
+
 	Active common.SearchResultIface
 
 	HeaderLabel *HeaderLabel
@@ -86,35 +179,93 @@ type ResultListWidget struct {
 	onResultDisplay func(terms []string)
 }
 
-func (w *ResultListWidget) SetResults(results []common.SearchResultIface) {
-	w.QListWidget.Clear()
-	w.results = results
-	for _, res := range results {
-		terms := res.Terms()
-		var text string
-		switch len(terms) {
-		case 0:
-			text = ""
-			slog.Error("empty terms", "res", res)
-		case 1:
-			text = terms[0]
-		case 2:
-			text = strings.Join(terms, ", ")
-		default:
-			text += fmt.Sprintf("%s (+%d)", terms[0], len(terms)-1)
+func resultItemText(res common.SearchResultIface) string {
+	terms := res.Terms()
+	var text string
+	switch len(terms) {
+	case 0:
+		text = ""
+		slog.Error("empty terms", "res", res)
+	case 1:
+		text = terms[0]
+	case 2:
+		text = strings.Join(terms, ", ")
+	default:
+		text += fmt.Sprintf("%s (+%d)", terms[0], len(terms)-1)
+	}
+	symbols := []string{}
+	for _, dictName := range append([]string{res.DictName()}, dictmgr.ResultAlsoIn(res)...) {
+		symbol := dictmgr.DictSymbol(dictName)
+		if symbol != "" {
+			symbols = append(symbols, symbol)
 		}
-		symbol := dictmgr.DictSymbol(res.DictName())
+	}
+	if len(symbols) > 0 {
+		text = fmt.Sprintf("%s %s", text, strings.Join(symbols, " "))
+	}
+	return text
+}
+
+func groupItemText(group *dictmgr.ResultGroup) string {
+	symbols := []string{}
+	for _, dictName := range group.DictNames() {
+		symbol := dictmgr.DictSymbol(dictName)
 		if symbol != "" {
-			text = fmt.Sprintf("%s %s", text, symbol)
+			symbols = append(symbols, symbol)
 		}
-		w.AddItem(text)
 	}
-	if len(results) > 0 {
+	if len(symbols) == 0 {
+		return fmt.Sprintf("%s (%d)", group.Term, len(group.Results))
+	}
+	return fmt.Sprintf("%s %s", group.Term, strings.Join(symbols, " "))
+}
+
+// SetResults shows results, dictOrder is the order of dictionaries
+// used by lookup, see dictmgr.LookupResult
+func (w *ResultListWidget) SetResults(
+	results []common.SearchResultIface,
+	dictOrder map[string]int,
+) {
+	w.results = results
+	w.dictOrder = dictOrder
+	w.update()
+}
+
+// SetGrouped switches between showing one item for each result,
+// and one item for each headword
+func (w *ResultListWidget) SetGrouped(grouped bool) {
+	if grouped == w.grouped {
+		return
+	}
+	w.grouped = grouped
+	if len(w.results) > 0 {
+		w.update()
+	}
+}
+
+func (w *ResultListWidget) update() {
+	w.QListWidget.Clear()
+	w.groups = nil
+	if w.grouped {
+		w.groups = dictmgr.GroupResults(w.results, w.dictOrder, conf)
+		for _, group := range w.groups {
+			w.AddItem(groupItemText(group))
+		}
+	} else {
+		for _, res := range w.results {
+			w.AddItem(resultItemText(res))
+		}
+	}
+	if len(w.results) > 0 {
 		w.SetCurrentRow(0)
 	}
 }
 
 func (w *ResultListWidget) OnActivate(row int) {
+	if w.grouped {
+		w.onActivateGroup(row)
+		return
+	}
 	if row >= len(w.results) {
 		slog.Error("ResultListWidget: OnActivate: row index out of range", "row", row)
 		return
@@ -132,9 +283,32 @@ func (w *ResultListWidget) OnActivate(row int) {
 	w.Active = res
 }
 
+func (w *ResultListWidget) onActivateGroup(row int) {
+	if row >= len(w.groups) {
+		slog.Error("ResultListWidget: OnActivate: row index out of range", "row", row)
+		return
+	}
+	group := w.groups[row]
+	res := group.Results[0]
+	w.HeaderLabel.SetGroup(group)
+	w.ArticleView.SetGroup(group)
+	resDirs := []string{}
+	for _, res := range group.Results {
+		resDir := res.ResourceDir()
+		if resDir != "" && !slices.Contains(resDirs, resDir) {
+			resDirs = append(resDirs, resDir)
+		}
+	}
+	w.ArticleView.SetSearchPaths(resDirs)
+	w.onResultDisplay(res.Terms())
+	w.Active = res
+}
+
 func (w *ResultListWidget) Clear() {
 	w.QListWidget.Clear()
 	w.results = nil
+	w.dictOrder = nil
+	w.groups = nil
 }
 
 func onQuery(
@@ -143,13 +317,13 @@ func onQuery(
 	isAuto bool,
 ) {
 	if query == "" {
+		queryArgs.runner.Cancel()
 		if !isAuto {
 			queryArgs.ArticleView.SetHtml("")
 			queryArgs.HeaderLabel.SetText("")
 		}
 		return
 	}
-	t := time.Now()
 	mode := dictmgr.QueryModeFuzzy
 	switch queryArgs.ModeCombo.CurrentIndex() {
 	case 1:
@@ -160,13 +334,49 @@ func onQuery(
 		mode = dictmgr.QueryModeGlob
 	case 4: // WordMatch
 		mode = dictmgr.QueryModeWordMatch
+	case 5:
+		mode = dictmgr.QueryModeDefinition
+	case 6:
+		mode = dictmgr.QueryModeExact
+	case 7:
+		mode = dictmgr.QueryModeEndsWith
+	case 8:
+		mode = dictmgr.QueryModeContains
 	}
-	results := dictmgr.LookupHTML(query, conf, mode, resultFlags, 0)
-	slog.Debug("LookupHTML running time", "dt", time.Since(t), "query", query)
-	queryArgs.ResultList.SetResults(results)
+	plan := dictmgr.ParseQuery(query, mode, conf.DetectQueryLang)
+	plan.CollapseDuplicates = conf.CollapseDuplicates
+	group := queryArgs.DictGroup()
+	queryArgs.runner.Run(func(ctx context.Context) func() {
+		t := time.Now()
+		lookupResult, err := dictmgr.LookupHTMLContext(ctx, plan, conf, group, resultFlags, 0)
+		if err != nil {
+			slog.Debug("LookupHTML cancelled", "dt", time.Since(t), "query", query)
+			return nil
+		}
+		slog.Debug("LookupHTML running time", "dt", time.Since(t), "query", query)
+		return func() {
+			onQueryResult(query, queryArgs, isAuto, lookupResult)
+		}
+	})
+}
+
+func onQueryResult(
+	query string,
+	queryArgs *QueryArgs,
+	isAuto bool,
+	lookupResult *dictmgr.LookupResult,
+) {
+	results := lookupResult.Results
+	queryArgs.ResultList.SetResults(results, lookupResult.DictOrder)
+	queryArgs.SetResultsStatus(
+		lookupResult.TimedOut,
+		lookupResult.Direction,
+		lookupResult.Notice,
+	)
+	queryArgs.SetDidYouMean(lookupResult.RemappedQuery)
 	if len(results) == 0 {
 		if !isAuto {
-			queryArgs.SetNoResult(query)
+			queryArgs.SetNoResult(query, lookupResult.Suggestions, lookupResult.Notice)
 		}
 	}
 	if isAuto {
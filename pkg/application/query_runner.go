@@ -0,0 +1,81 @@
+package application
+
+import (
+	"context"
+
+	"github.com/ilius/qt/core"
+)
+
+// interval (in milliseconds) for checking if the running query is finished
+const queryRunnerPollInterval = 10
+
+type queryDone struct {
+	ctx   context.Context
+	apply func()
+}
+
+// queryRunner runs lookups in background, so that GUI is not blocked,
+// and cancels the running lookup when a new one is started
+// (for example with search-on-type)
+// apply functions are called in main (GUI) thread
+type queryRunner struct {
+	timer  *core.QTimer
+	cancel context.CancelFunc
+	done   chan *queryDone
+}
+
+// newQueryRunner must be called in main (GUI) thread
+func newQueryRunner() *queryRunner {
+	r := &queryRunner{
+		timer: core.NewQTimer(nil),
+		done:  make(chan *queryDone),
+	}
+	r.timer.SetInterval(queryRunnerPollInterval)
+	r.timer.ConnectTimeout(r.poll)
+	return r
+}
+
+func (r *queryRunner) poll() {
+	select {
+	case qd := <-r.done:
+		if qd.ctx.Err() != nil {
+			// superseded by another query
+			return
+		}
+		r.timer.Stop()
+		r.cancel()
+		r.cancel = nil
+		qd.apply()
+	default:
+	}
+}
+
+// Cancel cancels the running lookup (if any)
+func (r *queryRunner) Cancel() {
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	r.cancel = nil
+	r.timer.Stop()
+}
+
+// Run cancels the running lookup (if any), and calls run in background.
+// The function returned by run is called in main thread, unless
+// the lookup is cancelled before it is finished
+func (r *queryRunner) Run(run func(ctx context.Context) func()) {
+	r.Cancel()
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	go func() {
+		apply := run(ctx)
+		if apply == nil {
+			return
+		}
+		select {
+		case r.done <- &queryDone{ctx: ctx, apply: apply}:
+		case <-ctx.Done():
+		}
+	}()
+	r.timer.Start2()
+}
@@ -32,6 +32,8 @@ type Config struct {
 
 	DirectoryList []string `toml:"directory_list" doc:"List of dictionary directory paths (absolute or relative to home)"`
 
+	SqlDictList []string `toml:"sql_dict_list" doc:"List of SQLite dictionary file paths (absolute or relative to home), in addition to ‘.sqlite‘ and ‘.sqlite3‘ files found in ‘directory_list‘"`
+
 	Style string `toml:"style" doc:"Path to application stylesheet file (.qss)"`
 
 	ArticleStyle string `toml:"article_style" doc:"Path to article stylesheet file (.css)"`
@@ -98,10 +100,40 @@ type Config struct {
 	SearchWorkerCount int `toml:"search_worker_count" doc:"The number of workers / goroutines used for search"`
 
 	SearchTimeout time.Duration `toml:"search_timeout" doc:"Timeout for search on each dictionary. Only works if ‘search_worker_count > 1‘"`
+
+	SearchDictWorkerCount int `toml:"search_dict_worker_count" doc:"The number of dictionaries that are searched in parallel"`
+
+	SearchTotalTimeout time.Duration `toml:"search_total_timeout" doc:"Timeout for search on all dictionaries. Partial results are shown after this timeout. Set ‘0‘ to disable"`
+
+	DefinitionIndex bool `toml:"definition_index" doc:"Build full-text index of definitions (in background, cached on disk) for ‘Definition‘ query mode, which has no results without it"`
+
+	HeadwordIndex bool `toml:"headword_index" doc:"Build trigram index of headwords (in background, cached on disk) to speed up Fuzzy, Glob and Regex search on large dictionaries"`
+
+	LemmaFallback bool     `toml:"lemma_fallback" doc:"If there is no exact match for query, also look up its base forms (lemmas), for example ‘run‘ for ‘running‘"`
+	LemmaDir      string   `toml:"lemma_dir" doc:"Directory of Hunspell affix rules (‘.aff‘ files, with optional ‘.dic‘ word list of same name) for lemma fallback (absolute or relative to config directory)"`
+	LemmaBuiltin  []string `toml:"lemma_builtin" doc:"Built-in stemmers for lemma fallback, supported languages: ‘en‘, ‘fa‘"`
+
+	Normalization []string `toml:"normalization" doc:"Normalization steps applied to query and headwords for Fuzzy, StartWith and WordMatch search: ‘nfkc‘, ‘case‘, ‘diacritics‘, ‘arabic‘ (Persian/Arabic letter folding), ‘zwnj‘. Can be overridden per dictionary"`
+
+	KeyboardLayouts   []string `toml:"keyboard_layouts" doc:"Keyboard layouts used to retry a query with no (or poor) results, in case it was typed with wrong layout. Built-in layouts: ‘fa‘, ‘ar‘, ‘ru‘, ‘de‘"`
+	KeyboardLayoutDir string   `toml:"keyboard_layout_dir" doc:"Directory of custom keyboard layouts (absolute or relative to config directory). Layout ‘NAME‘ is loaded from ‘NAME.txt‘ if exists, each line has a key of US layout and its character in that layout, separated by space"`
+
+	Suggestions           int `toml:"suggestions" doc:"Number of ‘Did you mean‘ suggestions (closest headwords) shown when there is no result, set ‘0‘ to disable"`
+	SuggestionMaxDistance int `toml:"suggestion_max_distance" doc:"Maximum edit distance between query and suggested headwords"`
+
+	ScoringModel string `toml:"scoring_model" doc:"Ordering of results: ‘legacy‘ (by score, then dictionary order) or ‘weighted‘ (score multiplied by dictionary weight, with small bonus for headword length and lookup frequency)"`
+
+	CollapseDuplicates bool `toml:"collapse_duplicates" doc:"Show results with the same headword and (nearly) identical definition from different dictionaries as one result, listing all of those dictionaries in header"`
+
+	DetectQueryLang bool `toml:"detect_query_lang" doc:"Detect script of query, and skip dictionaries whose source language is written in another script. Can be changed per query with ‘lang:auto‘ and ‘lang:any‘"`
+
+	QueryCacheSize int `toml:"query_cache_size" doc:"Number of recent lookups whose results are kept in memory, to show them again without searching. Set ‘0‘ to disable"`
 }
 
 const defaultHeaderTemplate = `<b><font color='#55f'>{{.DictName}}</font></b>
 <font color='#777'> [Score: %{{.Score}}]</font>
+{{if .Via}}<font color='#777'> [via {{.Via}}]</font>{{end}}
+{{if .AlsoIn}}<font color='#777'> [also in: {{join .AlsoIn ", "}}]</font>{{end}}
 {{if .ShowTerms }}
 <div dir="ltr" style="font-size: xx-large;font-weight:bold;">
 {{ index .Terms 0 }}
@@ -124,6 +156,8 @@ func Default() *Config {
 			".stardict/dic",
 		},
 
+		SqlDictList: []string{},
+
 		Style: "",
 
 		ArticleStyle: "",
@@ -192,6 +226,34 @@ func Default() *Config {
 		SearchWorkerCount: 8,
 
 		SearchTimeout: 5 * time.Second,
+
+		SearchDictWorkerCount: 4,
+
+		SearchTotalTimeout: 8 * time.Second,
+
+		DefinitionIndex: false,
+
+		HeadwordIndex: false,
+
+		LemmaFallback: false,
+		LemmaDir:      "lemma",
+		LemmaBuiltin:  []string{"en", "fa"},
+
+		Normalization: []string{},
+
+		KeyboardLayouts:   []string{},
+		KeyboardLayoutDir: "keyboard",
+
+		Suggestions:           5,
+		SuggestionMaxDistance: 2,
+
+		ScoringModel: "legacy",
+
+		CollapseDuplicates: true,
+
+		DetectQueryLang: false,
+
+		QueryCacheSize: 100,
 	}
 }
 
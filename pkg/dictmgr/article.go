@@ -5,9 +5,11 @@ import (
 	"crypto/sha1"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	std_html "html"
 	"io"
+	"io/fs"
 	"log/slog"
 	"net/http"
 	"net/url"
@@ -19,6 +21,7 @@ import (
 	"sync"
 
 	"github.com/ilius/ayandict/v2/pkg/config"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dictfiles"
 	"github.com/ilius/ayandict/v2/pkg/html"
 	common "github.com/ilius/go-dict-commons"
 )
@@ -31,7 +34,8 @@ var (
 	hrefSoundRE  = regexp.MustCompile(` href="sound://[^<>"]*?"`)
 	audioRE      = regexp.MustCompile(`<audio[ >].*?</audio>`)
 
-	linkRE = regexp.MustCompile(`<link [^<>]+>`)
+	linkRE     = regexp.MustCompile(`<link [^<>]+>`)
+	linkHrefRE = regexp.MustCompile(` href="[^<>"]*?"`)
 
 	colorRE      = regexp.MustCompile(` color=["']#?[a-zA-Z0-9]+["']`)
 	styleColorRE = regexp.MustCompile(`color:#?[a-zA-Z0-9]+`)
@@ -78,9 +82,56 @@ func (p *DictProcessor) dictResLocalURL(relPath string) string {
 		values.Add("path", relPath)
 		return DictResPathBase + "?" + values.Encode()
 	}
+	if reader := p.resourceReader(); reader != nil {
+		return p.extractedResURL(reader, relPath)
+	}
 	return p.ResourceURL() + "/" + relPath
 }
 
+// resourceReader returns the dictionary as ResourceReader, if it keeps
+// resources in an archive instead of ResourceDir
+func (p *DictProcessor) resourceReader() ResourceReader {
+	if p.ResourceDir() != "" {
+		return nil
+	}
+	reader, _ := p.Dictionary.(ResourceReader)
+	return reader
+}
+
+func (p *DictProcessor) hasResource() bool {
+	return p.ResourceDir() != "" || p.resourceReader() != nil
+}
+
+// extractedResURL extracts a resource from archive to cache directory
+// (unless it's already extracted) and returns its file:// URL
+func (p *DictProcessor) extractedResURL(reader ResourceReader, relPath string) string {
+	resDir := dictfiles.ResourceDir("extracted", p.DictName())
+	fpath := filepath.Join(resDir, filepath.FromSlash(relPath))
+	if _, err := os.Stat(fpath); err != nil {
+		data, err := reader.ReadResource(relPath)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				slog.Error("error reading resource", "err", err, "path", relPath)
+			}
+			return relPath
+		}
+		ok, err := dictfiles.WriteResource(resDir, relPath, data)
+		if err != nil || !ok {
+			slog.Error("error extracting resource", "err", err, "path", relPath)
+			return relPath
+		}
+	}
+	return dictfiles.FileURL(resDir) + "/" + relPath
+}
+
+// readResource reads a resource from ResourceDir or archive
+func (p *DictProcessor) readResource(relPath string) ([]byte, error) {
+	if reader := p.resourceReader(); reader != nil {
+		return reader.ReadResource(relPath)
+	}
+	return os.ReadFile(filepath.Join(p.ResourceDir(), relPath))
+}
+
 func (p *DictProcessor) unquoteValue(quoted string) (bool, string) {
 	// FIXME: fails to unquote single-quoted string
 	if quoted[0] == singleQuote {
@@ -371,7 +422,6 @@ func (p *DictProcessor) hrefBwordSub(match string) string {
 
 func (p *DictProcessor) embedExternalStyle(defi string) string {
 	const pre = len(` href=`)
-	resDir := p.ResourceDir()
 
 	subFunc := func(match string) string {
 		i := strings.Index(match, ` href=`)
@@ -389,9 +439,9 @@ func (p *DictProcessor) embedExternalStyle(defi string) string {
 			// TODO: download?
 			return match
 		}
-		data, err := os.ReadFile(filepath.Join(resDir, href))
+		data, err := p.readResource(href)
 		if err != nil {
-			if !os.IsNotExist(err) {
+			if !errors.Is(err, fs.ErrNotExist) {
 				slog.Error("external style file not found", "err", err)
 			}
 			return match
@@ -403,6 +453,21 @@ func (p *DictProcessor) embedExternalStyle(defi string) string {
 	return defi
 }
 
+// fixLinkHref fixes href of <link> tags (stylesheets) that are not
+// embedded, like src of images
+func (p *DictProcessor) fixLinkHref(defi string) string {
+	hrefSub := func(match string) string {
+		ok, urlStr := p.fixResURL(match[6:])
+		if !ok {
+			return match
+		}
+		return " href=" + strconv.Quote(urlStr)
+	}
+	return linkRE.ReplaceAllStringFunc(defi, func(match string) string {
+		return linkHrefRE.ReplaceAllStringFunc(match, hrefSub)
+	})
+}
+
 func (p *DictProcessor) applyColorMapping(defi string) string {
 	colorMapping := p.conf.ColorMapping
 	if len(colorMapping) == 0 {
@@ -488,7 +553,7 @@ func (p *DictProcessor) FixDefiHTML(defi string) string {
 	conf := p.conf
 	flags := p.flags
 	var playImage string
-	hasResource := p.ResourceDir() != ""
+	hasResource := p.hasResource()
 	_fixAudio := conf.Audio && flags&common.ResultFlag_FixAudio > 0
 	if _fixAudio {
 		playImage = p.getPlayImage()
@@ -506,6 +571,9 @@ func (p *DictProcessor) FixDefiHTML(defi string) string {
 	if conf.EmbedExternalStylesheet {
 		defi = p.embedExternalStyle(defi)
 	}
+	if hasResource && flags&common.ResultFlag_FixFileSrc > 0 {
+		defi = p.fixLinkHref(defi)
+	}
 	if flags&common.ResultFlag_FixWordLink > 0 {
 		defi = hrefBwordRE.ReplaceAllStringFunc(defi, p.hrefBwordSub)
 	}
@@ -0,0 +1,81 @@
+package dictmgr
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/dictmgrtest"
+	common "github.com/ilius/go-dict-commons"
+	"github.com/ilius/is/v2"
+)
+
+func TestFixDefiHTML(t *testing.T) {
+	is := is.New(t)
+	conf := dictmgrtest.Config()
+	dic := dictmgrtest.New("test")
+	defi := `see <a href="bword://fl&#x205;k">flȅk</a>`
+	{
+		proc := NewDictProcessor(dic, conf, 0)
+		is.Equal(proc.FixDefiHTML(defi), defi)
+	}
+	{
+		proc := NewDictProcessor(dic, conf, common.ResultFlag_FixWordLink)
+		is.Equal(proc.FixDefiHTML(defi), `see <a href="flȅk">flȅk</a>`)
+	}
+}
+
+func TestDefinitionsHTMLXDXF(t *testing.T) {
+	is := is.New(t)
+	conf := dictmgrtest.Config()
+	dic := dictmgrtest.New("test", &dictmgrtest.Entry{
+		Terms: []string{"cat"},
+		Items: []*common.SearchResultItem{
+			{Type: 'x', Data: []byte("<k>cat</k> <tr>kæt</tr>\n<dtrn>chat</dtrn>, see <kref>kitten</kref>")},
+			{Type: 'm', Data: []byte("a <pet>")},
+		},
+	})
+	res := NewSearchResult(dic.EntryByIndex(0), dic, conf, 0)
+	is.Equal(res.DefinitionsHTML(), []string{
+		`<b>cat</b> <span class="transcription">[kæt]</span><br/>` + "\n" +
+			`<span class="dtrn">chat</span>, see <a href="bword://kitten">kitten</a><br/>` + "\n",
+		"<pre>a &lt;pet&gt;</pre>\n<br/>\n",
+	})
+}
+
+// resourceDict is a dictionary that keeps resources in memory
+type resourceDict struct {
+	*dictmgrtest.Dictionary
+	resources map[string]string
+}
+
+func (d *resourceDict) ReadResource(relPath string) ([]byte, error) {
+	data, ok := d.resources[relPath]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return []byte(data), nil
+}
+
+func TestFixDefiHTMLResourceReader(t *testing.T) {
+	is := is.New(t)
+	conf := dictmgrtest.Config()
+	dic := &resourceDict{
+		Dictionary: dictmgrtest.New("test"),
+		resources: map[string]string{
+			"~/css/style.css": "b { color: red; }",
+		},
+	}
+	defi := `<link rel="stylesheet" href="~/css/style.css"/><img src="~/images/cat.png"/>`
+	proc := NewDictProcessor(dic, conf, common.ResultFlag_Web|common.ResultFlag_FixFileSrc)
+	is.Equal(
+		proc.FixDefiHTML(defi),
+		`<link rel="stylesheet" href="/dict-res/?dictName=test&path=~%2Fcss%2Fstyle.css"/>`+
+			`<img src="/dict-res/?dictName=test&path=~%2Fimages%2Fcat.png"/>`,
+	)
+	conf.EmbedExternalStylesheet = true
+	is.Equal(
+		proc.FixDefiHTML(defi),
+		"<style>\nb { color: red; }\n</style>"+
+			`<img src="/dict-res/?dictName=test&path=~%2Fimages%2Fcat.png"/>`,
+	)
+}
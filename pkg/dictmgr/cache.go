@@ -0,0 +1,141 @@
+package dictmgr
+
+import (
+	"container/list"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dicts"
+	common "github.com/ilius/go-dict-commons"
+)
+
+// queryCache is a LRU cache of lookup results, keyed by query plan,
+// result flags, limit and the list of searched dictionaries.
+// It is dropped when dictionaries are loaded or reordered, or their
+// settings are changed or saved (see dicts.Generation), and on config
+// reload (see ClearQueryCache)
+type queryCache struct {
+	mu sync.Mutex
+
+	list  *list.List // of *queryCacheEntry, most recently used first
+	items map[string]*list.Element
+
+	generation uint64
+
+	hits   uint64
+	misses uint64
+}
+
+type queryCacheEntry struct {
+	key    string
+	result *LookupResult
+}
+
+var resultCache = newQueryCache()
+
+func newQueryCache() *queryCache {
+	return &queryCache{
+		list:  list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+// ClearQueryCache drops all cached lookup results, must be called
+// when config is reloaded
+func ClearQueryCache() {
+	resultCache.clear()
+}
+
+func (c *queryCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.list.Init()
+	clear(c.items)
+}
+
+// checkGeneration drops all items if dictionaries have changed,
+// must be called with c.mu locked
+func (c *queryCache) checkGeneration() {
+	generation := dicts.Generation()
+	if generation == c.generation {
+		return
+	}
+	if c.list.Len() > 0 {
+		slog.Debug("query cache: dictionaries changed, dropping cache", "size", c.list.Len())
+	}
+	c.list.Init()
+	clear(c.items)
+	c.generation = generation
+}
+
+func (c *queryCache) get(key string) *LookupResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkGeneration()
+	elem := c.items[key]
+	if elem == nil {
+		c.misses++
+		slog.Debug("query cache miss", "hits", c.hits, "misses", c.misses)
+		return nil
+	}
+	c.hits++
+	slog.Debug("query cache hit", "hits", c.hits, "misses", c.misses)
+	c.list.MoveToFront(elem)
+	return copyLookupResult(elem.Value.(*queryCacheEntry).result)
+}
+
+func (c *queryCache) put(key string, result *LookupResult, size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkGeneration()
+	result = copyLookupResult(result)
+	if elem := c.items[key]; elem != nil {
+		elem.Value.(*queryCacheEntry).result = result
+		c.list.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.list.PushFront(&queryCacheEntry{
+		key:    key,
+		result: result,
+	})
+	for c.list.Len() > size {
+		elem := c.list.Back()
+		c.list.Remove(elem)
+		delete(c.items, elem.Value.(*queryCacheEntry).key)
+	}
+}
+
+// copyLookupResult copies results, so that cached result is not affected
+// if caller modifies (for example sorts or collapses) them
+func copyLookupResult(result *LookupResult) *LookupResult {
+	resCopy := *result
+	resCopy.Results = cloneResults(result.Results)
+	return &resCopy
+}
+
+// queryCacheKey returns the cache key of a lookup, dictList is the
+// list of dictionaries of the group, before applying query filters
+func queryCacheKey(
+	plan *QueryPlan,
+	dictList []common.Dictionary,
+	resultFlags uint32,
+	limit int,
+) string {
+	parts := []string{
+		plan.Term,
+		strconv.Itoa(int(plan.Mode)),
+		strings.Join(plan.IncludeDicts, "\x01"),
+		strings.Join(plan.ExcludeDicts, "\x01"),
+		plan.Lang,
+		strconv.FormatBool(plan.DetectLang),
+		strconv.FormatBool(plan.CollapseDuplicates),
+		strconv.FormatUint(uint64(resultFlags), 10),
+		strconv.Itoa(limit),
+	}
+	for _, dic := range dictList {
+		parts = append(parts, dic.DictName())
+	}
+	return strings.Join(parts, "\x00")
+}
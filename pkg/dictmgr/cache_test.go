@@ -0,0 +1,80 @@
+package dictmgr
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dicts"
+	common "github.com/ilius/go-dict-commons"
+	"github.com/ilius/is/v2"
+)
+
+func TestQueryCache(t *testing.T) {
+	is := is.New(t)
+	cache := newQueryCache()
+	res1 := &LookupResult{RemappedQuery: "1"}
+	res2 := &LookupResult{RemappedQuery: "2"}
+	res3 := &LookupResult{RemappedQuery: "3"}
+
+	is.True(cache.get("a") == nil)
+	cache.put("a", res1, 2)
+	cache.put("b", res2, 2)
+	is.Equal(cache.get("a").RemappedQuery, "1")
+	// "b" is the least recently used
+	cache.put("c", res3, 2)
+	is.True(cache.get("b") == nil)
+	is.Equal(cache.get("a").RemappedQuery, "1")
+	is.Equal(cache.get("c").RemappedQuery, "3")
+	is.Equal(cache.hits, uint64(3))
+	is.Equal(cache.misses, uint64(2))
+
+	dicts.Reorder(dicts.Order())
+	is.True(cache.get("a") == nil)
+	is.True(cache.get("c") == nil)
+
+	cache.put("a", res1, 2)
+	cache.clear()
+	is.True(cache.get("a") == nil)
+
+	// settings may be modified in place before saving
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "config.toml"))
+	cache.put("a", res1, 2)
+	is.NotErr(dicts.SaveDictsSettings(dicts.SettingsMap()))
+	is.True(cache.get("a") == nil)
+}
+
+func TestQueryCacheCopy(t *testing.T) {
+	is := is.New(t)
+	cache := newQueryCache()
+	res := &SearchResult{via: "lemma: run", alsoIn: []string{"d2"}}
+	cache.put("a", &LookupResult{Results: []common.SearchResultIface{res}}, 2)
+	// modifying the given result does not change the cached one
+	res.via = ""
+	res.alsoIn = append(res.alsoIn[:0], "d3")
+
+	cached := cache.get("a").Results[0].(*SearchResult)
+	is.Equal(cached.via, "lemma: run")
+	is.Equal(cached.alsoIn, []string{"d2"})
+	// neither does modifying the returned result
+	cached.via = ""
+	cached.alsoIn[0] = "d3"
+	cached = cache.get("a").Results[0].(*SearchResult)
+	is.Equal(cached.via, "lemma: run")
+	is.Equal(cached.alsoIn, []string{"d2"})
+}
+
+func TestQueryCacheKey(t *testing.T) {
+	is := is.New(t)
+	d1 := &namedDict{name: "d1"}
+	d2 := &namedDict{name: "d2"}
+	plan := ParseQuery("dict:d1 test", QueryModeFuzzy, false)
+	dictList := []common.Dictionary{d1, d2}
+	key := queryCacheKey(plan, dictList, 0, 0)
+	is.Equal(key, queryCacheKey(ParseQuery("dict:d1 test", QueryModeFuzzy, false), dictList, 0, 0))
+	is.True(key != queryCacheKey(ParseQuery("test", QueryModeFuzzy, false), dictList, 0, 0))
+	is.True(key != queryCacheKey(ParseQuery("dict:d1 test", QueryModeExact, false), dictList, 0, 0))
+	is.True(key != queryCacheKey(plan, dictList, 1, 0))
+	is.True(key != queryCacheKey(plan, dictList, 0, 10))
+	is.True(key != queryCacheKey(plan, []common.Dictionary{d2, d1}, 0, 0))
+	is.True(key != queryCacheKey(plan, []common.Dictionary{d1}, 0, 0))
+}
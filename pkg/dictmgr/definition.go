@@ -0,0 +1,88 @@
+package dictmgr
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/ilius/ayandict/v2/pkg/config"
+	common "github.com/ilius/go-dict-commons"
+)
+
+var (
+	// ErrDefinitionIndexDisabled means Definition mode has no results
+	// because definition_index is disabled in config
+	ErrDefinitionIndexDisabled = errors.New(
+		"definition index is disabled, enable definition_index in config to search in definitions",
+	)
+
+	// ErrDefinitionIndexNotReady means Definition mode may have partial
+	// results because definition index of some dictionaries is not ready
+	ErrDefinitionIndexNotReady = errors.New(
+		"definition index is not ready yet, results may be incomplete",
+	)
+)
+
+// definitionIndexError returns the reason that Definition mode may have
+// no (or partial) results in the given dictionaries, or nil
+func definitionIndexError(conf *config.Config, dictList []common.Dictionary) error {
+	if !conf.DefinitionIndex {
+		return ErrDefinitionIndexDisabled
+	}
+	for _, dic := range dictList {
+		if isDefinitionIndexPending(dic.DictName()) {
+			return ErrDefinitionIndexNotReady
+		}
+	}
+	return nil
+}
+
+func searchDefinition(
+	ctx context.Context,
+	dic common.Dictionary,
+	conf *config.Config,
+	query string,
+) []*common.SearchResultLow {
+	dictName := dic.DictName()
+	idx := getDefinitionIndex(dictName)
+	if idx == nil {
+		slog.Debug("definition index is not ready", "dictName", dictName)
+		return nil
+	}
+	entryIndexes := idx.Search(query)
+	// all matches are equally relevant, no need to load all of them
+	if conf.MaxResultsTotal > 0 && len(entryIndexes) > conf.MaxResultsTotal {
+		entryIndexes = entryIndexes[:conf.MaxResultsTotal]
+	}
+	query = strings.ToLower(strings.TrimSpace(query))
+	results := make([]*common.SearchResultLow, 0, len(entryIndexes))
+	for i, entryIndex := range entryIndexes {
+		if ctxDone(ctx, i) {
+			break
+		}
+		res := dic.EntryByIndex(int(entryIndex))
+		if res == nil {
+			continue
+		}
+		res.F_Score = definitionScore(res.F_Terms, query)
+		results = append(results, res)
+	}
+	return results
+}
+
+// definitionScore gives a higher score to entries whose headword
+// is (or contains) the query
+func definitionScore(terms []string, query string) uint8 {
+	score := uint8(140)
+	for _, term := range terms {
+		term = strings.ToLower(term)
+		if term == query {
+			return 200
+		}
+		if strings.Contains(term, query) {
+			score = 160
+		}
+	}
+	return score
+}
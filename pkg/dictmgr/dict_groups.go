@@ -0,0 +1,69 @@
+package dictmgr
+
+import (
+	"log/slog"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dicts"
+	common "github.com/ilius/go-dict-commons"
+)
+
+// DictGroupNames returns names of dictionary groups (from groups.json)
+func DictGroupNames() []string {
+	groups := dicts.Groups()
+	names := make([]string, len(groups))
+	for i, group := range groups {
+		names[i] = group.Name
+	}
+	return names
+}
+
+func findDictGroup(name string) *dicts.DictGroup {
+	for _, group := range dicts.Groups() {
+		if group.Name == name {
+			return group
+		}
+	}
+	return nil
+}
+
+// HasDictGroup returns true if name is empty (all dictionaries),
+// or there is a dictionary group with this name
+func HasDictGroup(name string) bool {
+	return name == "" || findDictGroup(name) != nil
+}
+
+// groupDicts returns enabled and loaded dictionaries of the group,
+// in the order of group, or all of them if name is empty
+func groupDicts(name string) []common.Dictionary {
+	if name == "" {
+		return activeDicts()
+	}
+	group := findDictGroup(name)
+	if group == nil {
+		slog.Warn("dictionary group not found", "group", name)
+		return activeDicts()
+	}
+	list := make([]common.Dictionary, 0, len(group.Dicts))
+	for _, dictName := range group.Dicts {
+		dic := dicts.ByName(dictName)
+		if dic == nil {
+			slog.Warn("dictionary in group not found", "group", name, "dictName", dictName)
+			continue
+		}
+		if dic.Disabled() || !dic.Loaded() {
+			continue
+		}
+		list = append(list, dic)
+	}
+	return list
+}
+
+// dictListOrder maps name of each dictionary to its index in dictList,
+// used for sorting results in the order of searched dictionaries
+func dictListOrder(dictList []common.Dictionary) map[string]int {
+	order := make(map[string]int, len(dictList))
+	for index, dic := range dictList {
+		order[dic.DictName()] = index
+	}
+	return order
+}
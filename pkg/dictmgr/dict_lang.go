@@ -0,0 +1,133 @@
+package dictmgr
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dicts"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/script"
+	common "github.com/ilius/go-dict-commons"
+)
+
+// guessDictLangs guesses source and target languages of dictionaries
+// that have none of them set, and saves dicts.json if any is guessed
+func guessDictLangs() {
+	modified := false
+	for _, dic := range dicts.List() {
+		if dic.Disabled() || !dic.Loaded() {
+			continue
+		}
+		ds := dicts.Settings(dic.DictName())
+		if ds == nil || ds.SourceLang != "" || ds.TargetLang != "" {
+			continue
+		}
+		source, target := script.GuessLangs(
+			dic.DictName(),
+			dic.Description(),
+			headwordScript(dic),
+		)
+		if source == "" && target == "" {
+			continue
+		}
+		slog.Info(
+			"guessed dictionary languages",
+			"dictName", dic.DictName(),
+			"source", source,
+			"target", target,
+		)
+		ds = ds.Clone()
+		ds.SourceLang = source
+		ds.TargetLang = target
+		dicts.SetSettings(dic.DictName(), ds)
+		modified = true
+	}
+	if !modified {
+		return
+	}
+	err := dicts.SaveDictsSettings(dicts.SettingsMap())
+	if err != nil {
+		slog.Error("error saving dicts settings: " + err.Error())
+	}
+}
+
+func dictSettings(dictName string) *dicts.DictionarySettings {
+	ds := dicts.Settings(dictName)
+	if ds == nil {
+		return &dicts.DictionarySettings{}
+	}
+	return ds
+}
+
+// dictSourceScript returns script of source language of dictionary,
+// or script of its headwords if source language is not known
+func dictSourceScript(dic common.Dictionary) script.Script {
+	if s, ok := script.OfLang(dictSettings(dic.DictName()).SourceLang); ok {
+		return s
+	}
+	return headwordScript(dic)
+}
+
+// dictMatchesLang returns true if source language of dictionary is lang,
+// or if it's not known, and its headwords can be in lang
+func dictMatchesLang(dic common.Dictionary, lang string) bool {
+	sourceLang := dictSettings(dic.DictName()).SourceLang
+	if sourceLang != "" {
+		return script.BaseLang(sourceLang) == script.BaseLang(lang)
+	}
+	langScript, _ := script.OfLang(lang)
+	dictScript := headwordScript(dic)
+	return dictScript == script.Unknown || dictScript == langScript
+}
+
+// dictMatchesScript returns true if source language of dictionary
+// can be written in script s
+func dictMatchesScript(dic common.Dictionary, s script.Script) bool {
+	dictScript := dictSourceScript(dic)
+	return dictScript == script.Unknown || dictScript == s
+}
+
+// dictLangs returns sorted unique base languages of dictList,
+// lang returns source or target language of dictionary settings
+func dictLangs(
+	dictList []common.Dictionary,
+	lang func(ds *dicts.DictionarySettings) string,
+) []string {
+	langs := []string{}
+	for _, dic := range dictList {
+		l := script.BaseLang(lang(dictSettings(dic.DictName())))
+		if l != "" && !slices.Contains(langs, l) {
+			langs = append(langs, l)
+		}
+	}
+	slices.Sort(langs)
+	return langs
+}
+
+// queryDirection returns query direction like "en → fa" based on query
+// language and target languages of dictList. If query has no language,
+// source language of dictList is used if they all have the same one
+// (and script of query is detected).
+// Returns empty string if source or target language is not known
+func queryDirection(plan *QueryPlan, dictList []common.Dictionary) string {
+	source := script.BaseLang(plan.Lang)
+	if source == "" {
+		if plan.termScript() == script.Unknown {
+			return ""
+		}
+		sourceLangs := dictLangs(dictList, func(ds *dicts.DictionarySettings) string {
+			return ds.SourceLang
+		})
+		if len(sourceLangs) != 1 {
+			return ""
+		}
+		source = sourceLangs[0]
+	}
+	targets := dictLangs(dictList, func(ds *dicts.DictionarySettings) string {
+		return ds.TargetLang
+	})
+	if len(targets) == 0 {
+		return ""
+	}
+	return source + " → " + strings.Join(targets, ", ")
+}
@@ -0,0 +1,38 @@
+package dictmgr
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/dictmgrtest"
+	"github.com/ilius/is/v2"
+)
+
+func TestGuessDictLangs(t *testing.T) {
+	is := is.New(t)
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "config.toml"))
+	conf := dictmgrtest.Config()
+	conf.DetectQueryLang = true
+	dictmgrtest.Install(t, dictmgrtest.New("English-Persian", dictmgrtest.E("apple", "سیب")))
+	prev := dictmgrtest.Settings("English-Persian")
+
+	// lookups may be reading settings while languages are guessed
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 20 {
+			LookupHTML("apple", conf, QueryModeFuzzy, "", 0, 0)
+		}
+	}()
+	guessDictLangs()
+	wg.Wait()
+
+	ds := dictmgrtest.Settings("English-Persian")
+	is.Equal(ds.SourceLang, "en")
+	is.Equal(ds.TargetLang, "fa")
+	// settings are replaced, not modified in place
+	is.Equal(prev.SourceLang, "")
+	is.Equal(prev.TargetLang, "")
+}
@@ -0,0 +1,240 @@
+// Package dictmgrtest provides an in-memory implementation of
+// common.Dictionary, and helpers to use it as the active dictionaries
+// in tests of dictmgr and packages using it
+package dictmgrtest
+
+import (
+	"crypto/sha1"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/termsearch"
+	common "github.com/ilius/go-dict-commons"
+)
+
+// Entry is an entry of Dictionary
+type Entry struct {
+	Terms []string
+
+	// Defi is the HTML definition, only used if Items is nil
+	Defi string
+
+	Items []*common.SearchResultItem
+}
+
+func (e *Entry) items() []*common.SearchResultItem {
+	if e.Items != nil {
+		return e.Items
+	}
+	return []*common.SearchResultItem{{Type: 'h', Data: []byte(e.Defi)}}
+}
+
+// Dictionary is an in-memory common.Dictionary, its search methods
+// use the same scoring functions and minimum scores as go-stardict
+// (see termsearch package)
+type Dictionary struct {
+	Name    string
+	Desc    string
+	Entries []*Entry
+
+	// ResDir is returned by ResourceDir
+	ResDir string
+
+	// Latency is slept before each search
+	Latency time.Duration
+
+	// LoadErr is returned by Load, and makes Loaded return false
+	LoadErr error
+
+	// SearchErr is returned by SearchRegex and SearchGlob, and makes
+	// other search methods return no results
+	SearchErr error
+
+	mu       sync.Mutex
+	disabled bool
+	loaded   bool
+}
+
+var _ common.Dictionary = (*Dictionary)(nil)
+
+// New creates a loaded Dictionary with given entries
+func New(name string, entries ...*Entry) *Dictionary {
+	return &Dictionary{
+		Name:    name,
+		Entries: entries,
+		loaded:  true,
+	}
+}
+
+// E creates an Entry with a single term and HTML definition
+func E(term string, defi string) *Entry {
+	return &Entry{
+		Terms: []string{term},
+		Defi:  defi,
+	}
+}
+
+func (d *Dictionary) Disabled() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.disabled
+}
+
+func (d *Dictionary) SetDisabled(disabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.disabled = disabled
+}
+
+func (d *Dictionary) Loaded() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.loaded && d.LoadErr == nil
+}
+
+func (d *Dictionary) Load() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.LoadErr != nil {
+		return d.LoadErr
+	}
+	d.loaded = true
+	return nil
+}
+
+func (d *Dictionary) Close() {}
+
+func (d *Dictionary) DictName() string {
+	return d.Name
+}
+
+func (d *Dictionary) EntryCount() (int, error) {
+	return len(d.Entries), nil
+}
+
+func (d *Dictionary) Description() string {
+	return d.Desc
+}
+
+func (d *Dictionary) ResourceDir() string {
+	return d.ResDir
+}
+
+func (d *Dictionary) ResourceURL() string {
+	return ""
+}
+
+func (d *Dictionary) IndexPath() string {
+	return ""
+}
+
+func (d *Dictionary) IndexFileSize() uint64 {
+	return 0
+}
+
+func (d *Dictionary) InfoPath() string {
+	return ""
+}
+
+// CalcHash returns hash of all terms and definitions
+func (d *Dictionary) CalcHash() ([]byte, error) {
+	h := sha1.New()
+	for _, entry := range d.Entries {
+		h.Write([]byte(strings.Join(entry.Terms, "|")))
+		for _, item := range entry.items() {
+			h.Write(item.Data)
+		}
+	}
+	return h.Sum(nil), nil
+}
+
+// entryList implements termsearch.Entries
+type entryList []*Entry
+
+func (l entryList) Len() int {
+	return len(l)
+}
+
+func (l entryList) Terms(index int) []string {
+	return l[index].Terms
+}
+
+func (l entryList) Result(index int, score uint8) *common.SearchResultLow {
+	return &common.SearchResultLow{
+		F_Score:      score,
+		F_Terms:      l[index].Terms,
+		Items:        l[index].items,
+		F_EntryIndex: uint64(index),
+	}
+}
+
+func (d *Dictionary) EntryByIndex(index int) *common.SearchResultLow {
+	if index < 0 || index >= len(d.Entries) {
+		return nil
+	}
+	return entryList(d.Entries).Result(index, 0)
+}
+
+// wait sleeps for Latency, and returns false if search should fail
+func (d *Dictionary) wait() bool {
+	if d.Latency > 0 {
+		time.Sleep(d.Latency)
+	}
+	return d.SearchErr == nil
+}
+
+func (d *Dictionary) SearchFuzzy(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	if !d.wait() {
+		return nil
+	}
+	return termsearch.Fuzzy(entryList(d.Entries), query, workerCount, timeout)
+}
+
+func (d *Dictionary) SearchStartWith(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	if !d.wait() {
+		return nil
+	}
+	return termsearch.StartWith(entryList(d.Entries), query, workerCount, timeout)
+}
+
+func (d *Dictionary) SearchWordMatch(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	if !d.wait() {
+		return nil
+	}
+	return termsearch.WordMatch(entryList(d.Entries), query, workerCount, timeout)
+}
+
+func (d *Dictionary) SearchRegex(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, error) {
+	if !d.wait() {
+		return nil, d.SearchErr
+	}
+	return termsearch.Regex(entryList(d.Entries), query, workerCount, timeout)
+}
+
+func (d *Dictionary) SearchGlob(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, error) {
+	if !d.wait() {
+		return nil, d.SearchErr
+	}
+	return termsearch.Glob(entryList(d.Entries), query, workerCount, timeout)
+}
@@ -0,0 +1,69 @@
+package dictmgrtest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	common "github.com/ilius/go-dict-commons"
+	"github.com/ilius/is/v2"
+)
+
+func resultTerms(results []*common.SearchResultLow) []string {
+	terms := make([]string, len(results))
+	for i, res := range results {
+		terms[i] = res.F_Terms[0]
+	}
+	return terms
+}
+
+func TestDictionarySearch(t *testing.T) {
+	is := is.New(t)
+	dic := New(
+		"test",
+		E("apple", "a fruit"),
+		E("apply", "to use"),
+		&Entry{Terms: []string{"banana", "bananas"}, Defi: "another fruit"},
+		E("pineapple", "a tropical fruit"),
+	)
+	is.Equal(resultTerms(dic.SearchStartWith("Appl", 1, 0)), []string{"apple", "apply"})
+	is.Equal(resultTerms(dic.SearchWordMatch("bananas", 1, 0)), []string{"banana"})
+
+	results := dic.SearchFuzzy("apple", 1, 0)
+	is.True(len(results) > 0)
+	is.Equal(results[0].F_Terms[0], "apple")
+	is.Equal(results[0].F_Score, uint8(200))
+
+	results, err := dic.SearchRegex(".*apple", 1, 0)
+	is.NotErr(err)
+	is.Equal(resultTerms(results), []string{"apple", "pineapple"})
+	is.Equal(results[1].F_EntryIndex, uint64(3))
+
+	results, err = dic.SearchGlob("ban*", 1, 0)
+	is.NotErr(err)
+	is.Equal(resultTerms(results), []string{"banana"})
+
+	entry := dic.EntryByIndex(2)
+	is.Equal(entry.F_Terms, []string{"banana", "bananas"})
+	is.Equal(string(entry.Items()[0].Data), "another fruit")
+	is.True(dic.EntryByIndex(4) == nil)
+}
+
+func TestDictionaryErrorsAndLatency(t *testing.T) {
+	is := is.New(t)
+	dic := New("test", E("apple", "a fruit"))
+	dic.SearchErr = errors.New("broken")
+	_, err := dic.SearchRegex("apple", 1, 0)
+	is.ErrMsg(err, "broken")
+	is.Equal(len(dic.SearchFuzzy("apple", 1, 0)), 0)
+
+	dic.SearchErr = nil
+	dic.Latency = 20 * time.Millisecond
+	t1 := time.Now()
+	is.Equal(len(dic.SearchStartWith("apple", 1, 0)), 1)
+	is.True(time.Since(t1) >= dic.Latency)
+
+	dic.LoadErr = errors.New("missing file")
+	is.ErrMsg(dic.Load(), "missing file")
+	is.False(dic.Loaded())
+}
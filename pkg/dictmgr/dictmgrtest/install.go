@@ -0,0 +1,65 @@
+package dictmgrtest
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ilius/ayandict/v2/pkg/config"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dicts"
+	common "github.com/ilius/go-dict-commons"
+)
+
+// Config returns default config without features that depend on files
+// in config directory, background indexes or previous lookups
+// (lemma fallback, keyboard layouts, suggestions and query cache)
+func Config() *config.Config {
+	conf := config.Default()
+	conf.DefinitionIndex = false
+	conf.HeadwordIndex = false
+	conf.LemmaFallback = false
+	conf.KeyboardLayouts = nil
+	conf.Suggestions = 0
+	conf.QueryCacheSize = 0
+	return conf
+}
+
+// Install makes dictList the active dictionaries, in the given order
+// and with default settings, until the end of test.
+// Tests calling it must not run in parallel
+func Install(t testing.TB, dictList ...common.Dictionary) {
+	t.Helper()
+	prev := dicts.Current()
+	t.Cleanup(func() {
+		dicts.Replace(prev)
+	})
+
+	order := make(map[string]int, len(dictList))
+	settingsMap := make(map[string]*dicts.DictionarySettings, len(dictList))
+	for index, dic := range dictList {
+		dictName := dic.DictName()
+		order[dictName] = index
+		settingsMap[dictName] = dicts.NewDictSettings(dic, index)
+	}
+	dicts.Replace(dicts.State{
+		DictList:        dictList,
+		DictsOrder:      order,
+		DictSettingsMap: settingsMap,
+	})
+}
+
+// Settings returns settings of an installed dictionary, which can be
+// modified by test, or nil if dictionary is not installed
+func Settings(dictName string) *dicts.DictionarySettings {
+	return dicts.Settings(dictName)
+}
+
+// InstallGroup adds a dictionary group, until Install is called
+// again or the test that called Install is done
+func InstallGroup(name string, dictNames ...string) {
+	s := dicts.Current()
+	s.DictGroups = append(slices.Clone(s.DictGroups), &dicts.DictGroup{
+		Name:  name,
+		Dicts: dictNames,
+	})
+	dicts.Replace(s)
+}
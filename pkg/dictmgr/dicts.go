@@ -1,6 +1,8 @@
 package dictmgr
 
 import (
+	"errors"
+	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
@@ -10,8 +12,16 @@ import (
 
 const DictResPathBase = "/dict-res/"
 
+// ResourceReader is implemented by dictionaries that keep resources in
+// an archive (like MDict .mdd files) instead of a ResourceDir
+type ResourceReader interface {
+	// ReadResource returns content of a resource, relPath has forward
+	// slashes. Returns fs.ErrNotExist if there is no such resource
+	ReadResource(relPath string) ([]byte, error)
+}
+
 func DictSymbol(dictName string) string {
-	ds := dicts.DictSettingsMap[dictName]
+	ds := dicts.Settings(dictName)
 	if ds == nil {
 		return ""
 	}
@@ -19,7 +29,7 @@ func DictSymbol(dictName string) string {
 }
 
 func DictShowTerms(dictName string) bool {
-	ds := dicts.DictSettingsMap[dictName]
+	ds := dicts.Settings(dictName)
 	if ds == nil {
 		return true
 	}
@@ -27,7 +37,7 @@ func DictShowTerms(dictName string) bool {
 }
 
 func CloseDicts() {
-	for _, dic := range dicts.DictList {
+	for _, dic := range dicts.List() {
 		if dic.Disabled() {
 			continue
 		}
@@ -36,8 +46,8 @@ func CloseDicts() {
 }
 
 func DictResFile(dictName string, resPath string) (string, bool) {
-	dic, ok := dicts.DictByName[dictName]
-	if !ok {
+	dic := dicts.ByName(dictName)
+	if dic == nil {
 		return "", false
 	}
 	resDir := dic.ResourceDir()
@@ -55,8 +65,28 @@ func DictResFile(dictName string, resPath string) (string, bool) {
 	return fpath, true
 }
 
+// DictResData returns content of a resource of a ResourceReader dictionary
+func DictResData(dictName string, resPath string) ([]byte, bool) {
+	dic := dicts.ByName(dictName)
+	if dic == nil {
+		return nil, false
+	}
+	reader, ok := dic.(ResourceReader)
+	if !ok {
+		return nil, false
+	}
+	data, err := reader.ReadResource(resPath)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Error("error reading resource", "err", err, "dictName", dictName, "path", resPath)
+		}
+		return nil, false
+	}
+	return data, true
+}
+
 func AudioVolume(dictName string) int {
-	ds := dicts.DictSettingsMap[dictName]
+	ds := dicts.Settings(dictName)
 	if ds == nil {
 		slog.Error("AudioVolume: no Settings value", "dictName", dictName)
 		return 100
@@ -0,0 +1,76 @@
+package dictmgr
+
+import (
+	"hash/fnv"
+	"slices"
+	"strings"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/defindex"
+	common "github.com/ilius/go-dict-commons"
+)
+
+type definitionKey struct {
+	term string
+	hash uint64
+}
+
+// definitionHash returns hash of definition text of result, ignoring
+// markup, punctuation, whitespace and case, so that near-identical
+// definitions have the same hash. returns false if definition has no text
+func definitionHash(res *SearchResult) (uint64, bool) {
+	h := fnv.New64a()
+	empty := true
+	for _, item := range res.Items() {
+		for _, token := range defindex.Tokenize(defindex.PlainText(item)) {
+			if !empty {
+				_, _ = h.Write([]byte{' '})
+			}
+			_, _ = h.Write([]byte(token))
+			empty = false
+		}
+	}
+	return h.Sum64(), !empty
+}
+
+// collapseDuplicates removes results that have the same headword and
+// definition as a previous (better) result from another dictionary, and
+// adds their dictionary names to AlsoIn of that result.
+// stops after limit results (if limit > 0), so definitions of the rest
+// of results are not read
+func collapseDuplicates(results []common.SearchResultIface, limit int) []common.SearchResultIface {
+	seen := map[definitionKey]*SearchResult{}
+	collapsed := make([]common.SearchResultIface, 0, len(results))
+	for _, res := range results {
+		if limit > 0 && len(collapsed) >= limit {
+			break
+		}
+		sr, ok := res.(*SearchResult)
+		if !ok || len(sr.Terms()) == 0 {
+			collapsed = append(collapsed, res)
+			continue
+		}
+		hash, ok := definitionHash(sr)
+		if !ok {
+			collapsed = append(collapsed, res)
+			continue
+		}
+		key := definitionKey{
+			term: strings.ToLower(sr.Terms()[0]),
+			hash: hash,
+		}
+		first := seen[key]
+		if first == nil {
+			seen[key] = sr
+			collapsed = append(collapsed, res)
+			continue
+		}
+		dictName := sr.DictName()
+		if dictName == first.DictName() || slices.Contains(first.alsoIn, dictName) {
+			// same entry in one dictionary is not a re-packaging
+			collapsed = append(collapsed, res)
+			continue
+		}
+		first.alsoIn = append(first.alsoIn, dictName)
+	}
+	return collapsed
+}
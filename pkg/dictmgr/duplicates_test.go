@@ -0,0 +1,48 @@
+package dictmgr
+
+import (
+	"testing"
+
+	"github.com/ilius/ayandict/v2/pkg/config"
+	common "github.com/ilius/go-dict-commons"
+	"github.com/ilius/is/v2"
+)
+
+// namedDict only implements DictName, other methods must not be called
+type namedDict struct {
+	common.Dictionary
+	name string
+}
+
+func (d *namedDict) DictName() string { return d.name }
+
+func newTestSearchResult(dictName string, term string, defi string) *SearchResult {
+	conf := config.Default()
+	return NewSearchResult(&common.SearchResultLow{
+		F_Terms: []string{term},
+		F_Score: 200,
+		Items: func() []*common.SearchResultItem {
+			return []*common.SearchResultItem{{Type: 'h', Data: []byte(defi)}}
+		},
+	}, &namedDict{name: dictName}, conf, 0)
+}
+
+func TestCollapseDuplicates(t *testing.T) {
+	is := is.New(t)
+	results := []common.SearchResultIface{
+		newTestSearchResult("A", "set", "<b>To put</b> something somewhere."),
+		newTestSearchResult("B", "Set", "to put something  somewhere"),
+		newTestSearchResult("C", "set", "A group of things."),
+		newTestSearchResult("A", "set", "to put something somewhere"),
+		newTestSearchResult("D", "set", "To put something, somewhere!"),
+	}
+	collapsed := collapseDuplicates(results, 0)
+	is.Equal(len(collapsed), 3)
+	is.Equal(collapsed[0].DictName(), "A")
+	is.Equal(ResultAlsoIn(collapsed[0]), []string{"B", "D"})
+	is.Equal(collapsed[1].DictName(), "C")
+	is.Equal(collapsed[2].DictName(), "A")
+	is.Equal(len(ResultAlsoIn(collapsed[1])), 0)
+
+	is.Equal(len(collapseDuplicates(results, 2)), 2)
+}
@@ -0,0 +1,84 @@
+package dictmgr
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ilius/ayandict/v2/pkg/config"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/textnorm"
+	common "github.com/ilius/go-dict-commons"
+)
+
+// ResultGroup is a list of results (from different dictionaries or entries)
+// that have the same (normalized) headword
+type ResultGroup struct {
+	// Term is the headword of the best result of group
+	Term string
+
+	// Results are sorted by dictionary order
+	Results []common.SearchResultIface
+}
+
+// Score returns the best score of results in group
+func (g *ResultGroup) Score() uint8 {
+	return bestScore(g.Results)
+}
+
+// DictNames returns unique names of dictionaries in group,
+// including dictionaries of collapsed duplicate results
+func (g *ResultGroup) DictNames() []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, res := range g.Results {
+		for _, name := range append([]string{res.DictName()}, ResultAlsoIn(res)...) {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func headwordKey(term string, profile *textnorm.Profile) string {
+	if profile != nil {
+		term = profile.Normalize(term)
+	}
+	return strings.ToLower(strings.TrimSpace(term))
+}
+
+// GroupResults groups sorted results by their first headword, normalized
+// with conf.Normalization (if set) and lowercased. Groups are in the order
+// of their best result, and results of each group are sorted by dictOrder,
+// which is the order of dictionaries used by lookup (see LookupResult)
+func GroupResults(
+	results []common.SearchResultIface,
+	dictOrder map[string]int,
+	conf *config.Config,
+) []*ResultGroup {
+	profile, _ := textnorm.NewProfile(conf.Normalization)
+	groups := []*ResultGroup{}
+	groupByKey := map[string]*ResultGroup{}
+	for _, res := range results {
+		terms := res.Terms()
+		if len(terms) == 0 {
+			continue
+		}
+		key := headwordKey(terms[0], profile)
+		group := groupByKey[key]
+		if group == nil {
+			group = &ResultGroup{Term: terms[0]}
+			groupByKey[key] = group
+			groups = append(groups, group)
+		}
+		group.Results = append(group.Results, res)
+	}
+	for _, group := range groups {
+		sort.SliceStable(group.Results, func(i, j int) bool {
+			return dictOrder[group.Results[i].DictName()] <
+				dictOrder[group.Results[j].DictName()]
+		})
+	}
+	return groups
+}
@@ -0,0 +1,56 @@
+package dictmgr
+
+import (
+	"testing"
+
+	"github.com/ilius/ayandict/v2/pkg/config"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/dictmgrtest"
+	common "github.com/ilius/go-dict-commons"
+	"github.com/ilius/is/v2"
+)
+
+type testResult struct {
+	common.SearchResultIface
+	terms    []string
+	dictName string
+	score    uint8
+}
+
+func (r *testResult) Terms() []string  { return r.terms }
+func (r *testResult) DictName() string { return r.dictName }
+func (r *testResult) Score() uint8     { return r.score }
+
+func TestGroupResults(t *testing.T) {
+	is := is.New(t)
+	results := []common.SearchResultIface{
+		&testResult{terms: []string{"Set"}, dictName: "C", score: 200},
+		&testResult{terms: []string{"set"}, dictName: "A", score: 190},
+		&testResult{terms: []string{"seta"}, dictName: "B", score: 150},
+		&testResult{terms: []string{"set "}, dictName: "B", score: 140},
+	}
+	conf := config.Default()
+	groups := GroupResults(results, map[string]int{"A": 0, "B": 1, "C": 2}, conf)
+	is.Equal(len(groups), 2)
+	is.Equal(groups[0].Term, "Set")
+	is.Equal(groups[0].Score(), uint8(200))
+	is.Equal(groups[0].DictNames(), []string{"A", "B", "C"})
+	is.Equal(groups[1].Term, "seta")
+	is.Equal(len(groups[1].Results), 1)
+}
+
+func TestGroupResultsDictGroupOrder(t *testing.T) {
+	is := is.New(t)
+	conf := dictmgrtest.Config()
+	dictmgrtest.Install(
+		t,
+		dictmgrtest.New("d1", dictmgrtest.E("apple", "a fruit")),
+		dictmgrtest.New("d2", dictmgrtest.E("apple", "a red fruit")),
+	)
+	// dictionary group has its own order
+	dictmgrtest.InstallGroup("g1", "d2", "d1")
+	result := LookupHTML("apple", conf, QueryModeFuzzy, "g1", 0, 0)
+	is.Equal(lookupTerms(result.Results), []string{"d2: apple", "d1: apple"})
+	groups := GroupResults(result.Results, result.DictOrder, conf)
+	is.Equal(len(groups), 1)
+	is.Equal(groups[0].DictNames(), []string{"d2", "d1"})
+}
@@ -0,0 +1,203 @@
+package dictmgr
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/trigram"
+	"github.com/ilius/glob"
+	common "github.com/ilius/go-dict-commons"
+	su "github.com/ilius/go-dict-commons/search_utils"
+)
+
+// same minimum scores that are used by stardict package
+const (
+	fuzzyMinScore   = uint8(64)
+	patternMinScore = uint8(140)
+)
+
+// fuzzy queries with a shorter main word (in runes) are not searched
+// using trigram index, because a typo breaks most of their trigrams
+const fuzzyIndexMinWordLen = 4
+
+// searchFuzzyIndexed uses trigram index of headwords to find candidates
+// and then scores them the same way as SearchFuzzy of stardict package.
+// returns ok=false if index is not ready or query is too short to use it,
+// or has too few trigrams for the number of edits that are allowed
+func searchFuzzyIndexed(
+	ctx context.Context,
+	dic common.Dictionary,
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, bool) {
+	idx := getTrigramIndex(dic.DictName())
+	if idx == nil {
+		return nil, false
+	}
+	args := fuzzyArgs(strings.ToLower(strings.TrimSpace(query)))
+	queryMainWord := args.QueryMainWord
+	if len(queryMainWord) < fuzzyIndexMinWordLen {
+		return nil, false
+	}
+	// fuzzy search allows up to 1/3 of the word to be edited, and each
+	// edit breaks at most 3 of its (padded) trigrams, so a matching
+	// headword has at least the rest of them. If an edit can break all
+	// of them, index can not be used without missing results
+	grams := trigram.Trigrams(string(queryMainWord))
+	maxDistance := len(queryMainWord) / 3
+	minCount := len(grams) - 3*maxDistance
+	if minCount < 1 {
+		return nil, false
+	}
+	entryIndexes := idx.AtLeast(grams, minCount)
+	return runOnEntries(ctx, dic, entryIndexes, workerCount, timeout, func(terms []string, buff []uint16) uint8 {
+		score := su.ScoreFuzzy(terms, args, buff)
+		if score < fuzzyMinScore {
+			return 0
+		}
+		return score
+	}), true
+}
+
+// fuzzyArgs creates arguments of su.ScoreFuzzy for a lowercase query,
+// the same way as SearchFuzzy of stardict package
+func fuzzyArgs(query string) *su.ScoreFuzzyArgs {
+	queryWords := strings.Split(query, " ")
+	mainWordIndex := 0
+	for mainWordIndex < len(queryWords)-1 && queryWords[mainWordIndex] == "*" {
+		mainWordIndex++
+	}
+	minWordCount := 1
+	queryWordCount := 0
+	for _, word := range queryWords {
+		if word == "*" {
+			minWordCount++
+			continue
+		}
+		queryWordCount++
+	}
+	return &su.ScoreFuzzyArgs{
+		Query:          query,
+		QueryRunes:     []rune(query),
+		QueryMainWord:  []rune(queryWords[mainWordIndex]),
+		QueryWordCount: queryWordCount,
+		MinWordCount:   minWordCount,
+		MainWordIndex:  mainWordIndex,
+	}
+}
+
+// searchRegexIndexed uses trigram index of headwords to find candidates
+// that contain all literal parts of regex, and then matches them.
+// returns ok=false if index is not ready or regex has no long literal part
+func searchRegexIndexed(
+	ctx context.Context,
+	dic common.Dictionary,
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, bool, error) {
+	idx := getTrigramIndex(dic.DictName())
+	if idx == nil {
+		return nil, false, nil
+	}
+	grams := literalTrigrams(trigram.RegexLiterals(query))
+	if len(grams) == 0 {
+		return nil, false, nil
+	}
+	re, err := regexp.Compile("^" + query + "$")
+	if err != nil {
+		return nil, true, err
+	}
+	return runOnEntries(
+		ctx, dic, idx.All(grams), workerCount, timeout,
+		patternScorer(re.MatchString),
+	), true, nil
+}
+
+// searchGlobIndexed is like searchRegexIndexed, but for glob patterns
+func searchGlobIndexed(
+	ctx context.Context,
+	dic common.Dictionary,
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, bool, error) {
+	idx := getTrigramIndex(dic.DictName())
+	if idx == nil {
+		return nil, false, nil
+	}
+	grams := literalTrigrams(trigram.GlobLiterals(query))
+	if len(grams) == 0 {
+		return nil, false, nil
+	}
+	pattern, err := glob.Compile(query)
+	if err != nil {
+		return nil, true, err
+	}
+	return runOnEntries(
+		ctx, dic, idx.All(grams), workerCount, timeout,
+		patternScorer(pattern.Match),
+	), true, nil
+}
+
+func literalTrigrams(literals []string) []string {
+	grams := []string{}
+	for _, literal := range literals {
+		grams = append(grams, trigram.InnerTrigrams(literal)...)
+	}
+	return grams
+}
+
+func patternScorer(match func(string) bool) func([]string, []uint16) uint8 {
+	return func(terms []string, _ []uint16) uint8 {
+		for _, term := range terms {
+			if !match(term) {
+				continue
+			}
+			if len(term) < 20 {
+				return 200 - uint8(len(term))
+			}
+			return 180
+		}
+		return 0
+	}
+}
+
+// runOnEntries scores the given entries of dictionary using workers,
+// and returns the ones with non-zero score
+func runOnEntries(
+	ctx context.Context,
+	dic common.Dictionary,
+	entryIndexes []uint32,
+	workerCount int,
+	timeout time.Duration,
+	score func(terms []string, buff []uint16) uint8,
+) []*common.SearchResultLow {
+	return su.RunWorkers(
+		len(entryIndexes),
+		workerCount,
+		timeout,
+		func(start int, end int) []*common.SearchResultLow {
+			var results []*common.SearchResultLow
+			buff := make([]uint16, 500)
+			for i, entryIndex := range entryIndexes[start:end] {
+				if ctxDone(ctx, i) {
+					break
+				}
+				res := dic.EntryByIndex(int(entryIndex))
+				if res == nil {
+					continue
+				}
+				res.F_Score = score(res.F_Terms, buff)
+				if res.F_Score == 0 {
+					continue
+				}
+				results = append(results, res)
+			}
+			return results
+		},
+	)
+}
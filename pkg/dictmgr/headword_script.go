@@ -0,0 +1,64 @@
+package dictmgr
+
+import (
+	"sync"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/script"
+	common "github.com/ilius/go-dict-commons"
+)
+
+// number of entries to read for detecting script of headwords
+const headwordScriptSampleSize = 100
+
+var (
+	headwordScriptMutex  sync.Mutex
+	headwordScriptByName = map[string]*dictScript{}
+)
+
+type dictScript struct {
+	dic    common.Dictionary
+	script script.Script
+}
+
+func resetHeadwordScripts() {
+	headwordScriptMutex.Lock()
+	defer headwordScriptMutex.Unlock()
+	headwordScriptByName = map[string]*dictScript{}
+}
+
+// headwordScript returns the script of most headwords of dictionary,
+// detected from a sample of entries, and cached by dictionary name
+func headwordScript(dic common.Dictionary) script.Script {
+	dictName := dic.DictName()
+	headwordScriptMutex.Lock()
+	cached := headwordScriptByName[dictName]
+	headwordScriptMutex.Unlock()
+	// another dictionary may have been loaded with the same name
+	if cached != nil && cached.dic == dic {
+		return cached.script
+	}
+	s := script.DetectAll(sampleHeadwords(dic, headwordScriptSampleSize))
+	headwordScriptMutex.Lock()
+	headwordScriptByName[dictName] = &dictScript{dic: dic, script: s}
+	headwordScriptMutex.Unlock()
+	return s
+}
+
+// sampleHeadwords returns first term of up to n entries,
+// evenly spread over the dictionary
+func sampleHeadwords(dic common.Dictionary, n int) []string {
+	count, err := dic.EntryCount()
+	if err != nil || count == 0 {
+		return nil
+	}
+	n = min(n, count)
+	terms := make([]string, 0, n)
+	for i := range n {
+		entry := dic.EntryByIndex(i * count / n)
+		if entry == nil || len(entry.F_Terms) == 0 {
+			continue
+		}
+		terms = append(terms, entry.F_Terms[0])
+	}
+	return terms
+}
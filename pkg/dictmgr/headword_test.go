@@ -0,0 +1,70 @@
+package dictmgr
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/dictmgrtest"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/trigram"
+	common "github.com/ilius/go-dict-commons"
+	"github.com/ilius/is/v2"
+)
+
+func fuzzyResultTerms(results []*common.SearchResultLow) []string {
+	terms := make([]string, len(results))
+	for i, res := range results {
+		terms[i] = res.F_Terms[0]
+	}
+	slices.Sort(terms)
+	return terms
+}
+
+func TestSearchFuzzyIndexed(t *testing.T) {
+	is := is.New(t)
+	entries := []*dictmgrtest.Entry{}
+	for _, term := range []string{
+		"apple", "apples", "apply", "ample", "maple", "applesauce",
+		"signature", "signatory", "signal", "sign", "design",
+		"gold", "gold leaf", "golden", "leaf", "leave", "leaves",
+		"cat", "cats", "act", "coat", "hat",
+		"dictionary", "diction", "fiction", "dictator",
+		"search", "research", "searching", "starch",
+	} {
+		entries = append(entries, dictmgrtest.E(term, ""))
+	}
+	dic := dictmgrtest.New("test", entries...)
+	idx, err := trigram.Build(context.Background(), dic)
+	is.NotErr(err)
+	indexMutex.Lock()
+	trigramIndexByName[dic.DictName()] = idx
+	indexMutex.Unlock()
+	t.Cleanup(func() {
+		indexMutex.Lock()
+		delete(trigramIndexByName, dic.DictName())
+		indexMutex.Unlock()
+	})
+
+	ctx := context.Background()
+	for _, query := range []string{
+		"aple", "appel", "maple", "ampl",
+		"signatur", "sigature", "signl",
+		"gold lef", "leav", "leafs",
+		"dictionray", "fictoin",
+		"serch", "reserch",
+	} {
+		results, ok := searchFuzzyIndexed(ctx, dic, query, 1, 0)
+		is.Msg(query).True(ok)
+		expected := fuzzyResultTerms(dic.SearchFuzzy(query, 1, 0))
+		is.Msg(query).True(len(expected) > 0)
+		is.Msg(query).Equal(fuzzyResultTerms(results), expected)
+	}
+	// too short, or an edit can break all of their trigrams
+	for _, query := range []string{
+		"cat", "cts", "hat",
+		"apples", "golden", "singature", "dictonary", "searhcing",
+	} {
+		_, ok := searchFuzzyIndexed(ctx, dic, query, 1, 0)
+		is.Msg(query).False(ok)
+	}
+}
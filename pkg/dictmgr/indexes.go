@@ -0,0 +1,224 @@
+package dictmgr
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ilius/ayandict/v2/pkg/config"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/defindex"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dicts"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/indexcache"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/textnorm"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/trigram"
+	common "github.com/ilius/go-dict-commons"
+)
+
+// search indexes of dictionaries, which are loaded from cache directory
+// or built in background after loading dictionaries
+var (
+	indexMutex         sync.RWMutex
+	defIndexByName     = map[string]*defindex.Index{}
+	trigramIndexByName = map[string]*trigram.Index{}
+	normIndexByName    = map[string]*normIndex{}
+	indexCancel        context.CancelFunc
+
+	// defIndexPending has names of dictionaries whose definition index
+	// is being loaded or built
+	defIndexPending = map[string]bool{}
+)
+
+type normIndex struct {
+	profileKey string
+	*textnorm.Index
+}
+
+type indexJob struct {
+	dic        common.Dictionary
+	key        string
+	definition bool
+	trigram    bool
+	norm       *textnorm.Profile
+}
+
+func getDefinitionIndex(dictName string) *defindex.Index {
+	indexMutex.RLock()
+	defer indexMutex.RUnlock()
+	return defIndexByName[dictName]
+}
+
+// isDefinitionIndexPending returns true if definition index of dictionary
+// is being loaded or built
+func isDefinitionIndexPending(dictName string) bool {
+	indexMutex.RLock()
+	defer indexMutex.RUnlock()
+	return defIndexPending[dictName]
+}
+
+func getTrigramIndex(dictName string) *trigram.Index {
+	indexMutex.RLock()
+	defer indexMutex.RUnlock()
+	return trigramIndexByName[dictName]
+}
+
+// getNormIndex returns index of normalized headwords
+// if it's ready and built with the given profile
+func getNormIndex(dictName string, profile *textnorm.Profile) *textnorm.Index {
+	indexMutex.RLock()
+	defer indexMutex.RUnlock()
+	idx := normIndexByName[dictName]
+	if idx == nil || idx.profileKey != profile.Key() {
+		return nil
+	}
+	return idx.Index
+}
+
+// indexKey returns the key of cached indexes of dictionary, based on path,
+// size and modification time of its index file, so that indexes of a
+// replaced file are not used. Returns empty string if file is not found
+func indexKey(dic common.Dictionary) string {
+	path := dic.IndexPath()
+	if path == "" {
+		return ""
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		slog.Error("error in os.Stat", "err", err, "dictName", dic.DictName())
+		return ""
+	}
+	return sha1sumStr(fmt.Sprintf("%s\x00%d\x00%d", path, info.Size(), info.ModTime().UnixNano()))
+}
+
+// startIndexing loads (or builds and saves) search indexes
+// of enabled dictionaries in background
+func startIndexing(conf *config.Config) {
+	indexMutex.Lock()
+	defer indexMutex.Unlock()
+	if indexCancel != nil {
+		indexCancel()
+		indexCancel = nil
+	}
+	defIndexByName = map[string]*defindex.Index{}
+	trigramIndexByName = map[string]*trigram.Index{}
+	normIndexByName = map[string]*normIndex{}
+	defIndexPending = map[string]bool{}
+	jobs := []*indexJob{}
+	for _, dic := range activeDicts() {
+		ds := dicts.Settings(dic.DictName())
+		if ds == nil {
+			continue
+		}
+		key := indexKey(dic)
+		if key == "" {
+			continue
+		}
+		job := &indexJob{
+			dic:        dic,
+			key:        key,
+			definition: conf.DefinitionIndex && ds.Definition(),
+			trigram:    conf.HeadwordIndex,
+			norm:       normProfile(conf, ds),
+		}
+		if !job.definition && !job.trigram && job.norm == nil {
+			continue
+		}
+		if job.definition {
+			defIndexPending[dic.DictName()] = true
+		}
+		jobs = append(jobs, job)
+	}
+	if len(jobs) == 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	indexCancel = cancel
+	go runIndexJobs(ctx, jobs)
+}
+
+func runIndexJobs(ctx context.Context, jobs []*indexJob) {
+	// one dictionary at a time, to keep it light in background
+	for _, job := range jobs {
+		dictName := job.dic.DictName()
+		if job.trigram {
+			idx := loadOrBuildIndex(ctx, trigram.Kind, job.dic, job.key, trigram.Build)
+			if ctx.Err() != nil {
+				return
+			}
+			if idx != nil {
+				indexMutex.Lock()
+				trigramIndexByName[dictName] = idx
+				indexMutex.Unlock()
+			}
+		}
+		if job.norm != nil {
+			profile := job.norm
+			idx := loadOrBuildIndex(
+				ctx,
+				textnorm.KindPrefix+profile.Key(),
+				job.dic,
+				job.key,
+				func(ctx context.Context, dic common.Dictionary) (*textnorm.Index, error) {
+					return textnorm.Build(ctx, dic, profile)
+				},
+			)
+			if ctx.Err() != nil {
+				return
+			}
+			if idx != nil {
+				indexMutex.Lock()
+				normIndexByName[dictName] = &normIndex{
+					profileKey: profile.Key(),
+					Index:      idx,
+				}
+				indexMutex.Unlock()
+			}
+		}
+		if job.definition {
+			idx := loadOrBuildIndex(ctx, defindex.Kind, job.dic, job.key, defindex.Build)
+			if ctx.Err() != nil {
+				return
+			}
+			indexMutex.Lock()
+			if idx != nil {
+				defIndexByName[dictName] = idx
+			}
+			delete(defIndexPending, dictName)
+			indexMutex.Unlock()
+		}
+	}
+}
+
+func loadOrBuildIndex[T any](
+	ctx context.Context,
+	kind string,
+	dic common.Dictionary,
+	key string,
+	build func(context.Context, common.Dictionary) (*T, error),
+) *T {
+	dictName := dic.DictName()
+	idx := new(T)
+	err := indexcache.Load(kind, key, idx)
+	if err == nil {
+		return idx
+	}
+	if !os.IsNotExist(err) {
+		slog.Error("error loading index", "err", err, "kind", kind, "dictName", dictName)
+	}
+	t := time.Now()
+	idx, err = build(ctx, dic)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("error building index", "err", err, "kind", kind, "dictName", dictName)
+		}
+		return nil
+	}
+	slog.Info("Built index", "kind", kind, "dictName", dictName, "dt", time.Since(t))
+	err = indexcache.Save(kind, key, idx)
+	if err != nil {
+		slog.Error("error saving index", "err", err, "kind", kind, "dictName", dictName)
+	}
+	return idx
+}
@@ -0,0 +1,48 @@
+package dictmgr
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/dictmgrtest"
+	"github.com/ilius/is/v2"
+)
+
+// fileDict is a dictionary with an index file
+type fileDict struct {
+	*dictmgrtest.Dictionary
+	indexPath string
+}
+
+func (d *fileDict) IndexPath() string {
+	return d.indexPath
+}
+
+func TestIndexKey(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	dic := &fileDict{
+		Dictionary: dictmgrtest.New("test"),
+		indexPath:  filepath.Join(dir, "test.idx"),
+	}
+	is.Equal(indexKey(dic), "")
+
+	is.NotErr(os.WriteFile(dic.indexPath, []byte("abc"), 0o644))
+	key1 := indexKey(dic)
+	is.True(key1 != "")
+	is.Equal(indexKey(dic), key1)
+
+	// replaced with a file of the same size
+	is.NotErr(os.WriteFile(dic.indexPath, []byte("xyz"), 0o644))
+	mtime := time.Now().Add(time.Minute)
+	is.NotErr(os.Chtimes(dic.indexPath, mtime, mtime))
+	key2 := indexKey(dic)
+	is.True(key2 != "")
+	is.True(key2 != key1)
+
+	is.NotErr(os.WriteFile(dic.indexPath, []byte("abcd"), 0o644))
+	is.NotErr(os.Chtimes(dic.indexPath, mtime, mtime))
+	is.True(indexKey(dic) != key2)
+}
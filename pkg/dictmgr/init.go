@@ -7,4 +7,10 @@ import (
 
 func InitDicts(conf *config.Config) {
 	dicts.InitDicts(conf)
+	resetHeadwordScripts()
+	resetTermTables()
+	guessDictLangs()
+	ClearQueryCache()
+	startIndexing(conf)
+	startSuggestIndexing(conf)
 }
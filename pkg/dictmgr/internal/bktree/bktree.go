@@ -0,0 +1,128 @@
+// Package bktree implements a BK-tree of words, for finding words
+// within a given (Levenshtein) edit distance of a query
+package bktree
+
+import (
+	"sort"
+
+	"github.com/ilius/go-dict-commons/levenshtein"
+)
+
+type child struct {
+	distance uint16
+	node     *node
+}
+
+type node struct {
+	word     []rune
+	children []child
+}
+
+func (n *node) child(distance uint16) *node {
+	for _, c := range n.children {
+		if c.distance == distance {
+			return c.node
+		}
+	}
+	return nil
+}
+
+// Tree is a BK-tree, it's not safe for concurrent Add,
+// but concurrent Search calls (without Add) are fine
+type Tree struct {
+	root *node
+	size int
+	buff []uint16
+}
+
+// Match is a word found by Search
+type Match struct {
+	Word     string
+	Distance int
+}
+
+func distance(a []rune, b []rune, buff []uint16) uint16 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	return levenshtein.ComputeDistance(a, b, buff)
+}
+
+// Len returns number of (unique) words in tree
+func (t *Tree) Len() int {
+	return t.size
+}
+
+// Add adds word to tree, if it's not already added
+func (t *Tree) Add(word string) {
+	runes := []rune(word)
+	if len(runes) == 0 || len(runes) > 0xffff {
+		return
+	}
+	if t.root == nil {
+		t.root = &node{word: runes}
+		t.size = 1
+		return
+	}
+	if t.buff == nil {
+		t.buff = make([]uint16, 100)
+	}
+	current := t.root
+	for {
+		d := distance(current.word, runes, t.buff)
+		if d == 0 {
+			return
+		}
+		next := current.child(d)
+		if next == nil {
+			current.children = append(current.children, child{
+				distance: d,
+				node:     &node{word: runes},
+			})
+			t.size++
+			return
+		}
+		current = next
+	}
+}
+
+// Search returns words within maxDistance of query, sorted by distance
+// and then alphabetically, at most limit words (if limit > 0)
+func (t *Tree) Search(query string, maxDistance int, limit int) []Match {
+	if t.root == nil {
+		return nil
+	}
+	queryRunes := []rune(query)
+	buff := make([]uint16, 100)
+	matches := []Match{}
+	stack := []*node{t.root}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		d := int(distance(n.word, queryRunes, buff))
+		if d <= maxDistance {
+			matches = append(matches, Match{
+				Word:     string(n.word),
+				Distance: d,
+			})
+		}
+		// by triangle inequality, only children with distance
+		// in [d-maxDistance, d+maxDistance] can have matches
+		for _, c := range n.children {
+			cd := int(c.distance)
+			if cd >= d-maxDistance && cd <= d+maxDistance {
+				stack = append(stack, c.node)
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Distance != matches[j].Distance {
+			return matches[i].Distance < matches[j].Distance
+		}
+		return matches[i].Word < matches[j].Word
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
@@ -0,0 +1,35 @@
+package bktree
+
+import (
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+func TestTree(t *testing.T) {
+	is := is.New(t)
+	tree := &Tree{}
+	is.Equal(len(tree.Search("foo", 2, 0)), 0)
+	for _, word := range []string{
+		"book", "books", "cake", "boo", "boon", "cook", "cape", "cart", "book",
+	} {
+		tree.Add(word)
+	}
+	is.Equal(tree.Len(), 8)
+	is.Equal(tree.Search("bok", 1, 0), []Match{
+		{Word: "boo", Distance: 1},
+		{Word: "book", Distance: 1},
+	})
+	is.Equal(tree.Search("bok", 2, 3), []Match{
+		{Word: "boo", Distance: 1},
+		{Word: "book", Distance: 1},
+		{Word: "books", Distance: 2},
+	})
+	is.Equal(tree.Search("caqe", 1, 0), []Match{
+		{Word: "cake", Distance: 1},
+		{Word: "cape", Distance: 1},
+	})
+	is.Equal(tree.Search("book", 0, 0), []Match{
+		{Word: "book", Distance: 0},
+	})
+}
@@ -0,0 +1,129 @@
+// Package defindex implements an inverted index over definitions
+// of a dictionary, used for full-text search in definitions
+package defindex
+
+import (
+	"context"
+	std_html "html"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	common "github.com/ilius/go-dict-commons"
+)
+
+// Kind is the name of index used in indexcache
+// increase version when format or tokenization is changed
+const Kind = "definition-v1"
+
+// check ctx every this many entries while building index
+const ctxCheckInterval = 1000
+
+var tagRE = regexp.MustCompile(`<[^<>]*>`)
+
+// Index maps each token (lowercase word) to sorted list of entry indexes
+// whose definition contains that word
+type Index struct {
+	Tokens map[string][]uint32
+}
+
+func isTokenRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+// Tokenize splits text into lowercase words
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !isTokenRune(r)
+	})
+}
+
+// PlainText converts definition item to plain text
+func PlainText(item *common.SearchResultItem) string {
+	text := string(item.Data)
+	switch item.Type {
+	case 'h', 'x', 'g':
+		text = tagRE.ReplaceAllString(text, " ")
+		text = std_html.UnescapeString(text)
+	}
+	return text
+}
+
+// Build reads all entries of dictionary and creates the index
+// returns ctx.Err() if ctx is done before it's finished
+func Build(ctx context.Context, dic common.Dictionary) (*Index, error) {
+	count, err := dic.EntryCount()
+	if err != nil {
+		return nil, err
+	}
+	tokens := map[string][]uint32{}
+	for entryIndex := range count {
+		if entryIndex%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		entry := dic.EntryByIndex(entryIndex)
+		if entry == nil {
+			continue
+		}
+		seen := map[string]bool{}
+		for _, item := range entry.Items() {
+			for _, token := range Tokenize(PlainText(item)) {
+				if seen[token] {
+					continue
+				}
+				seen[token] = true
+				// entryIndex is increasing, so lists stay sorted
+				tokens[token] = append(tokens[token], uint32(entryIndex))
+			}
+		}
+	}
+	return &Index{Tokens: tokens}, nil
+}
+
+// Search returns sorted indexes of entries whose definition
+// contains all words of query
+func (idx *Index) Search(query string) []uint32 {
+	words := Tokenize(query)
+	if len(words) == 0 {
+		return nil
+	}
+	lists := make([][]uint32, len(words))
+	for i, word := range words {
+		list := idx.Tokens[word]
+		if len(list) == 0 {
+			return nil
+		}
+		lists[i] = list
+	}
+	// start from the shortest list
+	sort.Slice(lists, func(i, j int) bool {
+		return len(lists[i]) < len(lists[j])
+	})
+	result := lists[0]
+	for _, list := range lists[1:] {
+		result = intersect(result, list)
+		if len(result) == 0 {
+			return nil
+		}
+	}
+	return result
+}
+
+func intersect(a []uint32, b []uint32) []uint32 {
+	result := []uint32{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
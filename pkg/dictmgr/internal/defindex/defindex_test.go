@@ -0,0 +1,41 @@
+package defindex
+
+import (
+	"testing"
+
+	common "github.com/ilius/go-dict-commons"
+	"github.com/ilius/is/v2"
+)
+
+func TestTokenize(t *testing.T) {
+	is := is.New(t)
+	is.Equal(Tokenize("Photosynthesis, in plants!"), []string{"photosynthesis", "in", "plants"})
+	is.Equal(Tokenize("  "), []string{})
+	is.Equal(Tokenize("کتاب‌ها"), []string{"کتاب", "ها"})
+}
+
+func TestPlainText(t *testing.T) {
+	is := is.New(t)
+	is.Equal(PlainText(&common.SearchResultItem{
+		Type: 'h',
+		Data: []byte("<b>light</b>&amp;dark"),
+	}), " light &dark")
+	is.Equal(PlainText(&common.SearchResultItem{
+		Type: 'm',
+		Data: []byte("<b>light</b>"),
+	}), "<b>light</b>")
+}
+
+func TestIndexSearch(t *testing.T) {
+	is := is.New(t)
+	idx := &Index{Tokens: map[string][]uint32{
+		"green":  {1, 3, 5, 8},
+		"plants": {0, 3, 8, 9},
+		"light":  {3, 4, 8},
+	}}
+	is.Equal(idx.Search("plants"), []uint32{0, 3, 8, 9})
+	is.Equal(idx.Search("Green plants"), []uint32{3, 8})
+	is.Equal(idx.Search("green plants light"), []uint32{3, 8})
+	is.Equal(len(idx.Search("green plants animals")), 0)
+	is.Equal(len(idx.Search("...")), 0)
+}
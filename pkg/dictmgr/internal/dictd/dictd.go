@@ -0,0 +1,408 @@
+// Package dictd implements common.Dictionary for dictd dictionaries
+// (like FreeDict and WordNet): a .index file and a .dict or .dict.dz
+// (dictzip) file next to it.
+// Name and description are taken from 00-database-short and
+// 00-database-info entries, which are not searched
+package dictd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/termsearch"
+	common "github.com/ilius/go-dict-commons"
+	"golang.org/x/text/encoding/charmap"
+)
+
+const base64Chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// cross references in definitions, like {word}
+var refRE = regexp.MustCompile(`\{([^{}]+)\}`)
+
+type entry struct {
+	terms  []string
+	offset uint64
+	length uint64
+}
+
+// dictionaryImp is a dictd dictionary, index is kept in memory and
+// definitions are read from .dict(.dz) file when needed
+type dictionaryImp struct {
+	indexPath   string
+	dataPath    string
+	name        string
+	description string
+	// dictionary has 00-database-utf8 entry
+	utf8 bool
+
+	mu       sync.RWMutex
+	disabled bool
+	data     dataReader
+	entries  []*entry
+}
+
+var _ common.Dictionary = (*dictionaryImp)(nil)
+
+// NewDictionary reads name and description of dictionary from its
+// 00-database-short and 00-database-info entries
+func NewDictionary(indexPath string, dataPath string) (*dictionaryImp, error) {
+	d := &dictionaryImp{
+		indexPath: indexPath,
+		dataPath:  dataPath,
+		name:      strings.TrimSuffix(filepath.Base(indexPath), ".index"),
+	}
+	_, infoEntries, err := readIndex(indexPath, true)
+	if err != nil {
+		return nil, err
+	}
+	_, d.utf8 = infoEntries["utf8"]
+	if infoEntries["short"] == nil && infoEntries["info"] == nil {
+		return d, nil
+	}
+	data, err := openData(dataPath)
+	if err != nil {
+		return nil, err
+	}
+	defer data.Close()
+	infoText := func(name string) string {
+		e := infoEntries[name]
+		if e == nil {
+			return ""
+		}
+		raw, err := data.read(e.offset, e.length)
+		if err != nil {
+			slog.Error("error reading info entry", "err", err, "name", name, "path", dataPath)
+			return ""
+		}
+		text := decodeText(raw, d.utf8)
+		// definition may start with the headword line
+		first, rest, _ := strings.Cut(text, "\n")
+		if isInfoEntry(strings.TrimSpace(first)) {
+			text = rest
+		}
+		return strings.TrimSpace(text)
+	}
+	if name := infoText("short"); name != "" {
+		d.name = name
+	}
+	d.description = infoText("info")
+	return d, nil
+}
+
+// decodeBase64Number decodes numbers of .index file, which are in
+// base64 digits
+func decodeBase64Number(str string) (uint64, error) {
+	var n uint64
+	for i := 0; i < len(str); i++ {
+		digit := strings.IndexByte(base64Chars, str[i])
+		if digit < 0 {
+			return 0, fmt.Errorf("invalid base64 number %#v", str)
+		}
+		n = n*64 + uint64(digit)
+	}
+	return n, nil
+}
+
+// isInfoEntry returns true for 00-database-* entries, or 00database*
+// in files made by old versions of dictfmt
+func isInfoEntry(term string) bool {
+	return strings.HasPrefix(term, "00-database-") || strings.HasPrefix(term, "00database")
+}
+
+// readIndex reads .index file, entries with the same definition are
+// merged. info entries are returned by their name without prefix.
+// Only info entries are read if infoOnly is true
+func readIndex(path string, infoOnly bool) ([]*entry, map[string]*entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	entries := []*entry{}
+	infoEntries := map[string]*entry{}
+	type location struct{ offset, length uint64 }
+	byLocation := map[location]*entry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		parts := strings.Split(strings.TrimRight(scanner.Text(), "\r"), "\t")
+		if len(parts) < 3 {
+			continue
+		}
+		term := parts[0]
+		if infoOnly && !isInfoEntry(term) {
+			continue
+		}
+		offset, err := decodeBase64Number(parts[1])
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		length, err := decodeBase64Number(parts[2])
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		if isInfoEntry(term) {
+			name := strings.TrimPrefix(strings.TrimPrefix(term, "00-database-"), "00database")
+			infoEntries[name] = &entry{offset: offset, length: length}
+			continue
+		}
+		loc := location{offset, length}
+		if e := byLocation[loc]; e != nil {
+			e.terms = append(e.terms, term)
+			continue
+		}
+		e := &entry{terms: []string{term}, offset: offset, length: length}
+		byLocation[loc] = e
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return entries, infoEntries, nil
+}
+
+func (d *dictionaryImp) Disabled() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.disabled
+}
+
+func (d *dictionaryImp) SetDisabled(disabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.disabled = disabled
+}
+
+func (d *dictionaryImp) Loaded() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.data != nil
+}
+
+// Load reads .index file and opens .dict(.dz) file
+func (d *dictionaryImp) Load() error {
+	entries, _, err := readIndex(d.indexPath, false)
+	if err != nil {
+		return err
+	}
+	data, err := openData(d.dataPath)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.data != nil {
+		d.data.Close()
+	}
+	d.data = data
+	d.entries = entries
+	return nil
+}
+
+// decodeText decodes definition, which is in UTF-8 if dictionary has
+// 00-database-utf8 entry, and in Latin-1 otherwise (unless it's valid
+// UTF-8, as many dictionaries do not have that entry)
+func decodeText(data []byte, isUTF8 bool) string {
+	if isUTF8 || utf8.Valid(data) {
+		return string(data)
+	}
+	text, err := charmap.ISO8859_1.NewDecoder().Bytes(data)
+	if err != nil {
+		return string(data)
+	}
+	return string(text)
+}
+
+func (d *dictionaryImp) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.data == nil {
+		return
+	}
+	err := d.data.Close()
+	if err != nil {
+		slog.Error("error closing file", "err", err, "path", d.dataPath)
+	}
+	d.data = nil
+	d.entries = nil
+}
+
+func (d *dictionaryImp) DictName() string {
+	return d.name
+}
+
+func (d *dictionaryImp) EntryCount() (int, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.data == nil {
+		return 0, errors.New("dictionary is not loaded")
+	}
+	return len(d.entries), nil
+}
+
+func (d *dictionaryImp) Description() string {
+	return d.description
+}
+
+func (d *dictionaryImp) ResourceDir() string {
+	return ""
+}
+
+func (d *dictionaryImp) ResourceURL() string {
+	return ""
+}
+
+func (d *dictionaryImp) IndexPath() string {
+	return d.indexPath
+}
+
+func (d *dictionaryImp) IndexFileSize() uint64 {
+	fi, err := os.Stat(d.indexPath)
+	if err != nil {
+		return 0
+	}
+	return uint64(fi.Size())
+}
+
+func (d *dictionaryImp) InfoPath() string {
+	return ""
+}
+
+func (d *dictionaryImp) CalcHash() ([]byte, error) {
+	file, err := os.Open(d.indexPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	hash := sha1.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// definitionHTML converts plain text definition to HTML, keeping its
+// layout, with links for cross references like {word}
+func definitionHTML(text string) string {
+	text = html.EscapeString(strings.TrimRight(text, "\n"))
+	text = refRE.ReplaceAllStringFunc(text, func(match string) string {
+		word := strings.Join(strings.Fields(match[1:len(match)-1]), " ")
+		return `<a href="bword://` + word + `">` + word + "</a>"
+	})
+	return `<pre style="white-space:pre-wrap">` + text + "</pre>"
+}
+
+// items reads definition of entry
+func (d *dictionaryImp) items(e *entry) []*common.SearchResultItem {
+	d.mu.RLock()
+	data := d.data
+	d.mu.RUnlock()
+	if data == nil {
+		return nil
+	}
+	raw, err := data.read(e.offset, e.length)
+	if err != nil {
+		slog.Error("error reading definition", "err", err, "path", d.dataPath)
+		return nil
+	}
+	text := decodeText(bytes.TrimRight(raw, "\x00"), d.utf8)
+	return []*common.SearchResultItem{{
+		Type: 'h',
+		Data: []byte(definitionHTML(text)),
+	}}
+}
+
+// entryList implements termsearch.Entries
+type entryList struct {
+	d       *dictionaryImp
+	entries []*entry
+}
+
+func (l entryList) Len() int {
+	return len(l.entries)
+}
+
+func (l entryList) Terms(index int) []string {
+	return l.entries[index].terms
+}
+
+func (l entryList) Result(index int, score uint8) *common.SearchResultLow {
+	e := l.entries[index]
+	return &common.SearchResultLow{
+		F_Score: score,
+		F_Terms: e.terms,
+		Items: func() []*common.SearchResultItem {
+			return l.d.items(e)
+		},
+		F_EntryIndex: uint64(index),
+	}
+}
+
+func (d *dictionaryImp) entryList() entryList {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return entryList{d: d, entries: d.entries}
+}
+
+func (d *dictionaryImp) EntryByIndex(index int) *common.SearchResultLow {
+	list := d.entryList()
+	if index < 0 || index >= list.Len() {
+		return nil
+	}
+	return list.Result(index, 0)
+}
+
+func (d *dictionaryImp) SearchFuzzy(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	return termsearch.Fuzzy(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchStartWith(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	return termsearch.StartWith(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchWordMatch(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	return termsearch.WordMatch(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchRegex(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, error) {
+	return termsearch.Regex(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchGlob(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, error) {
+	return termsearch.Glob(d.entryList(), query, workerCount, timeout)
+}
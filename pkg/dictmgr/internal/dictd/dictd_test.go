@@ -0,0 +1,160 @@
+package dictd
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+func encodeBase64Number(n uint64) string {
+	if n == 0 {
+		return "A"
+	}
+	var digits []byte
+	for ; n > 0; n /= 64 {
+		digits = append([]byte{base64Chars[n%64]}, digits...)
+	}
+	return string(digits)
+}
+
+func TestDecodeBase64Number(t *testing.T) {
+	is := is.New(t)
+	for _, n := range []uint64{0, 1, 63, 64, 4095, 123456789} {
+		decoded, err := decodeBase64Number(encodeBase64Number(n))
+		is.NotErr(err)
+		is.Equal(decoded, n)
+	}
+	_, err := decodeBase64Number("a-b")
+	is.Err(err)
+}
+
+type testEntry struct {
+	terms []string
+	defi  string
+}
+
+// writeTestDict writes .index and .dict data of entries
+func writeTestDict(t *testing.T, indexPath string, entries ...testEntry) []byte {
+	t.Helper()
+	var data, index bytes.Buffer
+	for _, e := range entries {
+		offset := encodeBase64Number(uint64(data.Len()))
+		length := encodeBase64Number(uint64(len(e.defi)))
+		for _, term := range e.terms {
+			index.WriteString(term + "\t" + offset + "\t" + length + "\n")
+		}
+		data.WriteString(e.defi)
+	}
+	err := os.WriteFile(indexPath, index.Bytes(), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data.Bytes()
+}
+
+// dictzip compresses data in chunks of chunkLen bytes
+func dictzip(t *testing.T, data []byte, chunkLen int) []byte {
+	t.Helper()
+	var chunks bytes.Buffer
+	sizes := []int{}
+	for start := 0; start < len(data); start += chunkLen {
+		var buf bytes.Buffer
+		writer, err := flate.NewWriter(&buf, flate.BestCompression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer.Write(data[start:min(start+chunkLen, len(data))])
+		writer.Flush()
+		sizes = append(sizes, buf.Len())
+		chunks.Write(buf.Bytes())
+	}
+	// final empty block
+	chunks.Write([]byte{3, 0})
+
+	chunkInfo := binary.LittleEndian.AppendUint16(nil, 1)
+	chunkInfo = binary.LittleEndian.AppendUint16(chunkInfo, uint16(chunkLen))
+	chunkInfo = binary.LittleEndian.AppendUint16(chunkInfo, uint16(len(sizes)))
+	for _, size := range sizes {
+		chunkInfo = binary.LittleEndian.AppendUint16(chunkInfo, uint16(size))
+	}
+	extra := append([]byte{'R', 'A'}, binary.LittleEndian.AppendUint16(nil, uint16(len(chunkInfo)))...)
+	extra = append(extra, chunkInfo...)
+
+	out := []byte{0x1f, 0x8b, 8, gzipFlagExtra | gzipFlagName, 0, 0, 0, 0, 2, 3}
+	out = binary.LittleEndian.AppendUint16(out, uint16(len(extra)))
+	out = append(out, extra...)
+	out = append(out, "test.dict\x00"...)
+	out = append(out, chunks.Bytes()...)
+	out = binary.LittleEndian.AppendUint32(out, crc32.ChecksumIEEE(data))
+	return binary.LittleEndian.AppendUint32(out, uint32(len(data)))
+}
+
+func TestOpen(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	data := writeTestDict(
+		t, filepath.Join(dir, "fd-eng-fra.index"),
+		testEntry{[]string{"00-database-info"}, "00-database-info\n  English-French FreeDict Dictionary\n  version 0.1\n"},
+		testEntry{[]string{"00-database-short"}, "00-database-short\n     English-French FreeDict\n"},
+		testEntry{[]string{"00-database-utf8"}, "\n"},
+		testEntry{[]string{"cat", "cats"}, "cat /kæt/\n1. chat\n   see also {kitten}\n"},
+		testEntry{[]string{"dog"}, "dog\nchien <m>\n"},
+		testEntry{[]string{"kitten"}, "kitten\n" + strings.Repeat("chaton ", 10) + "\n"},
+	)
+	err := os.WriteFile(filepath.Join(dir, "fd-eng-fra.dict.dz"), dictzip(t, data, 16), 0o644)
+	is.NotErr(err)
+
+	err = os.MkdirAll(filepath.Join(dir, "sub"), 0o755)
+	is.NotErr(err)
+	data = writeTestDict(
+		t, filepath.Join(dir, "sub", "other.index"),
+		testEntry{[]string{"caf\xe9"}, "caf\xe9\ncoffee\n"},
+	)
+	err = os.WriteFile(filepath.Join(dir, "sub", "other.dict"), data, 0o644)
+	is.NotErr(err)
+	// no .dict file
+	err = os.WriteFile(filepath.Join(dir, "missing.index"), []byte("a\tA\tB\n"), 0o644)
+	is.NotErr(err)
+
+	dicList := Open([]string{dir}, map[string]int{"other": -1})
+	is.Equal(len(dicList), 2)
+
+	dic := dicList[0]
+	is.Equal(dic.DictName(), "English-French FreeDict")
+	is.Equal(dic.Description(), "English-French FreeDict Dictionary\n  version 0.1")
+	is.True(dic.Loaded())
+	count, err := dic.EntryCount()
+	is.NotErr(err)
+	is.Equal(count, 3)
+
+	results := dic.SearchStartWith("cats", 1, 0)
+	is.Equal(len(results), 1)
+	is.Equal(results[0].F_Terms, []string{"cat", "cats"})
+	items := results[0].Items()
+	is.Equal(len(items), 1)
+	is.Equal(items[0].Type, 'h')
+	is.Equal(string(items[0].Data), `<pre style="white-space:pre-wrap">cat /kæt/`+"\n1. chat\n"+
+		`   see also <a href="bword://kitten">kitten</a></pre>`)
+	is.Equal(string(dic.EntryByIndex(1).Items()[0].Data), `<pre style="white-space:pre-wrap">dog`+"\nchien &lt;m&gt;</pre>")
+	is.Equal(string(dic.EntryByIndex(2).Items()[0].Data), `<pre style="white-space:pre-wrap">kitten`+"\n"+
+		strings.TrimSpace(strings.Repeat("chaton ", 10))+" </pre>")
+
+	other := dicList[1]
+	is.Equal(other.DictName(), "other")
+	is.Equal(other.Description(), "")
+	is.True(other.Disabled())
+	is.False(other.Loaded())
+	is.NotErr(other.Load())
+	entry := other.EntryByIndex(0)
+	is.Equal(entry.F_Terms, []string{"caf\xe9"})
+	is.Equal(string(entry.Items()[0].Data), `<pre style="white-space:pre-wrap">café`+"\ncoffee</pre>")
+	other.Close()
+	is.False(other.Loaded())
+}
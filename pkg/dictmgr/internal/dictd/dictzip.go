@@ -0,0 +1,238 @@
+package dictd
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// dataReader reads parts of .dict or .dict.dz file
+type dataReader interface {
+	read(offset uint64, length uint64) ([]byte, error)
+	Close() error
+}
+
+// openData opens .dict file, or .dict.dz file with random access if it
+// has dictzip chunk info, otherwise decompresses it all in memory
+func openData(path string) (dataReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(strings.ToLower(path), ".dz") {
+		return &plainReader{file: file}, nil
+	}
+	dz, err := newDictzipReader(file)
+	if err == nil {
+		return dz, nil
+	}
+	if err != errNoChunkInfo {
+		file.Close()
+		return nil, err
+	}
+	// a normal gzip file
+	defer file.Close()
+	gzReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer gzReader.Close()
+	data, err := io.ReadAll(gzReader)
+	if err != nil {
+		return nil, err
+	}
+	return memoryReader(data), nil
+}
+
+type plainReader struct {
+	file *os.File
+}
+
+func (r *plainReader) read(offset uint64, length uint64) ([]byte, error) {
+	buf := make([]byte, length)
+	n, err := r.file.ReadAt(buf, int64(offset))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+func (r *plainReader) Close() error {
+	return r.file.Close()
+}
+
+type memoryReader []byte
+
+func (r memoryReader) read(offset uint64, length uint64) ([]byte, error) {
+	if offset > uint64(len(r)) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	end := min(offset+length, uint64(len(r)))
+	return r[offset:end], nil
+}
+
+func (memoryReader) Close() error {
+	return nil
+}
+
+var errNoChunkInfo = errors.New("no dictzip chunk info")
+
+// gzip header flags
+const (
+	gzipFlagHCRC    = 1 << 1
+	gzipFlagExtra   = 1 << 2
+	gzipFlagName    = 1 << 3
+	gzipFlagComment = 1 << 4
+)
+
+// dictzipReader reads dictzip files, which are gzip files whose data
+// is compressed in chunks that can be decompressed separately, with
+// sizes of chunks in "RA" field of gzip header
+type dictzipReader struct {
+	file *os.File
+
+	chunkLen uint64
+	// offset of each chunk in file, and end of last chunk
+	chunkOffsets []int64
+
+	// last decompressed chunk
+	cacheMu    sync.Mutex
+	cacheIndex int
+	cacheData  []byte
+}
+
+func newDictzipReader(file *os.File) (*dictzipReader, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return nil, err
+	}
+	if header[0] != 0x1f || header[1] != 0x8b || header[2] != 8 {
+		return nil, errors.New("invalid gzip header")
+	}
+	flags := header[3]
+	if flags&gzipFlagExtra == 0 {
+		return nil, errNoChunkInfo
+	}
+	pos := int64(10)
+	readAt := func(size int) ([]byte, error) {
+		buf := make([]byte, size)
+		if _, err := file.ReadAt(buf, pos); err != nil {
+			return nil, err
+		}
+		pos += int64(size)
+		return buf, nil
+	}
+	xlenBytes, err := readAt(2)
+	if err != nil {
+		return nil, err
+	}
+	extra, err := readAt(int(binary.LittleEndian.Uint16(xlenBytes)))
+	if err != nil {
+		return nil, err
+	}
+	var chunkInfo []byte
+	for len(extra) >= 4 {
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if 4+size > len(extra) {
+			break
+		}
+		if extra[0] == 'R' && extra[1] == 'A' {
+			chunkInfo = extra[4 : 4+size]
+			break
+		}
+		extra = extra[4+size:]
+	}
+	// version, chunk length, chunk count and sizes of chunks
+	if len(chunkInfo) < 6 {
+		return nil, errNoChunkInfo
+	}
+	chunkCount := int(binary.LittleEndian.Uint16(chunkInfo[4:6]))
+	if len(chunkInfo) < 6+chunkCount*2 {
+		return nil, errors.New("invalid dictzip chunk info")
+	}
+	for _, flag := range []byte{gzipFlagName, gzipFlagComment} {
+		if flags&flag == 0 {
+			continue
+		}
+		// skip null-terminated string
+		for {
+			b, err := readAt(1)
+			if err != nil {
+				return nil, err
+			}
+			if b[0] == 0 {
+				break
+			}
+		}
+	}
+	if flags&gzipFlagHCRC != 0 {
+		pos += 2
+	}
+	r := &dictzipReader{
+		file:         file,
+		chunkLen:     uint64(binary.LittleEndian.Uint16(chunkInfo[2:4])),
+		chunkOffsets: make([]int64, chunkCount+1),
+		cacheIndex:   -1,
+	}
+	if r.chunkLen == 0 {
+		return nil, errors.New("invalid dictzip chunk length")
+	}
+	r.chunkOffsets[0] = pos
+	for i := range chunkCount {
+		size := int64(binary.LittleEndian.Uint16(chunkInfo[6+i*2:]))
+		r.chunkOffsets[i+1] = r.chunkOffsets[i] + size
+	}
+	return r, nil
+}
+
+func (r *dictzipReader) chunk(index int) ([]byte, error) {
+	r.cacheMu.Lock()
+	defer r.cacheMu.Unlock()
+	if r.cacheIndex == index {
+		return r.cacheData, nil
+	}
+	start, end := r.chunkOffsets[index], r.chunkOffsets[index+1]
+	compData := make([]byte, end-start)
+	if _, err := r.file.ReadAt(compData, start); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(flate.NewReader(bytes.NewReader(compData)))
+	// chunks end with a flush, not the end of deflate stream
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	r.cacheIndex = index
+	r.cacheData = data
+	return data, nil
+}
+
+func (r *dictzipReader) read(offset uint64, length uint64) ([]byte, error) {
+	out := make([]byte, 0, length)
+	end := offset + length
+	chunkCount := uint64(len(r.chunkOffsets) - 1)
+	for index := offset / r.chunkLen; index < chunkCount && offset < end; index++ {
+		data, err := r.chunk(int(index))
+		if err != nil {
+			return nil, err
+		}
+		chunkStart := index * r.chunkLen
+		from := offset - chunkStart
+		to := min(end-chunkStart, uint64(len(data)))
+		if from >= to {
+			break
+		}
+		out = append(out, data[from:to]...)
+		offset = chunkStart + to
+	}
+	return out, nil
+}
+
+func (r *dictzipReader) Close() error {
+	return r.file.Close()
+}
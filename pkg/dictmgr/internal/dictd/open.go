@@ -0,0 +1,52 @@
+package dictd
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dictfiles"
+	common "github.com/ilius/go-dict-commons"
+)
+
+func isIndexFile(name string) bool {
+	return strings.HasSuffix(name, ".index")
+}
+
+// dataPath returns path of .dict.dz or .dict file of an .index file,
+// or empty string if there is none
+func dataPath(indexPath string) string {
+	base := strings.TrimSuffix(indexPath, ".index")
+	for _, fpath := range []string{base + ".dict.dz", base + ".dict"} {
+		if _, err := os.Stat(fpath); err == nil {
+			return fpath
+		}
+	}
+	return ""
+}
+
+// Open finds dictd dictionaries in given paths, each of them can be
+// an .index file, or a directory which is searched for .index files
+// (also in its direct sub-directories).
+// Relative paths are relative to home directory, like stardict.Open
+func Open(pathList []string, order map[string]int) []common.Dictionary {
+	var dicList []common.Dictionary
+	for _, indexPath := range dictfiles.FindAll(pathList, isIndexFile) {
+		dictPath := dataPath(indexPath)
+		if dictPath == "" {
+			slog.Debug("no .dict or .dict.dz file for .index file", "path", indexPath)
+			continue
+		}
+		dic, err := NewDictionary(indexPath, dictPath)
+		if err != nil {
+			slog.Error("error opening dictd dictionary", "err", err, "path", indexPath)
+			continue
+		}
+		if order[dic.DictName()] < 0 {
+			dic.disabled = true
+		}
+		dicList = append(dicList, dic)
+	}
+	dictfiles.LoadAll(dicList, "dictd")
+	return dicList
+}
@@ -0,0 +1,239 @@
+// Package dictfiles has helpers shared by loaders of dictionary formats
+// other than StarDict: finding dictionary files, loading dictionaries
+// in parallel, and extracting their resources to cache directory
+package dictfiles
+
+import (
+	"html"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ilius/ayandict/v2/pkg/config"
+	common "github.com/ilius/go-dict-commons"
+)
+
+// ResCacheDir is the directory that resources of dictionaries are
+// extracted to, in a sub-directory for each format and dictionary
+var ResCacheDir = filepath.Join(config.GetCacheDir(), "dict-res")
+
+// AbsPath returns path relative to home directory if it's not absolute,
+// like stardict.Open does with directory_list
+func AbsPath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		panic(err)
+	}
+	return filepath.Join(homeDir, path)
+}
+
+// Find returns path if it's a matching file, or files in it (and its direct
+// sub-directories) whose name matches, if it's a directory.
+// Relative path is relative to home directory
+func Find(path string, match func(name string) bool) []string {
+	path = AbsPath(path)
+	fi, err := os.Stat(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Error("error", "err", err, "path", path)
+		}
+		return nil
+	}
+	if !fi.IsDir() {
+		if !match(fi.Name()) {
+			return nil
+		}
+		return []string{path}
+	}
+	dirEntries, err := os.ReadDir(path)
+	if err != nil {
+		slog.Error("error reading directory", "err", err, "path", path)
+		return nil
+	}
+	files := []string{}
+	for _, de := range dirEntries {
+		if !de.IsDir() {
+			if match(de.Name()) {
+				files = append(files, filepath.Join(path, de.Name()))
+			}
+			continue
+		}
+		subDir := filepath.Join(path, de.Name())
+		subEntries, err := os.ReadDir(subDir)
+		if err != nil {
+			slog.Error("error reading directory", "err", err, "path", subDir)
+			continue
+		}
+		for _, sub := range subEntries {
+			if !sub.IsDir() && match(sub.Name()) {
+				files = append(files, filepath.Join(subDir, sub.Name()))
+			}
+		}
+	}
+	return files
+}
+
+// FindAll is like Find for a list of paths
+func FindAll(pathList []string, match func(name string) bool) []string {
+	files := []string{}
+	for _, path := range pathList {
+		files = append(files, Find(path, match)...)
+	}
+	return files
+}
+
+// LoadAll loads dictionaries that are not disabled, in parallel
+func LoadAll(dicList []common.Dictionary, format string) {
+	var wg sync.WaitGroup
+	for _, dic := range dicList {
+		if dic.Disabled() {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t0 := time.Now()
+			err := dic.Load()
+			if err != nil {
+				slog.Error("error loading "+format+" dictionary", "err", err, "dictName", dic.DictName())
+				return
+			}
+			slog.Info("Loaded "+format+" dictionary", "path", dic.IndexPath(), "dt", time.Since(t0))
+		}()
+	}
+	wg.Wait()
+}
+
+// ResourceDir returns the directory to extract resources of dictionary
+func ResourceDir(format string, dictName string) string {
+	return filepath.Join(ResCacheDir, format, safeFileName(dictName))
+}
+
+func safeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// resourcePath returns path of a resource file in resDir, or empty
+// string if relPath points outside resDir
+func resourcePath(resDir string, relPath string) string {
+	fpath := filepath.Join(resDir, filepath.FromSlash(relPath))
+	if !strings.HasPrefix(fpath, resDir+string(filepath.Separator)) {
+		return ""
+	}
+	return fpath
+}
+
+func writeFile(fpath string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(fpath), 0o755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fpath, data, 0o644)
+}
+
+// WriteResource writes a resource file into resDir, unless a file with
+// the same size exists. Returns false if relPath points outside resDir
+func WriteResource(resDir string, relPath string, data []byte) (bool, error) {
+	fpath := resourcePath(resDir, relPath)
+	if fpath == "" {
+		return false, nil
+	}
+	if fi, err := os.Stat(fpath); err == nil && fi.Size() == int64(len(data)) {
+		return true, nil
+	}
+	return true, writeFile(fpath, data)
+}
+
+// ExtractResource writes a resource file into resDir and sets its
+// modification time, unless a file with the same size and modification
+// time exists, in which case read is not called.
+// Returns false if relPath points outside resDir
+func ExtractResource(
+	resDir string,
+	relPath string,
+	size int64,
+	modTime time.Time,
+	read func() ([]byte, error),
+) (bool, error) {
+	fpath := resourcePath(resDir, relPath)
+	if fpath == "" {
+		return false, nil
+	}
+	modTime = modTime.Truncate(time.Second)
+	if fi, err := os.Stat(fpath); err == nil && fi.Size() == size &&
+		fi.ModTime().Truncate(time.Second).Equal(modTime) {
+		return true, nil
+	}
+	data, err := read()
+	if err != nil {
+		return true, err
+	}
+	err = writeFile(fpath, data)
+	if err != nil {
+		return true, err
+	}
+	return true, os.Chtimes(fpath, modTime, modTime)
+}
+
+// FileURL returns file:// URL of a local directory, for ResourceURL
+func FileURL(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	if runtime.GOOS != "windows" {
+		return "file://" + dir
+	}
+	return "file:///" + strings.ReplaceAll(dir, `\`, `/`)
+}
+
+var audioExts = map[string]bool{
+	".wav":  true,
+	".mp3":  true,
+	".ogg":  true,
+	".oga":  true,
+	".m4a":  true,
+	".spx":  true,
+	".flac": true,
+}
+
+var imageExts = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".bmp":  true,
+	".webp": true,
+	".svg":  true,
+	".tif":  true,
+	".tiff": true,
+}
+
+// ResourceHTML returns HTML for a resource file that is referenced in
+// an article: <a href="sound://file.wav"></a> for audio files (like
+// StarDict), <img src="file.png"/> for images, and a link otherwise.
+// These are fixed by DictProcessor.FixDefiHTML
+func ResourceHTML(relPath string) string {
+	escaped := html.EscapeString(relPath)
+	ext := strings.ToLower(path.Ext(relPath))
+	switch {
+	case audioExts[ext]:
+		return `<a href="sound://` + escaped + `"></a>`
+	case imageExts[ext]:
+		return `<img src="` + escaped + `"/>`
+	}
+	return `<a href="` + escaped + `">` + escaped + "</a>"
+}
@@ -1,6 +1,9 @@
 package dicts
 
 import (
+	"slices"
+	"time"
+
 	common "github.com/ilius/go-dict-commons"
 )
 
@@ -10,6 +13,10 @@ const (
 	FlagNoRegex
 	FlagNoGlob
 	FlagNoWordMatch
+	FlagNoDefinition
+	FlagNoExact
+	FlagNoEndsWith
+	FlagNoContains
 )
 
 type DictionarySettings struct {
@@ -22,6 +29,35 @@ type DictionarySettings struct {
 	HideTermsHeader bool `json:"hide_terms_header,omitempty"`
 
 	AudioVolume int `json:"audio_volume,omitempty"`
+
+	// Weight is multiplied by score of results in weighted scoring model
+	// zero means default weight (1)
+	Weight float64 `json:"weight,omitempty"`
+
+	// SourceLang and TargetLang are language codes (like "en" or "fa")
+	// of headwords and definitions, guessed when dictionary is found
+	SourceLang string `json:"source_lang,omitempty"`
+	TargetLang string `json:"target_lang,omitempty"`
+
+	// Normalization overrides config.Normalization for this dictionary
+	// if not empty, use ["none"] to disable normalization
+	Normalization []string `json:"normalization,omitempty"`
+
+	// MaxResults, SearchTimeoutMs and SearchWorkerCount override
+	// max_results_total, search_timeout and search_worker_count in config
+	// for this dictionary, zero means use config
+	// SearchWorkerCount=1 searches the whole dictionary with no timeout
+	MaxResults        int `json:"max_results,omitempty"`
+	SearchTimeoutMs   int `json:"search_timeout_ms,omitempty"`
+	SearchWorkerCount int `json:"search_worker_count,omitempty"`
+}
+
+// Clone returns a copy of settings to be modified and then set with
+// SetSettings, since settings may be read by lookups at the same time
+func (ds *DictionarySettings) Clone() *DictionarySettings {
+	clone := *ds
+	clone.Normalization = slices.Clone(ds.Normalization)
+	return &clone
 }
 
 func (ds *DictionarySettings) Fuzzy() bool {
@@ -44,6 +80,46 @@ func (ds *DictionarySettings) WordMatch() bool {
 	return ds.Flags&FlagNoWordMatch == 0
 }
 
+func (ds *DictionarySettings) Definition() bool {
+	return ds.Flags&FlagNoDefinition == 0
+}
+
+func (ds *DictionarySettings) Exact() bool {
+	return ds.Flags&FlagNoExact == 0
+}
+
+func (ds *DictionarySettings) EndsWith() bool {
+	return ds.Flags&FlagNoEndsWith == 0
+}
+
+func (ds *DictionarySettings) Contains() bool {
+	return ds.Flags&FlagNoContains == 0
+}
+
+// EffectiveWeight returns Weight, or 1 if it's not set
+func (ds *DictionarySettings) EffectiveWeight() float64 {
+	if ds.Weight <= 0 {
+		return 1
+	}
+	return ds.Weight
+}
+
+// EffectiveSearchTimeout returns SearchTimeoutMs as duration, or def if it's not set
+func (ds *DictionarySettings) EffectiveSearchTimeout(def time.Duration) time.Duration {
+	if ds.SearchTimeoutMs <= 0 {
+		return def
+	}
+	return time.Duration(ds.SearchTimeoutMs) * time.Millisecond
+}
+
+// EffectiveSearchWorkerCount returns SearchWorkerCount, or def if it's not set
+func (ds *DictionarySettings) EffectiveSearchWorkerCount(def int) int {
+	if ds.SearchWorkerCount <= 0 {
+		return def
+	}
+	return ds.SearchWorkerCount
+}
+
 func (ds *DictionarySettings) SetFuzzy(enable bool) {
 	if enable {
 		ds.Flags &= ^FlagNoFuzzy
@@ -76,6 +152,46 @@ func (ds *DictionarySettings) SetGlob(enable bool) {
 	}
 }
 
+func (ds *DictionarySettings) SetWordMatch(enable bool) {
+	if enable {
+		ds.Flags &= ^FlagNoWordMatch
+	} else {
+		ds.Flags |= FlagNoWordMatch
+	}
+}
+
+func (ds *DictionarySettings) SetDefinition(enable bool) {
+	if enable {
+		ds.Flags &= ^FlagNoDefinition
+	} else {
+		ds.Flags |= FlagNoDefinition
+	}
+}
+
+func (ds *DictionarySettings) SetExact(enable bool) {
+	if enable {
+		ds.Flags &= ^FlagNoExact
+	} else {
+		ds.Flags |= FlagNoExact
+	}
+}
+
+func (ds *DictionarySettings) SetEndsWith(enable bool) {
+	if enable {
+		ds.Flags &= ^FlagNoEndsWith
+	} else {
+		ds.Flags |= FlagNoEndsWith
+	}
+}
+
+func (ds *DictionarySettings) SetContains(enable bool) {
+	if enable {
+		ds.Flags &= ^FlagNoContains
+	} else {
+		ds.Flags |= FlagNoContains
+	}
+}
+
 func NewDictSettings(dic common.Dictionary, index int) *DictionarySettings {
 	return &DictionarySettings{
 		Symbol: common.DefaultSymbol(dic.DictName()),
@@ -2,6 +2,7 @@ package dicts
 
 import (
 	"testing"
+	"time"
 
 	"github.com/ilius/is/v2"
 )
@@ -78,6 +79,104 @@ func TestDictSettingsGlob(t *testing.T) {
 	}
 }
 
+func TestDictSettingsWordMatch(t *testing.T) {
+	is := is.New(t)
+	{
+		ds := &DictionarySettings{}
+		is.True(ds.WordMatch())
+	}
+	{
+		ds := &DictionarySettings{}
+		ds.SetWordMatch(true)
+		is.True(ds.WordMatch())
+	}
+	{
+		ds := &DictionarySettings{}
+		ds.SetWordMatch(false)
+		is.False(ds.WordMatch())
+	}
+}
+
+func TestDictSettingsDefinition(t *testing.T) {
+	is := is.New(t)
+	{
+		ds := &DictionarySettings{}
+		is.True(ds.Definition())
+	}
+	{
+		ds := &DictionarySettings{}
+		ds.SetDefinition(true)
+		is.True(ds.Definition())
+	}
+	{
+		ds := &DictionarySettings{}
+		ds.SetDefinition(false)
+		is.False(ds.Definition())
+		is.True(ds.WordMatch())
+		is.True(ds.Fuzzy())
+	}
+}
+
+func TestDictSettingsExact(t *testing.T) {
+	is := is.New(t)
+	{
+		ds := &DictionarySettings{}
+		is.True(ds.Exact())
+	}
+	{
+		ds := &DictionarySettings{}
+		ds.SetExact(true)
+		is.True(ds.Exact())
+	}
+	{
+		ds := &DictionarySettings{}
+		ds.SetExact(false)
+		is.False(ds.Exact())
+		is.True(ds.Definition())
+		is.True(ds.Fuzzy())
+	}
+}
+
+func TestDictSettingsEndsWith(t *testing.T) {
+	is := is.New(t)
+	{
+		ds := &DictionarySettings{}
+		is.True(ds.EndsWith())
+	}
+	{
+		ds := &DictionarySettings{}
+		ds.SetEndsWith(true)
+		is.True(ds.EndsWith())
+	}
+	{
+		ds := &DictionarySettings{}
+		ds.SetEndsWith(false)
+		is.False(ds.EndsWith())
+		is.True(ds.Definition())
+		is.True(ds.Fuzzy())
+	}
+}
+
+func TestDictSettingsContains(t *testing.T) {
+	is := is.New(t)
+	{
+		ds := &DictionarySettings{}
+		is.True(ds.Contains())
+	}
+	{
+		ds := &DictionarySettings{}
+		ds.SetContains(true)
+		is.True(ds.Contains())
+	}
+	{
+		ds := &DictionarySettings{}
+		ds.SetContains(false)
+		is.False(ds.Contains())
+		is.True(ds.Definition())
+		is.True(ds.Fuzzy())
+	}
+}
+
 func TestDictSettingsFlagsMixed(t *testing.T) {
 	is := is.New(t)
 	{
@@ -120,3 +219,20 @@ func TestDictSettingsFlagsMixed(t *testing.T) {
 		is.True(ds.Glob())
 	}
 }
+
+func TestDictSettingsSearchOverrides(t *testing.T) {
+	is := is.New(t)
+	{
+		ds := &DictionarySettings{}
+		is.Equal(ds.EffectiveSearchTimeout(5*time.Second), 5*time.Second)
+		is.Equal(ds.EffectiveSearchWorkerCount(8), 8)
+	}
+	{
+		ds := &DictionarySettings{
+			SearchTimeoutMs:   20000,
+			SearchWorkerCount: 2,
+		}
+		is.Equal(ds.EffectiveSearchTimeout(5*time.Second), 20*time.Second)
+		is.Equal(ds.EffectiveSearchWorkerCount(8), 2)
+	}
+}
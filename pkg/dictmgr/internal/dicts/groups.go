@@ -0,0 +1,35 @@
+package dicts
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/ilius/ayandict/v2/pkg/config"
+)
+
+const groupsJsonFilename = "groups.json"
+
+// DictGroup is a named list of dictionaries, in the order they are
+// searched and shown when this group is selected
+type DictGroup struct {
+	Name  string   `json:"name"`
+	Dicts []string `json:"dicts"`
+}
+
+func loadDictGroups() ([]*DictGroup, error) {
+	groups := []*DictGroup{}
+	fpath := filepath.Join(config.GetConfigDir(), groupsJsonFilename)
+	jsonBytes, err := os.ReadFile(fpath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return groups, nil
+		}
+		return groups, err
+	}
+	err = json.Unmarshal(jsonBytes, &groups)
+	if err != nil {
+		return []*DictGroup{}, err
+	}
+	return groups, nil
+}
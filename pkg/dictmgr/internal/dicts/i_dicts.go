@@ -3,12 +3,21 @@ package dicts
 import (
 	"encoding/json"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ilius/ayandict/v2/pkg/config"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dictd"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dsl"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/mdict"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/slob"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/xdxf"
 	"github.com/ilius/ayandict/v2/pkg/qtcommon/qerr"
 	common "github.com/ilius/go-dict-commons"
 	"github.com/ilius/go-stardict/v2"
@@ -16,13 +25,119 @@ import (
 
 const dictsJsonFilename = "dicts.json"
 
-var (
+// State is the list of dictionaries with their order, settings and groups.
+// Slices and maps of State are shared, and must not be modified in place
+type State struct {
 	DictList        []common.Dictionary
-	DictByName      = map[string]common.Dictionary{}
 	DictsOrder      map[string]int
-	DictSettingsMap = map[string]*DictionarySettings{}
+	DictSettingsMap map[string]*DictionarySettings
+	DictGroups      []*DictGroup
+}
+
+var (
+	// mu guards state and dictByName, which are replaced by InitDicts
+	// and Reorder (in GUI thread) while lookups may be running
+	mu         sync.RWMutex
+	state      = State{DictSettingsMap: map[string]*DictionarySettings{}}
+	dictByName = map[string]common.Dictionary{}
 )
 
+// Current returns the current State
+func Current() State {
+	mu.RLock()
+	defer mu.RUnlock()
+	return state
+}
+
+// Replace makes s the current State
+func Replace(s State) {
+	byName := make(map[string]common.Dictionary, len(s.DictList))
+	for _, dic := range s.DictList {
+		byName[dic.DictName()] = dic
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	state = s
+	dictByName = byName
+	generation.Add(1)
+}
+
+// List returns the list of dictionaries, sorted by their order
+func List() []common.Dictionary {
+	mu.RLock()
+	defer mu.RUnlock()
+	return state.DictList
+}
+
+// ByName returns the dictionary with the given name, or nil
+func ByName(dictName string) common.Dictionary {
+	mu.RLock()
+	defer mu.RUnlock()
+	return dictByName[dictName]
+}
+
+// Order returns order of dictionaries by name, negative for disabled ones
+func Order() map[string]int {
+	mu.RLock()
+	defer mu.RUnlock()
+	return state.DictsOrder
+}
+
+// Settings returns settings of the dictionary, or nil
+func Settings(dictName string) *DictionarySettings {
+	mu.RLock()
+	defer mu.RUnlock()
+	return state.DictSettingsMap[dictName]
+}
+
+// SettingsMap returns settings of dictionaries by name
+func SettingsMap() map[string]*DictionarySettings {
+	mu.RLock()
+	defer mu.RUnlock()
+	return state.DictSettingsMap
+}
+
+// SetSettings sets settings of the dictionary, it does not save them
+func SetSettings(dictName string, ds *DictionarySettings) {
+	mu.Lock()
+	defer mu.Unlock()
+	settingsMap := maps.Clone(state.DictSettingsMap)
+	if settingsMap == nil {
+		settingsMap = map[string]*DictionarySettings{}
+	}
+	settingsMap[dictName] = ds
+	state.DictSettingsMap = settingsMap
+	generation.Add(1)
+}
+
+// Groups returns dictionary groups
+func Groups() []*DictGroup {
+	mu.RLock()
+	defer mu.RUnlock()
+	return state.DictGroups
+}
+
+// generation is increased whenever list, order or settings of dictionaries
+// may have changed, so that caches depending on them can be dropped
+var generation atomic.Uint64
+
+// Generation returns a number that changes on InitDicts, Reorder,
+// Replace, SetSettings and SaveDictsSettings
+func Generation() uint64 {
+	return generation.Load()
+}
+
+// openers open dictionaries of formats other than StarDict in the given
+// directories, to support another format, add its Open function here
+var openers = []func(pathList []string, order map[string]int) []common.Dictionary{
+	dsl.Open,
+	mdict.Open,
+	xdxf.Open,
+	dictd.Open,
+	slob.Open,
+}
+
+// sqldictOpen is set to sqldict.Open, unless built with nosqldict tag
 var sqldictOpen = func([]string, map[string]int) []common.Dictionary {
 	return nil
 }
@@ -57,11 +172,18 @@ func (s DictionaryListSorter) Less(i, j int) bool {
 	return absInt(s.Order[s.List[i].DictName()]) < absInt(s.Order[s.List[j].DictName()])
 }
 
+// Reorder sets order of dictionaries and sorts the list by it
 func Reorder(order map[string]int) {
+	mu.Lock()
+	defer mu.Unlock()
+	list := slices.Clone(state.DictList)
 	sort.Sort(DictionaryListSorter{
-		List:  DictList,
+		List:  list,
 		Order: order,
 	})
+	state.DictList = list
+	state.DictsOrder = order
+	generation.Add(1)
 }
 
 func loadDictsSettings() (
@@ -92,7 +214,10 @@ func loadDictsSettings() (
 	return settingsMap, order, nil
 }
 
+// SaveDictsSettings writes settings to file, settings may have been
+// modified in place, so it also invalidates caches (see Generation)
 func SaveDictsSettings(settingsMap map[string]*DictionarySettings) error {
+	generation.Add(1)
 	jsonBytes, err := json.MarshalIndent(settingsMap, "", "\t")
 	if err != nil {
 		return err
@@ -105,9 +230,9 @@ func SaveDictsSettings(settingsMap map[string]*DictionarySettings) error {
 	return nil
 }
 
-func getDictNameByHashMap() map[string][]string {
+func getDictNameByHashMap(settingsMap map[string]*DictionarySettings) map[string][]string {
 	byHash := map[string][]string{}
-	for dictName, ds := range DictSettingsMap {
+	for dictName, ds := range settingsMap {
 		if ds.Hash == "" {
 			continue
 		}
@@ -117,29 +242,34 @@ func getDictNameByHashMap() map[string][]string {
 }
 
 func InitDicts(conf *config.Config) {
-	var err error
-	DictSettingsMap, DictsOrder, err = loadDictsSettings()
+	settingsMap, order, err := loadDictsSettings()
 	if err != nil {
 		slog.Error("error reading dicts.json: " + err.Error())
 	}
+	groups, err := loadDictGroups()
+	if err != nil {
+		slog.Error("error reading groups.json: " + err.Error())
+	}
 
 	t := time.Now()
-	DictList, err = stardict.Open(conf.DirectoryList, DictsOrder)
+	dictList, err := stardict.Open(conf.DirectoryList, order)
 	if err != nil {
 		panic(err)
 	}
 
-	// to support another format, you can call pkg.Open just like stardict
-	// and append them new dicList to this dicList. since we are sorting them
-	// here in Reorder after loading all dictionaries
-
-	for _, dic := range DictList {
-		DictByName[dic.DictName()] = dic
+	// dictionaries of all formats are sorted together below,
+	// after loading all of them
+	for _, open := range openers {
+		dictList = append(dictList, open(conf.DirectoryList, order)...)
 	}
+	dictList = append(dictList, sqldictOpen(
+		slices.Concat(conf.DirectoryList, conf.SqlDictList),
+		order,
+	)...)
 
 	slog.Info("Loaded dictionaries", "dt", time.Since(t))
 
-	nameByHash := getDictNameByHashMap()
+	nameByHash := getDictNameByHashMap(settingsMap)
 
 	newDictSettings := func(dic common.Dictionary, index int) *DictionarySettings {
 		hash := Hash(dic)
@@ -148,8 +278,8 @@ func InitDicts(conf *config.Config) {
 			if len(prevNames) > 0 {
 				slog.Info("init: found renamed dicts:", "prevNames", prevNames)
 				prevName := prevNames[0]
-				ds := DictSettingsMap[prevName]
-				delete(DictSettingsMap, prevName)
+				ds := settingsMap[prevName]
+				delete(settingsMap, prevName)
 				return ds
 			}
 		}
@@ -161,14 +291,14 @@ func InitDicts(conf *config.Config) {
 	}
 
 	modified := false
-	for index, dic := range DictList {
+	for index, dic := range dictList {
 		dictName := dic.DictName()
-		ds := DictSettingsMap[dictName]
+		ds := settingsMap[dictName]
 		if ds == nil {
 			slog.Info("init: found new dict", "dictName", dictName)
 			ds = newDictSettings(dic, index)
-			DictSettingsMap[dictName] = ds
-			DictsOrder[dictName] = ds.Order
+			settingsMap[dictName] = ds
+			order[dictName] = ds.Order
 			modified = true
 			continue
 		}
@@ -181,11 +311,20 @@ func InitDicts(conf *config.Config) {
 		}
 	}
 	if modified {
-		err := SaveDictsSettings(DictSettingsMap)
+		err := SaveDictsSettings(settingsMap)
 		if err != nil {
 			slog.Error("error saving dicts settings: " + err.Error())
 		}
 	}
 
-	Reorder(DictsOrder)
+	sort.Sort(DictionaryListSorter{
+		List:  dictList,
+		Order: order,
+	})
+	Replace(State{
+		DictList:        dictList,
+		DictsOrder:      order,
+		DictSettingsMap: settingsMap,
+		DictGroups:      groups,
+	})
 }
@@ -0,0 +1,9 @@
+//go:build !nosqldict
+
+package dicts
+
+import "github.com/ilius/ayandict/v2/pkg/dictmgr/internal/sqldict"
+
+func init() {
+	sqldictOpen = sqldict.Open
+}
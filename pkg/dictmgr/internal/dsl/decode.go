@@ -0,0 +1,104 @@
+package dsl
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// maximum number of (decompressed) bytes read to find headers
+const headerReadSize = 64 * 1024
+
+var codepageRE = regexp.MustCompile(`#CODEPAGE\s+"?([A-Za-z]+)`)
+
+// Windows code pages by their names in #CODEPAGE header
+var charmapByCodepage = map[string]*charmap.Charmap{
+	"latin":           charmap.Windows1252,
+	"cyrillic":        charmap.Windows1251,
+	"russian":         charmap.Windows1251,
+	"easterneuropean": charmap.Windows1250,
+	"greek":           charmap.Windows1253,
+	"turkish":         charmap.Windows1254,
+	"hebrew":          charmap.Windows1255,
+	"arabic":          charmap.Windows1256,
+	"baltic":          charmap.Windows1257,
+	"vietnamese":      charmap.Windows1258,
+}
+
+// readFile reads and decompresses (if it's .dz) a file, reads at most
+// limit bytes if limit > 0
+func readFile(path string, limit int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	if strings.HasSuffix(strings.ToLower(path), ".dz") {
+		// dictzip files are valid gzip files
+		gzReader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gzReader.Close()
+		reader = gzReader
+	}
+	if limit > 0 {
+		reader = io.LimitReader(reader, limit)
+	}
+	return io.ReadAll(reader)
+}
+
+// decode converts DSL (or annotation) file content to UTF-8 text.
+// DSL files are usually in UTF-16LE, but UTF-8 and legacy Windows
+// code pages (given by #CODEPAGE header) are also supported.
+// truncated means data is only the beginning of file
+func decode(data []byte, truncated bool) (string, error) {
+	var enc encoding.Encoding
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		enc = unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM)
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		enc = unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM)
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		data = data[3:]
+	case len(data) > 1 && data[0] != 0 && data[1] == 0:
+		enc = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	case len(data) > 1 && data[0] == 0 && data[1] != 0:
+		enc = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	}
+	if enc == nil {
+		if utf8.Valid(data) {
+			return string(data), nil
+		}
+		if truncated {
+			// last character may be cut
+			for cut := 1; cut <= 3 && cut < len(data); cut++ {
+				if utf8.Valid(data[:len(data)-cut]) {
+					return string(data[:len(data)-cut]), nil
+				}
+			}
+		}
+		enc = charmap.Windows1252
+		if match := codepageRE.FindSubmatch(data); match != nil {
+			if cm := charmapByCodepage[strings.ToLower(string(match[1]))]; cm != nil {
+				enc = cm
+			}
+		}
+	} else if truncated && len(data)%2 == 1 {
+		data = data[:len(data)-1]
+	}
+	utf8Data, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", err
+	}
+	return string(utf8Data), nil
+}
@@ -0,0 +1,360 @@
+// Package dsl implements common.Dictionary for ABBYY Lingvo DSL files
+// (.dsl or dictzip-compressed .dsl.dz), with optional annotation (.ann)
+// and resources (.files.zip) files next to them.
+// Articles are converted from DSL markup to HTML when they are shown
+package dsl
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dictfiles"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/termsearch"
+	common "github.com/ilius/go-dict-commons"
+)
+
+// dictionaryImp is a DSL dictionary, the whole (decoded) text is kept
+// in memory after loading
+type dictionaryImp struct {
+	path        string
+	name        string
+	description string
+
+	mu       sync.RWMutex
+	disabled bool
+	entries  []*entry
+	resDir   string
+}
+
+var _ common.Dictionary = (*dictionaryImp)(nil)
+
+// basePath returns path without .dsl or .dsl.dz extension
+func basePath(path string) string {
+	lower := strings.ToLower(path)
+	for _, ext := range []string{".dsl.dz", ".dsl"} {
+		if strings.HasSuffix(lower, ext) {
+			return path[:len(path)-len(ext)]
+		}
+	}
+	return path
+}
+
+// NewDictionary reads headers and annotation of DSL file
+func NewDictionary(path string) (*dictionaryImp, error) {
+	data, err := readFile(path, headerReadSize)
+	if err != nil {
+		return nil, err
+	}
+	text, err := decode(data, true)
+	if err != nil {
+		return nil, err
+	}
+	headers := parseHeaders(text)
+	d := &dictionaryImp{
+		path:        path,
+		name:        headers["NAME"],
+		description: headerDescription(headers),
+	}
+	if d.name == "" {
+		d.name = filepath.Base(basePath(path))
+	}
+	annotation, err := readAnnotation(basePath(path) + ".ann")
+	if err != nil {
+		slog.Error("error reading annotation file", "err", err, "path", path)
+	}
+	if annotation != "" {
+		if d.description != "" {
+			d.description += "\n"
+		}
+		d.description += annotation
+	}
+	return d, nil
+}
+
+func headerDescription(headers map[string]string) string {
+	parts := []string{}
+	if lang := headers["INDEX_LANGUAGE"]; lang != "" {
+		parts = append(parts, "Index language: "+lang)
+	}
+	if lang := headers["CONTENTS_LANGUAGE"]; lang != "" {
+		parts = append(parts, "Contents language: "+lang)
+	}
+	return strings.Join(parts, ". ")
+}
+
+// readAnnotation returns text of annotation file, or empty string if
+// it does not exist
+func readAnnotation(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	text, err := decode(data, false)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(strings.TrimPrefix(text, "\uFEFF")), nil
+}
+
+func (d *dictionaryImp) Disabled() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.disabled
+}
+
+func (d *dictionaryImp) SetDisabled(disabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.disabled = disabled
+}
+
+func (d *dictionaryImp) Loaded() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.entries != nil
+}
+
+// Load reads and parses the whole DSL file, and extracts resources
+func (d *dictionaryImp) Load() error {
+	data, err := readFile(d.path, 0)
+	if err != nil {
+		return err
+	}
+	text, err := decode(data, false)
+	if err != nil {
+		return err
+	}
+	entries := parseEntries(text)
+	resDir, err := extractResources(d.resourcesPath(), d.name)
+	if err != nil {
+		slog.Error("error extracting resources", "err", err, "dictName", d.name)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries = entries
+	d.resDir = resDir
+	return nil
+}
+
+// resourcesPath returns path of .files.zip file, or empty string
+// if there is none
+func (d *dictionaryImp) resourcesPath() string {
+	candidates := []string{
+		// like "dict.dsl.files.zip" for "dict.dsl.dz"
+		strings.TrimSuffix(d.path, filepath.Ext(d.path)) + ".files.zip",
+		basePath(d.path) + ".files.zip",
+		d.path + ".files.zip",
+	}
+	for _, fpath := range candidates {
+		if _, err := os.Stat(fpath); err == nil {
+			return fpath
+		}
+	}
+	return ""
+}
+
+// extractResources extracts files of zip file into cache directory,
+// and returns its path. files that are already extracted (with the same
+// size and modification time) are skipped.
+// returns empty path if there is no resource
+func extractResources(zipPath string, dictName string) (string, error) {
+	if zipPath == "" {
+		return "", nil
+	}
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	resDir := dictfiles.ResourceDir("dsl", dictName)
+	count := 0
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		ok, err := dictfiles.ExtractResource(
+			resDir,
+			file.Name,
+			int64(file.UncompressedSize64),
+			file.Modified,
+			func() ([]byte, error) {
+				return readZipFile(file)
+			},
+		)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			slog.Warn("skipping resource with invalid path", "path", file.Name, "dictName", dictName)
+			continue
+		}
+		count++
+	}
+	if count == 0 {
+		return "", nil
+	}
+	return resDir, nil
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("error opening %#v in zip: %w", file.Name, err)
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func (d *dictionaryImp) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries = nil
+}
+
+func (d *dictionaryImp) DictName() string {
+	return d.name
+}
+
+func (d *dictionaryImp) EntryCount() (int, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.entries == nil {
+		return 0, errors.New("dictionary is not loaded")
+	}
+	return len(d.entries), nil
+}
+
+func (d *dictionaryImp) Description() string {
+	return d.description
+}
+
+func (d *dictionaryImp) ResourceDir() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.resDir
+}
+
+func (d *dictionaryImp) ResourceURL() string {
+	return dictfiles.FileURL(d.ResourceDir())
+}
+
+func (d *dictionaryImp) IndexPath() string {
+	return d.path
+}
+
+func (d *dictionaryImp) IndexFileSize() uint64 {
+	fi, err := os.Stat(d.path)
+	if err != nil {
+		return 0
+	}
+	return uint64(fi.Size())
+}
+
+func (d *dictionaryImp) InfoPath() string {
+	return ""
+}
+
+func (d *dictionaryImp) CalcHash() ([]byte, error) {
+	file, err := os.Open(d.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	hash := sha1.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// entryList implements termsearch.Entries
+type entryList []*entry
+
+func (l entryList) Len() int {
+	return len(l)
+}
+
+func (l entryList) Terms(index int) []string {
+	return l[index].terms
+}
+
+func (l entryList) Result(index int, score uint8) *common.SearchResultLow {
+	e := l[index]
+	return &common.SearchResultLow{
+		F_Score: score,
+		F_Terms: e.terms,
+		Items: func() []*common.SearchResultItem {
+			return []*common.SearchResultItem{{
+				Type: 'h',
+				Data: []byte(toHTML(e.body, e.terms[0])),
+			}}
+		},
+		F_EntryIndex: uint64(index),
+	}
+}
+
+func (d *dictionaryImp) entryList() entryList {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.entries
+}
+
+func (d *dictionaryImp) EntryByIndex(index int) *common.SearchResultLow {
+	list := d.entryList()
+	if index < 0 || index >= list.Len() {
+		return nil
+	}
+	return list.Result(index, 0)
+}
+
+func (d *dictionaryImp) SearchFuzzy(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	return termsearch.Fuzzy(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchStartWith(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	return termsearch.StartWith(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchWordMatch(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	return termsearch.WordMatch(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchRegex(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, error) {
+	return termsearch.Regex(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchGlob(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, error) {
+	return termsearch.Glob(d.entryList(), query, workerCount, timeout)
+}
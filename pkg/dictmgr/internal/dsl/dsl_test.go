@@ -0,0 +1,199 @@
+package dsl
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dictfiles"
+	"github.com/ilius/is/v2"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestExpandHeadword(t *testing.T) {
+	is := is.New(t)
+	is.Equal(expandHeadword("work(s)"), []string{"work", "works"})
+	is.Equal(expandHeadword("go {to} bed"), []string{"go bed"})
+	is.Equal(expandHeadword("a{{comment}}b \\(c\\)"), []string{"ab (c)"})
+	is.Equal(expandHeadword("(a)b(c)"), []string{"b", "ab", "bc", "abc"})
+	is.Equal(expandHeadword("(a)(b)(c)(d)e"), []string{"e", "abcde"})
+}
+
+func TestParseEntries(t *testing.T) {
+	is := is.New(t)
+	text := "\uFEFF#NAME \"Test\"\r\n#INDEX_LANGUAGE \"English\"\r\n\r\n" +
+		"colour\r\ncolor\r\n\t[m1]a hue\r\n\t[m1]{{note}}paint\r\n" +
+		"work(s)\r\n  labour\r\n"
+	headers := parseHeaders(text)
+	is.Equal(headers["NAME"], "Test")
+	is.Equal(headers["INDEX_LANGUAGE"], "English")
+	entries := parseEntries(text)
+	is.Equal(len(entries), 2)
+	is.Equal(entries[0].terms, []string{"colour", "color"})
+	is.Equal(entries[0].body, "\t[m1]a hue\r\n\t[m1]{{note}}paint")
+	is.Equal(entries[1].terms, []string{"work", "works"})
+	is.Equal(entries[1].body, "  labour")
+}
+
+func TestToHTML(t *testing.T) {
+	is := is.New(t)
+	test := func(body string, expected string) {
+		t.Helper()
+		is.Equal(toHTML(body, "cat"), expected)
+	}
+	test("[m1][trn]a [b]small[/b] ~[/trn][/m]", `<div style="margin-left:1em">a <b>small</b> cat</div>`+"\n")
+	test("[p]n[/p] [c red]x[/c] [c]y[/c]", `<div><i style="color:green">n</i> <font color="red">x</font> <font color="green">y</font></div>`+"\n")
+	test("see <<dog>> or [ref]kitten[/ref]", `<div>see <a href="bword://dog">dog</a> or <a href="bword://kitten">kitten</a></div>`+"\n")
+	test("[s]meow.wav[/s] [s]cat.png[/s]", `<div><a href="sound://meow.wav"></a> <img src="cat.png"/></div>`+"\n")
+	test("[b]one\n[i]two[/b] three[/i]", "<div><b>one</b></div>\n<div><b><i>two</i></b><i> three</i></div>\n")
+	test("a \\[b\\] <x> & {{c}}", "<div>a [b] &lt;x&gt; &amp; </div>\n")
+	test("[c \"><script>]x[/c]", `<div><font color="green">x</font></div>`+"\n")
+}
+
+func TestDecode(t *testing.T) {
+	is := is.New(t)
+	utf16, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte("#NAME \"Ø\""))
+	is.NotErr(err)
+	text, err := decode(utf16, false)
+	is.NotErr(err)
+	is.Equal(text, "#NAME \"Ø\"")
+
+	text, err = decode(utf16[:len(utf16)-1], true)
+	is.NotErr(err)
+	is.Equal(text, "#NAME \"Ø")
+
+	text, err = decode([]byte("#CODEPAGE \"Cyrillic\"\n\xcf\xf0"), false)
+	is.NotErr(err)
+	is.Equal(text, "#CODEPAGE \"Cyrillic\"\nПр")
+}
+
+func writeDZ(t *testing.T, path string, text string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	writer := gzip.NewWriter(file)
+	if _, err := writer.Write([]byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	writer := zip.NewWriter(file)
+	for name, data := range files {
+		fw, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	is := is.New(t)
+	dictfiles.ResCacheDir = t.TempDir()
+	dir := t.TempDir()
+	writeDZ(t, filepath.Join(dir, "animals.dsl.dz"),
+		"#NAME \"Animals\"\n#INDEX_LANGUAGE \"English\"\n#CONTENTS_LANGUAGE \"French\"\n\n"+
+			"cat\n\t[trn]chat[/trn] [s]meow.wav[/s]\ndog\n\tchien\n")
+	writeZip(t, filepath.Join(dir, "animals.dsl.files.zip"), map[string]string{
+		"meow.wav":  "RIFF",
+		"../x.wav":  "bad",
+		"img/a.png": "PNG",
+	})
+	err := os.WriteFile(filepath.Join(dir, "animals.ann"), []byte("Animal names\n"), 0o644)
+	is.NotErr(err)
+	err = os.MkdirAll(filepath.Join(dir, "sub"), 0o755)
+	is.NotErr(err)
+	err = os.WriteFile(filepath.Join(dir, "sub", "other.dsl"), []byte("apple\n pomme\n"), 0o644)
+	is.NotErr(err)
+	err = os.WriteFile(filepath.Join(dir, "readme.txt"), []byte("apple\n pomme\n"), 0o644)
+	is.NotErr(err)
+
+	dicList := Open([]string{dir}, map[string]int{"other": -1})
+	is.Equal(len(dicList), 2)
+
+	dic := dicList[0]
+	is.Equal(dic.DictName(), "Animals")
+	is.Equal(dic.Description(), "Index language: English. Contents language: French\nAnimal names")
+	is.True(dic.Loaded())
+	count, err := dic.EntryCount()
+	is.NotErr(err)
+	is.Equal(count, 2)
+
+	results := dic.SearchStartWith("ca", 1, 0)
+	is.Equal(len(results), 1)
+	is.Equal(results[0].F_Terms, []string{"cat"})
+	items := results[0].Items()
+	is.Equal(len(items), 1)
+	is.Equal(items[0].Type, 'h')
+	is.Equal(string(items[0].Data), `<div>chat <a href="sound://meow.wav"></a></div>`+"\n")
+
+	resDir := dic.ResourceDir()
+	is.Equal(resDir, filepath.Join(dictfiles.ResCacheDir, "dsl", "Animals"))
+	data, err := os.ReadFile(filepath.Join(resDir, "meow.wav"))
+	is.NotErr(err)
+	is.Equal(string(data), "RIFF")
+	_, err = os.Stat(filepath.Join(resDir, "img", "a.png"))
+	is.NotErr(err)
+
+	other := dicList[1]
+	is.Equal(other.DictName(), "other")
+	is.True(other.Disabled())
+	is.False(other.Loaded())
+	is.NotErr(other.Load())
+	is.Equal(other.EntryByIndex(0).F_Terms, []string{"apple"})
+	is.Equal(other.ResourceDir(), "")
+	other.Close()
+	is.False(other.Loaded())
+}
+
+func TestExtractResourcesCached(t *testing.T) {
+	is := is.New(t)
+	dictfiles.ResCacheDir = t.TempDir()
+	zipPath := filepath.Join(t.TempDir(), "test.dsl.files.zip")
+	writeZip(t, zipPath, map[string]string{"meow.wav": "RIFF"})
+
+	resDir, err := extractResources(zipPath, "test")
+	is.NotErr(err)
+	fpath := filepath.Join(resDir, "meow.wav")
+	fi, err := os.Stat(fpath)
+	is.NotErr(err)
+	modTime := fi.ModTime()
+
+	// file with the same size and modification time is not extracted again
+	is.NotErr(os.WriteFile(fpath, []byte("WAVE"), 0o644))
+	is.NotErr(os.Chtimes(fpath, modTime, modTime))
+	_, err = extractResources(zipPath, "test")
+	is.NotErr(err)
+	data, err := os.ReadFile(fpath)
+	is.NotErr(err)
+	is.Equal(string(data), "WAVE")
+
+	// but it is if modification time differs
+	is.NotErr(os.Chtimes(fpath, modTime, modTime.Add(time.Hour)))
+	_, err = extractResources(zipPath, "test")
+	is.NotErr(err)
+	data, err = os.ReadFile(fpath)
+	is.NotErr(err)
+	is.Equal(string(data), "RIFF")
+}
@@ -0,0 +1,266 @@
+package dsl
+
+import (
+	"html"
+	"strings"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dictfiles"
+)
+
+type htmlTag struct {
+	open  string
+	close string
+}
+
+// inline DSL tags and their HTML, tags that are not here (like [trn],
+// [lang], [*] and [']) are removed, keeping their content
+var htmlTags = map[string]htmlTag{
+	"b":   {"<b>", "</b>"},
+	"i":   {"<i>", "</i>"},
+	"u":   {"<u>", "</u>"},
+	"sup": {"<sup>", "</sup>"},
+	"sub": {"<sub>", "</sub>"},
+	"p":   {`<i style="color:green">`, "</i>"},
+	"ex":  {`<span style="color:steelblue">`, "</span>"},
+	"com": {`<span style="color:gray">`, "</span>"},
+	"t":   {`<span class="transcription">`, "</span>"},
+}
+
+// tags whose content is used as a link or file name
+var contentTags = map[string]bool{
+	"s":     true,
+	"ref":   true,
+	"url":   true,
+	"video": true,
+}
+
+// htmlConverter converts DSL markup of an article to HTML
+type htmlConverter struct {
+	headword string
+
+	out strings.Builder
+
+	// open inline tags of current line
+	stack []htmlTag
+}
+
+// toHTML converts DSL markup of article body to HTML, each line is a
+// <div>, indented by its [m] tag.
+// Links to other words are like <a href="bword://word">, sounds are like
+// <a href="sound://file.wav"></a> and images are like <img src="file.png">
+// so they are fixed by DictProcessor.FixDefiHTML like StarDict articles
+func toHTML(body string, headword string) string {
+	c := &htmlConverter{headword: headword}
+	for line := range strings.Lines(body) {
+		c.writeLine(line)
+	}
+	return c.out.String()
+}
+
+func (c *htmlConverter) writeLine(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	margin := ""
+	if len(line) >= 3 && strings.HasPrefix(line, "[m") {
+		if end := strings.IndexByte(line, ']'); end > 0 && end <= 4 {
+			margin = line[2:end]
+			line = line[end+1:]
+		}
+	}
+	switch margin {
+	case "", "0":
+		c.out.WriteString("<div>")
+	default:
+		c.out.WriteString(`<div style="margin-left:` + html.EscapeString(margin) + `em">`)
+	}
+	for _, tag := range c.stack {
+		c.out.WriteString(tag.open)
+	}
+	c.writeText(line)
+	for i := len(c.stack) - 1; i >= 0; i-- {
+		c.out.WriteString(c.stack[i].close)
+	}
+	c.out.WriteString("</div>\n")
+}
+
+func (c *htmlConverter) writeText(text string) {
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case ch == '\\':
+			if i+1 < len(text) {
+				i++
+				c.writeEscaped(text[i : i+1])
+			}
+		case ch == '~':
+			c.writeEscaped(c.headword)
+		case strings.HasPrefix(text[i:], "{{"):
+			end := strings.Index(text[i:], "}}")
+			if end < 0 {
+				return
+			}
+			i += end + 1
+		case strings.HasPrefix(text[i:], "<<"):
+			end := strings.Index(text[i:], ">>")
+			if end < 0 {
+				c.writeEscaped(text[i:])
+				return
+			}
+			c.writeWordLink(text[i+2 : i+end])
+			i += end + 1
+		case ch == '[':
+			end := strings.IndexByte(text[i:], ']')
+			if end < 0 {
+				c.writeEscaped(text[i:])
+				return
+			}
+			i += end
+			i = c.writeTag(text[i-end+1:i], text, i)
+		default:
+			// copy a run of plain characters
+			j := i + 1
+			for j < len(text) && !strings.ContainsRune(`\~{<[`, rune(text[j])) {
+				j++
+			}
+			c.writeEscaped(text[i:j])
+			i = j - 1
+		}
+	}
+}
+
+func (c *htmlConverter) writeEscaped(text string) {
+	c.out.WriteString(html.EscapeString(text))
+}
+
+// writeTag handles a tag (without brackets) which ends at text[pos],
+// returns the position of last character that is handled
+func (c *htmlConverter) writeTag(tag string, text string, pos int) int {
+	if strings.HasPrefix(tag, "/") {
+		c.closeTag(strings.TrimSpace(tag[1:]))
+		return pos
+	}
+	name, attr, _ := strings.Cut(tag, " ")
+	attr = strings.TrimSpace(attr)
+	if contentTags[name] {
+		closing := "[/" + name + "]"
+		end := strings.Index(text[pos+1:], closing)
+		if end < 0 {
+			return pos
+		}
+		c.writeContentTag(name, unescape(text[pos+1:pos+1+end]))
+		return pos + end + len(closing)
+	}
+	switch name {
+	case "c":
+		c.openTag(htmlTag{
+			open:  `<font color="` + colorAttr(attr) + `">`,
+			close: "</font>",
+		})
+		return pos
+	case "br":
+		c.out.WriteString("<br/>")
+		return pos
+	}
+	tagHTML, ok := htmlTags[name]
+	if !ok {
+		return pos
+	}
+	c.openTag(tagHTML)
+	return pos
+}
+
+func (c *htmlConverter) openTag(tag htmlTag) {
+	c.out.WriteString(tag.open)
+	c.stack = append(c.stack, tag)
+}
+
+func (c *htmlConverter) closeTag(name string) {
+	var tag htmlTag
+	switch name {
+	case "c":
+		tag.close = "</font>"
+	default:
+		var ok bool
+		tag, ok = htmlTags[name]
+		if !ok {
+			return
+		}
+	}
+	// close the last open tag with this HTML
+	for i := len(c.stack) - 1; i >= 0; i-- {
+		if c.stack[i].close != tag.close {
+			continue
+		}
+		// close tags that are opened after it too, and re-open them
+		for j := len(c.stack) - 1; j >= i; j-- {
+			c.out.WriteString(c.stack[j].close)
+		}
+		for j := i + 1; j < len(c.stack); j++ {
+			c.out.WriteString(c.stack[j].open)
+		}
+		c.stack = append(c.stack[:i], c.stack[i+1:]...)
+		return
+	}
+}
+
+func (c *htmlConverter) writeWordLink(word string) {
+	word = unescape(word)
+	c.out.WriteString(`<a href="bword://` + html.EscapeString(word) + `">`)
+	c.writeEscaped(word)
+	c.out.WriteString("</a>")
+}
+
+func (c *htmlConverter) writeContentTag(name string, content string) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return
+	}
+	escaped := html.EscapeString(content)
+	switch name {
+	case "ref":
+		c.writeWordLink(content)
+	case "url":
+		c.out.WriteString(`<a href="` + escaped + `">` + escaped + "</a>")
+	default: // s, video
+		c.out.WriteString(dictfiles.ResourceHTML(content))
+	}
+}
+
+// unescape removes backslashes and tags from content of [ref], [s], etc
+func unescape(text string) string {
+	var buf strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			if i+1 < len(text) {
+				i++
+				buf.WriteByte(text[i])
+			}
+		case '[':
+			end := strings.IndexByte(text[i:], ']')
+			if end < 0 {
+				buf.WriteString(text[i:])
+				return buf.String()
+			}
+			i += end
+		default:
+			buf.WriteByte(text[i])
+		}
+	}
+	return buf.String()
+}
+
+// colorAttr returns a safe color name/value of [c] tag, green by default
+func colorAttr(attr string) string {
+	if attr == "" {
+		return "green"
+	}
+	for _, r := range attr {
+		if !(r == '#' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return "green"
+		}
+	}
+	return attr
+}
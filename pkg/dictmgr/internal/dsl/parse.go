@@ -0,0 +1,189 @@
+package dsl
+
+import (
+	"strings"
+)
+
+// maximum number of optional parts of headword that are expanded
+// into all combinations, more optional parts make only 2 variants
+const maxOptionalParts = 3
+
+type entry struct {
+	terms []string
+
+	// body is DSL markup of article, with indented lines
+	body string
+}
+
+// parseHeaders returns headers (like NAME, INDEX_LANGUAGE) at the
+// beginning of DSL text, with quotes removed from values
+func parseHeaders(text string) map[string]string {
+	headers := map[string]string{}
+	text = strings.TrimPrefix(text, "\uFEFF")
+	for line := range strings.Lines(text) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if line[0] != '#' {
+			break
+		}
+		key, value, _ := strings.Cut(line[1:], " ")
+		value = strings.TrimSpace(value)
+		value = strings.TrimPrefix(value, `"`)
+		value = strings.TrimSuffix(value, `"`)
+		headers[strings.ToUpper(key)] = value
+	}
+	return headers
+}
+
+// parseEntries parses entries of DSL text. Each entry has one or more
+// headword lines, followed by indented lines of article body.
+// Body of entries are substrings of text, so text is kept in memory
+func parseEntries(text string) []*entry {
+	entries := []*entry{}
+	var headwords []string
+	bodyStart, bodyEnd := -1, -1
+	flush := func() {
+		if len(headwords) > 0 && bodyStart >= 0 {
+			terms := headwordTerms(headwords)
+			if len(terms) > 0 {
+				entries = append(entries, &entry{
+					terms: terms,
+					body:  text[bodyStart:bodyEnd],
+				})
+			}
+		}
+		headwords = nil
+		bodyStart = -1
+	}
+	inHeader := true
+	pos := 0
+	for pos < len(text) {
+		lineEnd := strings.IndexByte(text[pos:], '\n')
+		next := pos + lineEnd + 1
+		if lineEnd < 0 {
+			lineEnd = len(text) - pos
+			next = len(text)
+		}
+		lineEnd += pos
+		line := strings.TrimRight(text[pos:lineEnd], "\r")
+		switch {
+		case strings.TrimSpace(line) == "":
+		case line[0] == ' ' || line[0] == '\t':
+			if len(headwords) > 0 {
+				if bodyStart < 0 {
+					bodyStart = pos
+				}
+				bodyEnd = pos + len(line)
+			}
+		case inHeader && (line[0] == '#' || strings.HasPrefix(line, "\uFEFF#")):
+		default:
+			inHeader = false
+			if bodyStart >= 0 {
+				flush()
+			}
+			headwords = append(headwords, line)
+		}
+		pos = next
+	}
+	flush()
+	return entries
+}
+
+// headwordTerms returns unique search terms of headword lines
+func headwordTerms(lines []string) []string {
+	terms := []string{}
+	seen := map[string]bool{}
+	for _, line := range lines {
+		for _, term := range expandHeadword(line) {
+			if term == "" || seen[term] {
+				continue
+			}
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+type headwordPart struct {
+	text     string
+	optional bool
+}
+
+// expandHeadword returns variants of a headword line, removing escapes,
+// comments ({{...}}) and unsorted parts ({...}), and expanding optional
+// parts in parentheses, like "work(s)" to "work" and "works"
+func expandHeadword(line string) []string {
+	parts := []headwordPart{}
+	var buf strings.Builder
+	optional := false
+	endPart := func() {
+		if buf.Len() > 0 {
+			parts = append(parts, headwordPart{text: buf.String(), optional: optional})
+			buf.Reset()
+		}
+	}
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch c {
+		case '\\':
+			if i+1 < len(line) {
+				i++
+				buf.WriteByte(line[i])
+			}
+		case '{':
+			end := strings.IndexByte(line[i:], '}')
+			if end < 0 {
+				i = len(line)
+				continue
+			}
+			i += end
+			// skip second '}' of comments
+			if i+1 < len(line) && line[i+1] == '}' {
+				i++
+			}
+		case '(':
+			endPart()
+			optional = true
+		case ')':
+			endPart()
+			optional = false
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	endPart()
+
+	optionalCount := 0
+	for _, part := range parts {
+		if part.optional {
+			optionalCount++
+		}
+	}
+	masks := []int{0, -1}
+	if optionalCount <= maxOptionalParts {
+		masks = make([]int, 1<<optionalCount)
+		for mask := range masks {
+			masks[mask] = mask
+		}
+	}
+	variants := make([]string, 0, len(masks))
+	for _, mask := range masks {
+		var variant strings.Builder
+		optIndex := 0
+		for _, part := range parts {
+			if part.optional {
+				include := mask&(1<<optIndex) != 0
+				optIndex++
+				if !include {
+					continue
+				}
+			}
+			variant.WriteString(part.text)
+		}
+		variants = append(variants, strings.Join(strings.Fields(variant.String()), " "))
+	}
+	return variants
+}
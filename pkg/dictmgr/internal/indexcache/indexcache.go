@@ -0,0 +1,57 @@
+// Package indexcache stores per-dictionary search indexes in cache directory
+// Index files are keyed by path, size and modification time of dictionary
+// files, so an index is rebuilt only when the dictionary is modified
+package indexcache
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+
+	"github.com/ilius/ayandict/v2/pkg/config"
+)
+
+const dirName = "index"
+
+// Path returns path of index file for given kind of index and dict key
+func Path(kind string, key string) string {
+	return filepath.Join(config.GetCacheDir(), dirName, kind, key+".gob")
+}
+
+// Load reads index file into value (a pointer)
+// returns an error satisfying os.IsNotExist if there is no index file
+func Load(kind string, key string, value any) error {
+	file, err := os.Open(Path(kind, key))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return gob.NewDecoder(file).Decode(value)
+}
+
+// Save writes value into index file
+func Save(kind string, key string, value any) error {
+	fpath := Path(kind, key)
+	err := os.MkdirAll(filepath.Dir(fpath), 0o755)
+	if err != nil {
+		return err
+	}
+	// write to a temp file first, so we never leave a half-written index
+	tmpPath := fpath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	err = gob.NewEncoder(file).Encode(value)
+	if err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, fpath)
+}
@@ -0,0 +1,54 @@
+package kblayout
+
+import (
+	"strings"
+)
+
+var builtinKeys = map[string]map[rune]rune{
+	// Persian standard layout (ISIRI 9147)
+	"fa": fromRows(
+		"qwertyuiop[]asdfghjkl;'zxcvbnm,",
+		"ضصثقفغعهخحجچشسیبلاتنمکگظطزرذدپو",
+	),
+	// Arabic (101) layout
+	"ar": fromRows(
+		"qwertyuiop[]asdfghjkl;'zxcvnm,./`",
+		"ضصثقفغعهخحجدشسيبلاتنمكطئءؤرىةوزظذ",
+	),
+	// Russian (ЙЦУКЕН) layout
+	"ru": withUpper(fromRows(
+		"qwertyuiop[]asdfghjkl;'zxcvbnm,.`",
+		"йцукенгшщзхъфывапролджэячсмитьбюё",
+	)),
+	// German (QWERTZ) layout
+	"de": withUpper(fromRows(
+		"qwertyuiop[asdfghjkl;'zxcvbnm-",
+		"qwertzuiopüasdfghjklöäyxcvbnmß",
+	)),
+}
+
+// withUpper adds uppercase of letter keys that are mapped to letters
+func withUpper(m map[rune]rune) map[rune]rune {
+	for key, c := range m {
+		upperKey := []rune(strings.ToUpper(string(key)))
+		upperC := []rune(strings.ToUpper(string(c)))
+		if len(upperKey) != 1 || len(upperC) != 1 {
+			continue
+		}
+		if upperKey[0] == key || upperC[0] == c {
+			continue
+		}
+		m[upperKey[0]] = upperC[0]
+	}
+	return m
+}
+
+// Builtin returns a built-in layout by name, or nil
+// available layouts: fa, ar, ru, de
+func Builtin(name string) *Layout {
+	keys := builtinKeys[name]
+	if keys == nil {
+		return nil
+	}
+	return New(name, keys)
+}
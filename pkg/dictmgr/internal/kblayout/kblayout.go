@@ -0,0 +1,124 @@
+// Package kblayout remaps text typed with a wrong keyboard layout,
+// for example "sghl" (typed on English layout) to "سلام" (Persian layout)
+package kblayout
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Layout maps keys of US QWERTY layout to characters of another layout
+type Layout struct {
+	Name string
+
+	toLayout   map[rune]rune
+	fromLayout map[rune]rune
+}
+
+// New creates a layout from a mapping of US QWERTY keys to characters
+func New(name string, keys map[rune]rune) *Layout {
+	l := &Layout{
+		Name:       name,
+		toLayout:   make(map[rune]rune, len(keys)),
+		fromLayout: make(map[rune]rune, len(keys)),
+	}
+	for key, c := range keys {
+		l.toLayout[key] = c
+		if _, ok := l.fromLayout[c]; !ok {
+			l.fromLayout[c] = key
+		}
+	}
+	return l
+}
+
+// fromRows creates mapping from two strings with same number of runes
+func fromRows(keys string, chars string) map[rune]rune {
+	keyRunes := []rune(keys)
+	charRunes := []rune(chars)
+	if len(keyRunes) != len(charRunes) {
+		panic("kblayout: rows have different lengths: " + keys)
+	}
+	m := make(map[rune]rune, len(keyRunes))
+	for i, key := range keyRunes {
+		m[key] = charRunes[i]
+	}
+	return m
+}
+
+// Load reads a layout file, in which each line has a US QWERTY key and
+// the character it types in that layout, separated by space
+// Empty lines and lines starting with # are ignored.
+// Name of layout is the file name without extension
+func Load(fpath string) (*Layout, error) {
+	file, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	keys := map[rune]rune{}
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 ||
+			utf8.RuneCountInString(fields[0]) != 1 ||
+			utf8.RuneCountInString(fields[1]) != 1 {
+			return nil, fmt.Errorf("%s: line %d: invalid mapping %#v", fpath, lineNum, line)
+		}
+		key, _ := utf8.DecodeRuneInString(fields[0])
+		c, _ := utf8.DecodeRuneInString(fields[1])
+		keys[key] = c
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(filepath.Base(fpath), filepath.Ext(fpath))
+	return New(name, keys), nil
+}
+
+// Remap converts text typed on US layout to this layout, or text
+// typed on this layout to US layout, depending on which one covers
+// all letters of text. Returns false if neither does, or nothing changes
+func (l *Layout) Remap(text string) (string, bool) {
+	if remapped, ok := remap(text, l.toLayout, true); ok {
+		return remapped, true
+	}
+	return remap(text, l.fromLayout, false)
+}
+
+func remap(text string, m map[rune]rune, foldCase bool) (string, bool) {
+	var sb strings.Builder
+	changed := false
+	for _, c := range text {
+		if unicode.IsSpace(c) || unicode.IsDigit(c) {
+			sb.WriteRune(c)
+			continue
+		}
+		key := c
+		if _, ok := m[key]; !ok && foldCase {
+			key = unicode.ToLower(c)
+		}
+		mapped, ok := m[key]
+		if !ok {
+			return "", false
+		}
+		if mapped != c {
+			changed = true
+		}
+		sb.WriteRune(mapped)
+	}
+	if !changed {
+		return "", false
+	}
+	return sb.String(), true
+}
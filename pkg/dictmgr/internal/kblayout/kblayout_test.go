@@ -0,0 +1,61 @@
+package kblayout
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+func TestRemapPersian(t *testing.T) {
+	is := is.New(t)
+	l := Builtin("fa")
+	remapped, ok := l.Remap("sghl")
+	is.True(ok)
+	is.Equal(remapped, "سلام")
+	remapped, ok = l.Remap("SGHL")
+	is.True(ok)
+	is.Equal(remapped, "سلام")
+	remapped, ok = l.Remap("سلام")
+	is.True(ok)
+	is.Equal(remapped, "sghl")
+	remapped, ok = l.Remap("lhnv fcv\"")
+	is.False(ok)
+	is.Equal(remapped, "")
+}
+
+func TestRemapRussian(t *testing.T) {
+	is := is.New(t)
+	l := Builtin("ru")
+	remapped, ok := l.Remap("ghbdtn")
+	is.True(ok)
+	is.Equal(remapped, "привет")
+	remapped, ok = l.Remap("Ghbdtn")
+	is.True(ok)
+	is.Equal(remapped, "Привет")
+}
+
+func TestRemapGerman(t *testing.T) {
+	is := is.New(t)
+	l := Builtin("de")
+	remapped, ok := l.Remap("yeit")
+	is.True(ok)
+	is.Equal(remapped, "zeit")
+	// nothing to change
+	_, ok = l.Remap("haus")
+	is.False(ok)
+}
+
+func TestLoad(t *testing.T) {
+	is := is.New(t)
+	fpath := filepath.Join(t.TempDir(), "test.txt")
+	err := os.WriteFile(fpath, []byte("# comment\na α\nb β\n\n"), 0o644)
+	is.NotErr(err)
+	l, err := Load(fpath)
+	is.NotErr(err)
+	is.Equal(l.Name, "test")
+	remapped, ok := l.Remap("ab ba")
+	is.True(ok)
+	is.Equal(remapped, "αβ βα")
+}
@@ -0,0 +1,133 @@
+package lemma
+
+import (
+	"strings"
+)
+
+// suffixRule replaces suffix of word (if word is long enough)
+type suffixRule struct {
+	suffix  string
+	replace string
+	minStem int // minimum length of remaining stem in runes
+}
+
+func applySuffixRules(word string, rules []suffixRule) []string {
+	result := []string{}
+	for _, rule := range rules {
+		if !strings.HasSuffix(word, rule.suffix) {
+			continue
+		}
+		stem := word[:len(word)-len(rule.suffix)]
+		if len([]rune(stem)) < rule.minStem {
+			continue
+		}
+		result = append(result, stem+rule.replace)
+	}
+	return result
+}
+
+var englishIrregular = map[string][]string{
+	"children": {"child"},
+	"men":      {"man"},
+	"women":    {"woman"},
+	"people":   {"person"},
+	"mice":     {"mouse"},
+	"geese":    {"goose"},
+	"feet":     {"foot"},
+	"teeth":    {"tooth"},
+	"oxen":     {"ox"},
+	"was":      {"be"},
+	"were":     {"be"},
+	"been":     {"be"},
+	"is":       {"be"},
+	"are":      {"be"},
+	"am":       {"be"},
+	"has":      {"have"},
+	"had":      {"have"},
+	"did":      {"do"},
+	"done":     {"do"},
+	"does":     {"do"},
+	"went":     {"go"},
+	"gone":     {"go"},
+	"ran":      {"run"},
+	"came":     {"come"},
+	"saw":      {"see"},
+	"seen":     {"see"},
+	"took":     {"take"},
+	"taken":    {"take"},
+	"gave":     {"give"},
+	"given":    {"give"},
+	"ate":      {"eat"},
+	"eaten":    {"eat"},
+	"wrote":    {"write"},
+	"written":  {"write"},
+	"better":   {"good", "well"},
+	"best":     {"good", "well"},
+	"worse":    {"bad"},
+	"worst":    {"bad"},
+}
+
+var englishSuffixRules = []suffixRule{
+	{"ies", "y", 2},
+	{"es", "", 2},
+	{"s", "", 2},
+	{"ied", "y", 2},
+	{"ed", "", 2},
+	{"ed", "e", 2},
+	{"ing", "", 2},
+	{"ing", "e", 2},
+	{"ier", "y", 2},
+	{"iest", "y", 2},
+	{"er", "", 2},
+	{"er", "e", 2},
+	{"est", "", 2},
+	{"est", "e", 2},
+	{"ily", "y", 2},
+	{"ly", "", 3},
+}
+
+type english struct{}
+
+func (english) Lemmas(word string) []string {
+	result := append([]string{}, englishIrregular[word]...)
+	if strings.HasSuffix(word, "ss") {
+		// "class", "less"
+		return result
+	}
+	for _, stem := range applySuffixRules(word, englishSuffixRules) {
+		result = append(result, stem)
+		// running -> run, stopped -> stop
+		n := len(stem)
+		if n >= 3 && stem[n-1] == stem[n-2] && !strings.ContainsRune("aeiouls", rune(stem[n-1])) {
+			result = append(result, stem[:n-1])
+		}
+	}
+	return result
+}
+
+const zwnj = "‌"
+
+var persianSuffixRules = []suffixRule{
+	{"هایی", "", 2},
+	{"های", "", 2},
+	{"ها", "", 2},
+	{"ترین", "", 2},
+	{"تر", "", 2},
+	{"یان", "", 2},
+	{"گان", "ه", 2},
+	{"ان", "", 2},
+	{"ات", "", 2},
+	{"ین", "", 2},
+	{"ی", "", 2},
+}
+
+type persian struct{}
+
+func (persian) Lemmas(word string) []string {
+	result := []string{}
+	for _, stem := range applySuffixRules(word, persianSuffixRules) {
+		// کتاب‌ها -> کتاب
+		result = append(result, strings.TrimSuffix(stem, zwnj))
+	}
+	return result
+}
@@ -0,0 +1,266 @@
+package lemma
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type affixRule struct {
+	flag      string
+	strip     string
+	affix     string
+	condition *regexp.Regexp
+}
+
+// Hunspell is a lemmatizer based on affix rules (.aff file) and
+// optionally a word list (.dic file), only words that are in the list
+// and have the flag of the rule are returned if word list is not empty
+type Hunspell struct {
+	flagMode string
+	prefixes []*affixRule
+	suffixes []*affixRule
+	words    map[string]map[string]bool
+}
+
+// LoadHunspell loads .aff file and (if exists) .dic file with same name
+func LoadHunspell(affPath string) (*Hunspell, error) {
+	affFile, err := os.Open(affPath)
+	if err != nil {
+		return nil, err
+	}
+	defer affFile.Close()
+	var dicReader io.Reader
+	dicPath := strings.TrimSuffix(affPath, filepath.Ext(affPath)) + ".dic"
+	dicFile, err := os.Open(dicPath)
+	if err == nil {
+		defer dicFile.Close()
+		dicReader = dicFile
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return ParseHunspell(affFile, dicReader)
+}
+
+// LoadDir loads all .aff files in directory (sorted by file name)
+func LoadDir(dir string) ([]*Hunspell, error) {
+	affPaths, err := filepath.Glob(filepath.Join(dir, "*.aff"))
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*Hunspell, 0, len(affPaths))
+	for _, affPath := range affPaths {
+		h, err := LoadHunspell(affPath)
+		if err != nil {
+			return nil, fmt.Errorf("error loading %#v: %w", affPath, err)
+		}
+		list = append(list, h)
+	}
+	return list, nil
+}
+
+// ParseHunspell parses affix rules and word list, dic can be nil
+func ParseHunspell(aff io.Reader, dic io.Reader) (*Hunspell, error) {
+	h := &Hunspell{
+		words: map[string]map[string]bool{},
+	}
+	err := h.parseAff(aff)
+	if err != nil {
+		return nil, err
+	}
+	if dic != nil {
+		err = h.parseDic(dic)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+func (h *Hunspell) parseAff(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	// header lines ("SFX S Y 4") are followed by rule lines,
+	// we don't need to count them since rule lines have more fields
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "FLAG":
+			if len(fields) > 1 {
+				h.flagMode = fields[1]
+			}
+		case "PFX", "SFX":
+			if len(fields) < 5 {
+				// header line
+				continue
+			}
+			rule, err := newAffixRule(fields, fields[0] == "PFX")
+			if err != nil {
+				return fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			if fields[0] == "PFX" {
+				h.prefixes = append(h.prefixes, rule)
+			} else {
+				h.suffixes = append(h.suffixes, rule)
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+func newAffixRule(fields []string, prefix bool) (*affixRule, error) {
+	strip := fields[2]
+	if strip == "0" {
+		strip = ""
+	}
+	affix := fields[3]
+	// continuation flags are not supported
+	if i := strings.IndexByte(affix, '/'); i >= 0 {
+		affix = affix[:i]
+	}
+	if affix == "0" {
+		affix = ""
+	}
+	pattern := conditionPattern(fields[4])
+	if prefix {
+		pattern = "^" + pattern
+	} else {
+		pattern += "$"
+	}
+	condition, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &affixRule{
+		flag:      fields[1],
+		strip:     strip,
+		affix:     affix,
+		condition: condition,
+	}, nil
+}
+
+// conditionPattern converts condition of affix rule to regexp,
+// conditions only have character classes and "." in addition to letters
+func conditionPattern(condition string) string {
+	var sb strings.Builder
+	inClass := false
+	for _, c := range condition {
+		switch {
+		case c == '[':
+			inClass = true
+			sb.WriteRune(c)
+		case c == ']':
+			inClass = false
+			sb.WriteRune(c)
+		case c == '.' && !inClass:
+			sb.WriteRune(c)
+		case inClass:
+			sb.WriteRune(c)
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
+
+func (h *Hunspell) parseDic(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if first {
+			first = false
+			// first line is the (approximate) number of words
+			if _, err := strconv.Atoi(line); err == nil {
+				continue
+			}
+		}
+		if line == "" {
+			continue
+		}
+		// morphological fields are separated with tab or space
+		if i := strings.IndexAny(line, "\t "); i >= 0 {
+			line = line[:i]
+		}
+		word, flags, _ := strings.Cut(line, "/")
+		word = strings.ToLower(word)
+		flagSet := h.words[word]
+		if flagSet == nil {
+			flagSet = map[string]bool{}
+			h.words[word] = flagSet
+		}
+		for _, flag := range h.splitFlags(flags) {
+			flagSet[flag] = true
+		}
+	}
+	return scanner.Err()
+}
+
+func (h *Hunspell) splitFlags(flags string) []string {
+	if flags == "" {
+		return nil
+	}
+	switch h.flagMode {
+	case "long":
+		runes := []rune(flags)
+		result := make([]string, 0, len(runes)/2)
+		for i := 0; i+2 <= len(runes); i += 2 {
+			result = append(result, string(runes[i:i+2]))
+		}
+		return result
+	case "num":
+		return strings.Split(flags, ",")
+	}
+	result := make([]string, 0, len(flags))
+	for _, c := range flags {
+		result = append(result, string(c))
+	}
+	return result
+}
+
+func (h *Hunspell) accept(stem string, rule *affixRule) bool {
+	if stem == "" || !rule.condition.MatchString(stem) {
+		return false
+	}
+	if len(h.words) == 0 {
+		return true
+	}
+	return h.words[stem][rule.flag]
+}
+
+// Lemmas implements Lemmatizer
+func (h *Hunspell) Lemmas(word string) []string {
+	result := []string{}
+	for _, rule := range h.suffixes {
+		if !strings.HasSuffix(word, rule.affix) || len(word) == len(rule.affix) {
+			continue
+		}
+		stem := word[:len(word)-len(rule.affix)] + rule.strip
+		if h.accept(stem, rule) {
+			result = append(result, stem)
+		}
+	}
+	for _, rule := range h.prefixes {
+		if !strings.HasPrefix(word, rule.affix) || len(word) == len(rule.affix) {
+			continue
+		}
+		stem := rule.strip + word[len(rule.affix):]
+		if h.accept(stem, rule) {
+			result = append(result, stem)
+		}
+	}
+	return result
+}
@@ -0,0 +1,46 @@
+// Package lemma finds candidate base forms (lemmas) of inflected words
+// using Hunspell-style affix rules or built-in stemmers.
+// Candidates are not verified, caller is expected to look them up
+// and ignore the ones that do not exist
+package lemma
+
+import (
+	"strings"
+)
+
+// Lemmatizer returns candidate base forms of a lowercase word
+type Lemmatizer interface {
+	Lemmas(word string) []string
+}
+
+// Candidates returns unique lemmas of word from all lemmatizers
+// (in the given order), excluding the word itself
+func Candidates(word string, list []Lemmatizer) []string {
+	word = strings.ToLower(strings.TrimSpace(word))
+	if word == "" {
+		return nil
+	}
+	seen := map[string]bool{word: true}
+	result := []string{}
+	for _, lem := range list {
+		for _, lemma := range lem.Lemmas(word) {
+			if lemma == "" || seen[lemma] {
+				continue
+			}
+			seen[lemma] = true
+			result = append(result, lemma)
+		}
+	}
+	return result
+}
+
+// Builtin returns built-in lemmatizer by language code, or nil
+func Builtin(lang string) Lemmatizer {
+	switch strings.ToLower(lang) {
+	case "en", "eng", "english":
+		return english{}
+	case "fa", "fas", "per", "persian":
+		return persian{}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package lemma
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+const testAff = `
+SET UTF-8
+
+SFX S Y 3
+SFX S   y     ies        [^aeiou]y
+SFX S   0     s          [^sxzhy]
+SFX S   0     es         [sxzh]
+
+SFX G Y 2
+SFX G   e     ing        e
+SFX G   0     ing        [^e]
+
+PFX U Y 1
+PFX U   0     un         .
+`
+
+const testDic = `5
+city/S
+box/S
+make/GS
+walk/GS
+do/U
+`
+
+func TestHunspell(t *testing.T) {
+	is := is.New(t)
+	h, err := ParseHunspell(strings.NewReader(testAff), strings.NewReader(testDic))
+	is.NotErr(err)
+	is.Equal(h.Lemmas("cities"), []string{"city"})
+	is.Equal(h.Lemmas("boxes"), []string{"box"})
+	is.Equal(h.Lemmas("making"), []string{"make"})
+	is.Equal(h.Lemmas("walking"), []string{"walk"})
+	is.Equal(h.Lemmas("walks"), []string{"walk"})
+	is.Equal(h.Lemmas("undo"), []string{"do"})
+	// "citys" is not accepted by condition, "runs" is not in word list
+	is.Equal(len(h.Lemmas("citys")), 0)
+	is.Equal(len(h.Lemmas("runs")), 0)
+}
+
+func TestHunspellNoDic(t *testing.T) {
+	is := is.New(t)
+	h, err := ParseHunspell(strings.NewReader(testAff), nil)
+	is.NotErr(err)
+	is.Equal(h.Lemmas("runs"), []string{"run"})
+}
+
+func TestEnglish(t *testing.T) {
+	is := is.New(t)
+	lem := Builtin("en")
+	is.True(slices.Contains(lem.Lemmas("running"), "run"))
+	is.True(slices.Contains(lem.Lemmas("children"), "child"))
+	is.True(slices.Contains(lem.Lemmas("cities"), "city"))
+	is.True(slices.Contains(lem.Lemmas("stopped"), "stop"))
+	is.True(slices.Contains(lem.Lemmas("making"), "make"))
+	is.Equal(len(lem.Lemmas("class")), 0)
+}
+
+func TestPersian(t *testing.T) {
+	is := is.New(t)
+	lem := Builtin("fa")
+	is.True(slices.Contains(lem.Lemmas("کتاب‌ها"), "کتاب"))
+	is.True(slices.Contains(lem.Lemmas("درختان"), "درخت"))
+	is.True(slices.Contains(lem.Lemmas("بزرگ‌ترین"), "بزرگ"))
+}
+
+func TestCandidates(t *testing.T) {
+	is := is.New(t)
+	list := []Lemmatizer{Builtin("en"), Builtin("en")}
+	candidates := Candidates("Walks", list)
+	is.Equal(candidates, []string{"walk"})
+	is.Equal(len(Candidates(" ", list)), 0)
+}
@@ -0,0 +1,509 @@
+package mdict
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/adler32"
+	"html"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+var headerAttrRE = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+var errEncrypted = errors.New("encrypted MDict files are not supported")
+
+// block compression types
+const (
+	compNone = 0
+	compLZO  = 1
+	compZlib = 2
+)
+
+type key struct {
+	// offset of record in decompressed records
+	offset uint64
+	text   string
+}
+
+type recordBlock struct {
+	// offset of compressed block in file
+	fileOffset   int64
+	compSize     uint64
+	decompOffset uint64
+	decompSize   uint64
+}
+
+// mdictFile is an open .mdx or .mdd file, keys are loaded in memory
+// and records are read from file when needed
+type mdictFile struct {
+	file   *os.File
+	header map[string]string
+
+	// width of numbers, 8 for version 2 and 4 for version 1
+	numWidth int
+	v2       bool
+	// width of characters, 2 for UTF-16 and 1 otherwise
+	charWidth int
+	enc       encoding.Encoding
+
+	keys         []key
+	recordBlocks []recordBlock
+	recordsSize  uint64
+
+	// last decompressed record block, as records are often read
+	// from the same block
+	cacheMu    sync.Mutex
+	cacheIndex int
+	cacheData  []byte
+}
+
+// fileReader reads sequential parts of file
+type fileReader struct {
+	file *os.File
+	pos  int64
+}
+
+func (r *fileReader) read(size uint64) ([]byte, error) {
+	if size > 1<<31 {
+		return nil, fmt.Errorf("invalid size %d at %d", size, r.pos)
+	}
+	buf := make([]byte, size)
+	_, err := r.file.ReadAt(buf, r.pos)
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	r.pos += int64(size)
+	return buf, nil
+}
+
+// openFile opens an MDict file, reads header and keys.
+// Keys of .mdd files are always in UTF-16, encoding of .mdx files
+// is given in header
+func openFile(path string, isMDD bool) (*mdictFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &mdictFile{file: file, cacheIndex: -1}
+	err = f.readAll(isMDD)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+func (f *mdictFile) Close() error {
+	return f.file.Close()
+}
+
+func (f *mdictFile) readAll(isMDD bool) error {
+	r := &fileReader{file: f.file}
+	err := f.readHeader(r)
+	if err != nil {
+		return err
+	}
+	encName := f.header["Encoding"]
+	if isMDD {
+		encName = "UTF-16"
+	}
+	err = f.setEncoding(encName)
+	if err != nil {
+		return err
+	}
+	err = f.readKeys(r)
+	if err != nil {
+		return err
+	}
+	return f.readRecordBlocks(r)
+}
+
+func (f *mdictFile) readHeader(r *fileReader) error {
+	sizeBytes, err := r.read(4)
+	if err != nil {
+		return err
+	}
+	headerBytes, err := r.read(uint64(binary.BigEndian.Uint32(sizeBytes)))
+	if err != nil {
+		return err
+	}
+	checksum, err := r.read(4)
+	if err != nil {
+		return err
+	}
+	if adler32.Checksum(headerBytes) != binary.LittleEndian.Uint32(checksum) {
+		return errors.New("header checksum mismatch")
+	}
+	headerText, err := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewDecoder().Bytes(headerBytes)
+	if err != nil {
+		return err
+	}
+	f.header = map[string]string{}
+	for _, match := range headerAttrRE.FindAllSubmatch(headerText, -1) {
+		f.header[string(match[1])] = html.UnescapeString(string(match[2]))
+	}
+	version, _ := strconv.ParseFloat(f.header["GeneratedByEngineVersion"], 64)
+	f.v2 = version >= 2
+	f.numWidth = 4
+	if f.v2 {
+		f.numWidth = 8
+	}
+	if f.encrypted()&1 != 0 {
+		return errEncrypted
+	}
+	return nil
+}
+
+// encrypted returns encryption flags: 1 means keys are encrypted with
+// registration code (not supported), 2 means key block info is encrypted
+func (f *mdictFile) encrypted() int {
+	value := f.header["Encrypted"]
+	switch strings.ToLower(value) {
+	case "", "no":
+		return 0
+	case "yes":
+		return 1
+	}
+	flags, _ := strconv.Atoi(value)
+	return flags
+}
+
+func (f *mdictFile) setEncoding(name string) error {
+	f.charWidth = 1
+	switch strings.ToUpper(name) {
+	case "", "UTF-8", "UTF8":
+		return nil
+	case "UTF-16", "UTF-16LE":
+		f.charWidth = 2
+		f.enc = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+		return nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return fmt.Errorf("unsupported encoding %#v", name)
+	}
+	f.enc = enc
+	return nil
+}
+
+func (f *mdictFile) decodeText(data []byte) string {
+	if f.enc == nil {
+		return string(data)
+	}
+	text, err := f.enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return string(data)
+	}
+	return string(text)
+}
+
+// numbers reads count big-endian numbers of numWidth
+func (f *mdictFile) numbers(data []byte, count int) []uint64 {
+	nums := make([]uint64, count)
+	for i := range nums {
+		if f.numWidth == 8 {
+			nums[i] = binary.BigEndian.Uint64(data[i*8:])
+		} else {
+			nums[i] = uint64(binary.BigEndian.Uint32(data[i*4:]))
+		}
+	}
+	return nums
+}
+
+type keyBlockInfo struct {
+	entryCount uint64
+	compSize   uint64
+	decompSize uint64
+}
+
+func (f *mdictFile) readKeys(r *fileReader) error {
+	count := 4
+	if f.v2 {
+		count = 5
+	}
+	numBytes, err := r.read(uint64(count * f.numWidth))
+	if err != nil {
+		return err
+	}
+	nums := f.numbers(numBytes, count)
+	if f.v2 {
+		checksum, err := r.read(4)
+		if err != nil {
+			return err
+		}
+		if adler32.Checksum(numBytes) != binary.BigEndian.Uint32(checksum) {
+			return errors.New("key section checksum mismatch")
+		}
+		// remove decompressed size of key block info
+		nums = append(nums[:2], nums[3:]...)
+	}
+	blockCount, entryCount, infoSize, blocksSize := nums[0], nums[1], nums[2], nums[3]
+	infoData, err := r.read(infoSize)
+	if err != nil {
+		return err
+	}
+	infoList, err := f.parseKeyBlockInfo(infoData, blockCount)
+	if err != nil {
+		return err
+	}
+	blocksData, err := r.read(blocksSize)
+	if err != nil {
+		return err
+	}
+	f.keys = make([]key, 0, min(entryCount, 1<<24))
+	for _, info := range infoList {
+		if info.compSize > uint64(len(blocksData)) {
+			return errors.New("invalid key block size")
+		}
+		block, err := decompressBlock(blocksData[:info.compSize], info.decompSize)
+		if err != nil {
+			return fmt.Errorf("error in key block: %w", err)
+		}
+		blocksData = blocksData[info.compSize:]
+		err = f.parseKeyBlock(block)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *mdictFile) parseKeyBlockInfo(data []byte, blockCount uint64) ([]keyBlockInfo, error) {
+	if f.v2 {
+		if len(data) < 8 || !bytes.Equal(data[:4], []byte{2, 0, 0, 0}) {
+			return nil, errors.New("invalid key block info")
+		}
+		if f.encrypted()&2 != 0 {
+			data = decryptKeyBlockInfo(data)
+		}
+		var err error
+		data, err = decompressBlock(data, 0)
+		if err != nil {
+			return nil, fmt.Errorf("error in key block info: %w", err)
+		}
+	}
+	// text head/tail size is uint16 in v2, and uint8 in v1
+	sizeWidth := 1
+	// there is a null terminator after head and tail text in v2
+	terminator := 0
+	if f.v2 {
+		sizeWidth = 2
+		terminator = f.charWidth
+	}
+	infoList := make([]keyBlockInfo, 0, min(blockCount, 1<<20))
+	pos := 0
+	need := func(n int) bool {
+		return pos+n <= len(data)
+	}
+	readNum := func() uint64 {
+		n := f.numbers(data[pos:], 1)[0]
+		pos += f.numWidth
+		return n
+	}
+	skipText := func() bool {
+		if !need(sizeWidth) {
+			return false
+		}
+		size := int(data[pos])
+		if sizeWidth == 2 {
+			size = int(binary.BigEndian.Uint16(data[pos:]))
+		}
+		pos += sizeWidth
+		size = size*f.charWidth + terminator
+		if !need(size) {
+			return false
+		}
+		pos += size
+		return true
+	}
+	for range blockCount {
+		if !need(f.numWidth) {
+			return nil, errors.New("invalid key block info")
+		}
+		info := keyBlockInfo{entryCount: readNum()}
+		if !skipText() || !skipText() || !need(2*f.numWidth) {
+			return nil, errors.New("invalid key block info")
+		}
+		info.compSize = readNum()
+		info.decompSize = readNum()
+		infoList = append(infoList, info)
+	}
+	return infoList, nil
+}
+
+// parseKeyBlock parses a decompressed key block, which has record offset
+// and null-terminated text of each key
+func (f *mdictFile) parseKeyBlock(data []byte) error {
+	pos := 0
+	for pos < len(data) {
+		if pos+f.numWidth > len(data) {
+			return errors.New("invalid key block")
+		}
+		offset := f.numbers(data[pos:], 1)[0]
+		pos += f.numWidth
+		end := pos
+		for end+f.charWidth <= len(data) {
+			if data[end] == 0 && (f.charWidth == 1 || data[end+1] == 0) {
+				break
+			}
+			end += f.charWidth
+		}
+		f.keys = append(f.keys, key{
+			offset: offset,
+			text:   f.decodeText(data[pos:end]),
+		})
+		pos = end + f.charWidth
+	}
+	return nil
+}
+
+func (f *mdictFile) readRecordBlocks(r *fileReader) error {
+	numBytes, err := r.read(uint64(4 * f.numWidth))
+	if err != nil {
+		return err
+	}
+	nums := f.numbers(numBytes, 4)
+	blockCount, infoSize := nums[0], nums[2]
+	if infoSize != blockCount*uint64(2*f.numWidth) {
+		return errors.New("invalid record block info size")
+	}
+	infoData, err := r.read(infoSize)
+	if err != nil {
+		return err
+	}
+	sizes := f.numbers(infoData, int(2*blockCount))
+	f.recordBlocks = make([]recordBlock, blockCount)
+	fileOffset := r.pos
+	decompOffset := uint64(0)
+	for i := range f.recordBlocks {
+		block := recordBlock{
+			fileOffset:   fileOffset,
+			compSize:     sizes[2*i],
+			decompOffset: decompOffset,
+			decompSize:   sizes[2*i+1],
+		}
+		f.recordBlocks[i] = block
+		fileOffset += int64(block.compSize)
+		decompOffset += block.decompSize
+	}
+	f.recordsSize = decompOffset
+	return nil
+}
+
+func (f *mdictFile) readRecordBlock(index int) ([]byte, error) {
+	f.cacheMu.Lock()
+	defer f.cacheMu.Unlock()
+	if f.cacheIndex == index {
+		return f.cacheData, nil
+	}
+	block := f.recordBlocks[index]
+	r := &fileReader{file: f.file, pos: block.fileOffset}
+	compData, err := r.read(block.compSize)
+	if err != nil {
+		return nil, err
+	}
+	data, err := decompressBlock(compData, block.decompSize)
+	if err != nil {
+		return nil, fmt.Errorf("error in record block: %w", err)
+	}
+	f.cacheIndex = index
+	f.cacheData = data
+	return data, nil
+}
+
+// record returns data of record of key at index
+func (f *mdictFile) record(index int) ([]byte, error) {
+	start := f.keys[index].offset
+	end := f.recordsSize
+	if index+1 < len(f.keys) {
+		end = f.keys[index+1].offset
+	}
+	blockIndex := sort.Search(len(f.recordBlocks), func(i int) bool {
+		block := f.recordBlocks[i]
+		return block.decompOffset+block.decompSize > start
+	})
+	if blockIndex == len(f.recordBlocks) || end < start {
+		return nil, errors.New("invalid record offset")
+	}
+	block := f.recordBlocks[blockIndex]
+	data, err := f.readRecordBlock(blockIndex)
+	if err != nil {
+		return nil, err
+	}
+	// records do not span multiple blocks
+	end = min(end, block.decompOffset+block.decompSize)
+	return data[start-block.decompOffset : end-block.decompOffset], nil
+}
+
+// decompressBlock decompresses a key or record block, that has 4 bytes
+// of compression type and 4 bytes of adler32 checksum at the beginning
+func decompressBlock(block []byte, decompSize uint64) ([]byte, error) {
+	if len(block) < 8 {
+		return nil, errors.New("block is too short")
+	}
+	compType := binary.LittleEndian.Uint32(block[:4])
+	checksum := binary.BigEndian.Uint32(block[4:8])
+	block = block[8:]
+	var data []byte
+	var err error
+	switch compType {
+	case compNone:
+		data = block
+	case compLZO:
+		data, err = lzoDecompress(block, int(decompSize))
+	case compZlib:
+		var reader io.ReadCloser
+		reader, err = zlib.NewReader(bytes.NewReader(block))
+		if err == nil {
+			data, err = io.ReadAll(reader)
+			reader.Close()
+		}
+	default:
+		return nil, fmt.Errorf("unsupported compression type %#x", compType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if adler32.Checksum(data) != checksum {
+		return nil, errors.New("block checksum mismatch")
+	}
+	if decompSize > 0 && uint64(len(data)) != decompSize {
+		return nil, errors.New("invalid decompressed block size")
+	}
+	return data, nil
+}
+
+// decryptKeyBlockInfo decrypts key block info (after the first 8 bytes),
+// with a key made from its checksum
+func decryptKeyBlockInfo(data []byte) []byte {
+	cryptKey := ripemd128(binary.LittleEndian.AppendUint32(
+		append([]byte{}, data[4:8]...),
+		0x3695,
+	))
+	out := make([]byte, len(data))
+	copy(out, data[:8])
+	previous := byte(0x36)
+	for i := 8; i < len(data); i++ {
+		b := data[i]
+		j := i - 8
+		out[i] = (b>>4 | b<<4) ^ previous ^ byte(j) ^ cryptKey[j%len(cryptKey)]
+		previous = b
+	}
+	return out
+}
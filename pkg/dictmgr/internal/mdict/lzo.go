@@ -0,0 +1,225 @@
+package mdict
+
+import (
+	"errors"
+)
+
+var errLZOCorrupt = errors.New("corrupt LZO data")
+
+// lzoDecompress decompresses LZO1X data (without any header),
+// outSize is the size of decompressed data
+func lzoDecompress(in []byte, outSize int) ([]byte, error) {
+	d := &lzoDecoder{in: in, out: make([]byte, 0, outSize)}
+	err := d.run()
+	if err != nil {
+		return nil, err
+	}
+	if len(d.out) != outSize {
+		return nil, errLZOCorrupt
+	}
+	return d.out, nil
+}
+
+type lzoDecoder struct {
+	in  []byte
+	ip  int
+	out []byte
+}
+
+func (d *lzoDecoder) readByte() (int, error) {
+	if d.ip >= len(d.in) {
+		return 0, errLZOCorrupt
+	}
+	b := d.in[d.ip]
+	d.ip++
+	return int(b), nil
+}
+
+// readLength reads a length with zero bytes, each adding 255,
+// and adds base to it
+func (d *lzoDecoder) readLength(base int) (int, error) {
+	t := 0
+	for {
+		b, err := d.readByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != 0 {
+			return t + base + b, nil
+		}
+		t += 255
+	}
+}
+
+func (d *lzoDecoder) readLE16() (int, error) {
+	if d.ip+2 > len(d.in) {
+		return 0, errLZOCorrupt
+	}
+	v := int(d.in[d.ip]) | int(d.in[d.ip+1])<<8
+	d.ip += 2
+	return v, nil
+}
+
+func (d *lzoDecoder) copyLiterals(count int) error {
+	if d.ip+count > len(d.in) || len(d.out)+count > cap(d.out) {
+		return errLZOCorrupt
+	}
+	d.out = append(d.out, d.in[d.ip:d.ip+count]...)
+	d.ip += count
+	return nil
+}
+
+// copyMatch copies count bytes from dist bytes back in output,
+// byte by byte since they can overlap
+func (d *lzoDecoder) copyMatch(dist int, count int) error {
+	pos := len(d.out) - dist
+	if pos < 0 || dist <= 0 || len(d.out)+count > cap(d.out) {
+		return errLZOCorrupt
+	}
+	for i := range count {
+		d.out = append(d.out, d.out[pos+i])
+	}
+	return nil
+}
+
+// run is a port of lzo1x_decompress_safe, states are the labels of
+// the original code
+func (d *lzoDecoder) run() error {
+	const (
+		stateLiteral = iota
+		stateFirstLiteralRun
+		stateMatch
+		stateMatchNext
+	)
+	var t int
+	state := stateLiteral
+	if len(d.in) > 0 && d.in[0] > 17 {
+		d.ip++
+		t = int(d.in[0]) - 17
+		if t < 4 {
+			state = stateMatchNext
+		} else {
+			if err := d.copyLiterals(t); err != nil {
+				return err
+			}
+			state = stateFirstLiteralRun
+		}
+	}
+	for {
+		var err error
+		switch state {
+		case stateLiteral:
+			t, err = d.readByte()
+			if err != nil {
+				return err
+			}
+			if t >= 16 {
+				state = stateMatch
+				continue
+			}
+			if t == 0 {
+				t, err = d.readLength(15)
+				if err != nil {
+					return err
+				}
+			}
+			if err := d.copyLiterals(t + 3); err != nil {
+				return err
+			}
+			state = stateFirstLiteralRun
+			continue
+
+		case stateFirstLiteralRun:
+			t, err = d.readByte()
+			if err != nil {
+				return err
+			}
+			if t >= 16 {
+				state = stateMatch
+				continue
+			}
+			b, err := d.readByte()
+			if err != nil {
+				return err
+			}
+			if err := d.copyMatch(1+0x0800+(t>>2)+(b<<2), 3); err != nil {
+				return err
+			}
+
+		case stateMatchNext:
+			if err := d.copyLiterals(t); err != nil {
+				return err
+			}
+			t, err = d.readByte()
+			if err != nil {
+				return err
+			}
+			state = stateMatch
+			continue
+
+		case stateMatch:
+			switch {
+			case t >= 64:
+				b, err := d.readByte()
+				if err != nil {
+					return err
+				}
+				if err := d.copyMatch(1+((t>>2)&7)+(b<<3), (t>>5)+1); err != nil {
+					return err
+				}
+			case t >= 32:
+				t &= 31
+				if t == 0 {
+					t, err = d.readLength(31)
+					if err != nil {
+						return err
+					}
+				}
+				v, err := d.readLE16()
+				if err != nil {
+					return err
+				}
+				if err := d.copyMatch(1+(v>>2), t+2); err != nil {
+					return err
+				}
+			case t >= 16:
+				dist := (t & 8) << 11
+				t &= 7
+				if t == 0 {
+					t, err = d.readLength(7)
+					if err != nil {
+						return err
+					}
+				}
+				v, err := d.readLE16()
+				if err != nil {
+					return err
+				}
+				dist += v >> 2
+				if dist == 0 {
+					// end of stream
+					return nil
+				}
+				if err := d.copyMatch(dist+0x4000, t+2); err != nil {
+					return err
+				}
+			default:
+				b, err := d.readByte()
+				if err != nil {
+					return err
+				}
+				if err := d.copyMatch(1+(t>>2)+(b<<2), 2); err != nil {
+					return err
+				}
+			}
+		}
+		// match done, copy 0 to 3 literals given by the last 2 bits
+		// of the byte before last
+		t = int(d.in[d.ip-2]) & 3
+		if t == 0 {
+			state = stateLiteral
+		} else {
+			state = stateMatchNext
+		}
+	}
+}
@@ -0,0 +1,370 @@
+// Package mdict implements common.Dictionary for MDict dictionaries:
+// an .mdx file and optional .mdd files (like "name.mdd", "name.1.mdd")
+// next to it, that have resources (images, audio files, etc).
+// Resources are read from .mdd files by ReadResource when needed,
+// instead of being extracted to a ResourceDir
+package mdict
+
+import (
+	"crypto/sha1"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/termsearch"
+	common "github.com/ilius/go-dict-commons"
+)
+
+// prefix of records that redirect to another entry
+const linkPrefix = "@@@LINK="
+
+// maximum number of @@@LINK redirects that are followed
+const maxLinkDepth = 5
+
+var hrefEntryRE = regexp.MustCompile(` href="entry://([^#"][^"]*)"`)
+
+// dictionaryImp is an MDict dictionary
+type dictionaryImp struct {
+	path        string
+	name        string
+	description string
+
+	mu       sync.RWMutex
+	disabled bool
+	mdx      *mdictFile
+	mddList  []*mdictFile
+
+	// index of first key with each text, for following links
+	keyIndex map[string]int
+
+	// index of each resource in mddList, by normalized path
+	resIndex map[string]resLocation
+}
+
+type resLocation struct {
+	mdd   *mdictFile
+	index int
+}
+
+var _ common.Dictionary = (*dictionaryImp)(nil)
+
+// NewDictionary reads header of .mdx file, without loading keys
+func NewDictionary(path string) (*dictionaryImp, error) {
+	f, err := openFile(path, false)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d := &dictionaryImp{
+		path:        path,
+		name:        f.header["Title"],
+		description: strings.TrimSpace(f.header["Description"]),
+	}
+	if d.name == "" || d.name == "Title (No HTML code allowed)" {
+		d.name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if d.description == "<font size=5 color=red>Paste the description of this product in HTML source code format here</font>" {
+		d.description = ""
+	}
+	return d, nil
+}
+
+// mddPaths returns paths of existing .mdd files of dictionary
+func (d *dictionaryImp) mddPaths() []string {
+	base := strings.TrimSuffix(d.path, filepath.Ext(d.path))
+	paths := []string{}
+	for i := 0; ; i++ {
+		fpath := base + ".mdd"
+		if i > 0 {
+			fpath = base + "." + strconv.Itoa(i) + ".mdd"
+		}
+		if _, err := os.Stat(fpath); err != nil {
+			return paths
+		}
+		paths = append(paths, fpath)
+	}
+}
+
+func (d *dictionaryImp) Disabled() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.disabled
+}
+
+func (d *dictionaryImp) SetDisabled(disabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.disabled = disabled
+}
+
+func (d *dictionaryImp) Loaded() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.mdx != nil
+}
+
+// Load reads keys of .mdx and .mdd files
+func (d *dictionaryImp) Load() error {
+	mdx, err := openFile(d.path, false)
+	if err != nil {
+		return err
+	}
+	keyIndex := make(map[string]int, len(mdx.keys))
+	for index, k := range mdx.keys {
+		if _, ok := keyIndex[k.text]; !ok {
+			keyIndex[k.text] = index
+		}
+	}
+	mddList := []*mdictFile{}
+	resIndex := map[string]resLocation{}
+	for _, fpath := range d.mddPaths() {
+		mdd, err := openFile(fpath, true)
+		if err != nil {
+			slog.Error("error opening mdd file", "err", err, "dictName", d.name)
+			continue
+		}
+		mddList = append(mddList, mdd)
+		for index, k := range mdd.keys {
+			resPath := normalizeResPath(k.text)
+			if _, ok := resIndex[resPath]; !ok {
+				resIndex[resPath] = resLocation{mdd: mdd, index: index}
+			}
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closeFiles()
+	d.mdx = mdx
+	d.mddList = mddList
+	d.keyIndex = keyIndex
+	d.resIndex = resIndex
+	return nil
+}
+
+// normalizeResPath converts resource path of .mdd (like `\img\a.png`) or
+// article (like "img/a.png") to a lowercase relative path with slashes
+func normalizeResPath(resPath string) string {
+	resPath = strings.ReplaceAll(resPath, `\`, "/")
+	resPath = strings.TrimLeft(resPath, "/")
+	return strings.ToLower(resPath)
+}
+
+// closeFiles closes files, mu must be locked
+func (d *dictionaryImp) closeFiles() {
+	if d.mdx != nil {
+		d.mdx.Close()
+	}
+	for _, mdd := range d.mddList {
+		mdd.Close()
+	}
+	d.mdx = nil
+	d.mddList = nil
+	d.keyIndex = nil
+	d.resIndex = nil
+}
+
+func (d *dictionaryImp) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closeFiles()
+}
+
+func (d *dictionaryImp) DictName() string {
+	return d.name
+}
+
+func (d *dictionaryImp) EntryCount() (int, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.mdx == nil {
+		return 0, errors.New("dictionary is not loaded")
+	}
+	return len(d.mdx.keys), nil
+}
+
+func (d *dictionaryImp) Description() string {
+	return d.description
+}
+
+func (d *dictionaryImp) ResourceDir() string {
+	return ""
+}
+
+func (d *dictionaryImp) ResourceURL() string {
+	return ""
+}
+
+// ReadResource returns content of a resource file from .mdd files, or
+// from the directory of .mdx file (like .css and .js files)
+func (d *dictionaryImp) ReadResource(relPath string) ([]byte, error) {
+	d.mu.RLock()
+	loc, ok := d.resIndex[normalizeResPath(relPath)]
+	d.mu.RUnlock()
+	if ok {
+		return loc.mdd.record(loc.index)
+	}
+	dir := filepath.Dir(d.path)
+	fpath := filepath.Join(dir, filepath.FromSlash(relPath))
+	if !strings.HasPrefix(fpath, dir+string(filepath.Separator)) || fpath == d.path {
+		return nil, fs.ErrNotExist
+	}
+	return os.ReadFile(fpath)
+}
+
+func (d *dictionaryImp) IndexPath() string {
+	return d.path
+}
+
+func (d *dictionaryImp) IndexFileSize() uint64 {
+	fi, err := os.Stat(d.path)
+	if err != nil {
+		return 0
+	}
+	return uint64(fi.Size())
+}
+
+func (d *dictionaryImp) InfoPath() string {
+	return ""
+}
+
+func (d *dictionaryImp) CalcHash() ([]byte, error) {
+	file, err := os.Open(d.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	hash := sha1.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// items reads record of key at index, following @@@LINK redirects
+func (d *dictionaryImp) items(mdx *mdictFile, index int) []*common.SearchResultItem {
+	var text string
+	for range maxLinkDepth {
+		data, err := mdx.record(index)
+		if err != nil {
+			slog.Error("error reading record", "err", err, "index", index, "dictName", d.name)
+			return nil
+		}
+		text = strings.TrimRight(mdx.decodeText(data), "\x00\r\n ")
+		target, ok := strings.CutPrefix(text, linkPrefix)
+		if !ok {
+			break
+		}
+		d.mu.RLock()
+		targetIndex, ok := d.keyIndex[strings.TrimSpace(target)]
+		d.mu.RUnlock()
+		if !ok {
+			break
+		}
+		index = targetIndex
+	}
+	if target, ok := strings.CutPrefix(text, linkPrefix); ok {
+		target = strings.TrimSpace(target)
+		text = `<a href="entry://` + target + `">` + target + "</a>"
+	}
+	itemType := 'h'
+	if strings.EqualFold(mdx.header["Format"], "Text") {
+		itemType = 'm'
+	} else {
+		// MDict links to other entries are like entry://word
+		text = hrefEntryRE.ReplaceAllString(text, ` href="bword://$1"`)
+	}
+	return []*common.SearchResultItem{{
+		Type: itemType,
+		Data: []byte(text),
+	}}
+}
+
+// entryList implements termsearch.Entries
+type entryList struct {
+	d   *dictionaryImp
+	mdx *mdictFile
+}
+
+func (l entryList) Len() int {
+	if l.mdx == nil {
+		return 0
+	}
+	return len(l.mdx.keys)
+}
+
+func (l entryList) Terms(index int) []string {
+	return []string{l.mdx.keys[index].text}
+}
+
+func (l entryList) Result(index int, score uint8) *common.SearchResultLow {
+	return &common.SearchResultLow{
+		F_Score: score,
+		F_Terms: l.Terms(index),
+		Items: func() []*common.SearchResultItem {
+			return l.d.items(l.mdx, index)
+		},
+		F_EntryIndex: uint64(index),
+	}
+}
+
+func (d *dictionaryImp) entryList() entryList {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return entryList{d: d, mdx: d.mdx}
+}
+
+func (d *dictionaryImp) EntryByIndex(index int) *common.SearchResultLow {
+	list := d.entryList()
+	if index < 0 || index >= list.Len() {
+		return nil
+	}
+	return list.Result(index, 0)
+}
+
+func (d *dictionaryImp) SearchFuzzy(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	return termsearch.Fuzzy(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchStartWith(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	return termsearch.StartWith(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchWordMatch(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	return termsearch.WordMatch(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchRegex(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, error) {
+	return termsearch.Regex(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchGlob(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, error) {
+	return termsearch.Glob(d.entryList(), query, workerCount, timeout)
+}
@@ -0,0 +1,245 @@
+package mdict
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash/adler32"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ilius/is/v2"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestRipemd128(t *testing.T) {
+	is := is.New(t)
+	is.Equal(hex.EncodeToString(ripemd128(nil)), "cdf26213a150dc3ecb610f18f6b38b46")
+	is.Equal(hex.EncodeToString(ripemd128([]byte("abc"))), "c14a12199c66e4ba84636b0f69144c77")
+	is.Equal(hex.EncodeToString(ripemd128([]byte("message digest"))), "9e327b3d6e523062afc1132d7df9d1b8")
+}
+
+func TestLZODecompress(t *testing.T) {
+	is := is.New(t)
+	data, err := lzoDecompress([]byte{17 + 5, 'h', 'e', 'l', 'l', 'o', 0x11, 0, 0}, 5)
+	is.NotErr(err)
+	is.Equal(string(data), "hello")
+
+	// "abc" and a match of 6 bytes at distance 3
+	data, err = lzoDecompress([]byte{17 + 3, 'a', 'b', 'c', 0xA8, 0, 0x11, 0, 0}, 9)
+	is.NotErr(err)
+	is.Equal(string(data), "abcabcabc")
+
+	_, err = lzoDecompress([]byte{17 + 3, 'a', 'b', 'c', 0xA8, 0x10}, 9)
+	is.Err(err)
+}
+
+// lzoLiterals compresses data (at most 238 bytes) as literals only
+func lzoLiterals(data []byte) []byte {
+	out := append([]byte{byte(17 + len(data))}, data...)
+	return append(out, 0x11, 0, 0)
+}
+
+func testBlock(compType uint32, data []byte) []byte {
+	block := binary.LittleEndian.AppendUint32(nil, compType)
+	block = binary.BigEndian.AppendUint32(block, adler32.Checksum(data))
+	switch compType {
+	case compZlib:
+		var buf bytes.Buffer
+		writer := zlib.NewWriter(&buf)
+		writer.Write(data)
+		writer.Close()
+		return append(block, buf.Bytes()...)
+	case compLZO:
+		return append(block, lzoLiterals(data)...)
+	}
+	return append(block, data...)
+}
+
+// encryptKeyBlockInfo is the reverse of decryptKeyBlockInfo
+func encryptKeyBlockInfo(data []byte) []byte {
+	cryptKey := ripemd128(binary.LittleEndian.AppendUint32(
+		append([]byte{}, data[4:8]...),
+		0x3695,
+	))
+	out := append([]byte{}, data...)
+	previous := byte(0x36)
+	for i := 8; i < len(data); i++ {
+		j := i - 8
+		b := data[i] ^ previous ^ byte(j) ^ cryptKey[j%len(cryptKey)]
+		out[i] = b>>4 | b<<4
+		previous = out[i]
+	}
+	return out
+}
+
+type testEntry struct {
+	key    string
+	record string
+}
+
+// writeTestFile writes a version 2 MDict file, records are split into
+// two blocks, compressed with zlib and LZO
+func writeTestFile(t *testing.T, path string, attrs string, isMDD bool, entries ...testEntry) {
+	t.Helper()
+	utf16 := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	encodeText := func(text string) []byte {
+		if !isMDD {
+			return append([]byte(text), 0)
+		}
+		data, err := utf16.NewEncoder().Bytes([]byte(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return append(data, 0, 0)
+	}
+	textSize := func(text string) uint16 {
+		if isMDD {
+			return uint16(len(text))
+		}
+		return uint16(len([]byte(text)))
+	}
+	be64 := binary.BigEndian.AppendUint64
+
+	header, err := utf16.NewEncoder().Bytes([]byte(
+		`<Dictionary GeneratedByEngineVersion="2.0" ` + attrs + "/>\r\n\x00",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := binary.BigEndian.AppendUint32(nil, uint32(len(header)))
+	out = append(out, header...)
+	out = binary.LittleEndian.AppendUint32(out, adler32.Checksum(header))
+
+	var keyBlock []byte
+	offset := uint64(0)
+	for _, e := range entries {
+		keyBlock = be64(keyBlock, offset)
+		keyBlock = append(keyBlock, encodeText(e.key)...)
+		offset += uint64(len(e.record))
+	}
+	keyBlockComp := testBlock(compZlib, keyBlock)
+
+	first, last := entries[0].key, entries[len(entries)-1].key
+	info := be64(nil, uint64(len(entries)))
+	info = binary.BigEndian.AppendUint16(info, textSize(first))
+	info = append(info, encodeText(first)...)
+	info = binary.BigEndian.AppendUint16(info, textSize(last))
+	info = append(info, encodeText(last)...)
+	info = be64(info, uint64(len(keyBlockComp)))
+	info = be64(info, uint64(len(keyBlock)))
+	infoComp := testBlock(compZlib, info)
+	if bytes.Contains([]byte(attrs), []byte(`Encrypted="2"`)) {
+		infoComp = encryptKeyBlockInfo(infoComp)
+	}
+
+	nums := be64(nil, 1)
+	nums = be64(nums, uint64(len(entries)))
+	nums = be64(nums, uint64(len(info)))
+	nums = be64(nums, uint64(len(infoComp)))
+	nums = be64(nums, uint64(len(keyBlockComp)))
+	out = append(out, nums...)
+	out = binary.BigEndian.AppendUint32(out, adler32.Checksum(nums))
+	out = append(out, infoComp...)
+	out = append(out, keyBlockComp...)
+
+	var records1, records2 []byte
+	for i, e := range entries {
+		if i == 0 {
+			records1 = append(records1, e.record...)
+			continue
+		}
+		records2 = append(records2, e.record...)
+	}
+	block1 := testBlock(compZlib, records1)
+	block2 := testBlock(compLZO, records2)
+	out = be64(out, 2)
+	out = be64(out, uint64(len(entries)))
+	out = be64(out, 4*8)
+	out = be64(out, uint64(len(block1)+len(block2)))
+	out = be64(out, uint64(len(block1)))
+	out = be64(out, uint64(len(records1)))
+	out = be64(out, uint64(len(block2)))
+	out = be64(out, uint64(len(records2)))
+	out = append(out, block1...)
+	out = append(out, block2...)
+
+	err = os.WriteFile(path, out, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	writeTestFile(
+		t, filepath.Join(dir, "test.mdx"),
+		`Title="Test MDict" Description="&lt;b&gt;fruits&lt;/b&gt;" Encoding="UTF-8" Format="Html" Encrypted="2"`,
+		false,
+		testEntry{"apple", `<img src="img/apple.png"> see <a href="entry://fruit">fruit</a>` + "\r\n\x00"},
+		testEntry{"banana", "@@@LINK=fruit\r\n\x00"},
+		testEntry{"fruit", "a sweet food\x00"},
+	)
+	writeTestFile(
+		t, filepath.Join(dir, "test.mdd"),
+		`Encrypted="0"`,
+		true,
+		testEntry{`\apple.mp3`, "ID3"},
+		testEntry{`\img\apple.png`, "PNG"},
+	)
+	err := os.WriteFile(filepath.Join(dir, "style.css"), []byte("b {}"), 0o644)
+	is.NotErr(err)
+	writeTestFile(
+		t, filepath.Join(dir, "other.mdx"),
+		`Title="Title (No HTML code allowed)" Encrypted="1"`,
+		false,
+		testEntry{"x", "y"},
+	)
+
+	dicList := Open([]string{dir}, map[string]int{})
+	is.Equal(len(dicList), 1)
+
+	dic := dicList[0]
+	is.Equal(dic.DictName(), "Test MDict")
+	is.Equal(dic.Description(), "<b>fruits</b>")
+	is.True(dic.Loaded())
+	count, err := dic.EntryCount()
+	is.NotErr(err)
+	is.Equal(count, 3)
+
+	results := dic.SearchStartWith("app", 1, 0)
+	is.Equal(len(results), 1)
+	is.Equal(results[0].F_Terms, []string{"apple"})
+	items := results[0].Items()
+	is.Equal(len(items), 1)
+	is.Equal(items[0].Type, 'h')
+	is.Equal(string(items[0].Data), `<img src="img/apple.png"> see <a href="bword://fruit">fruit</a>`)
+
+	entry := dic.EntryByIndex(1)
+	is.Equal(entry.F_Terms, []string{"banana"})
+	is.Equal(string(entry.Items()[0].Data), "a sweet food")
+
+	is.Equal(dic.ResourceDir(), "")
+	reader := dic.(*dictionaryImp)
+	data, err := reader.ReadResource("img/apple.png")
+	is.NotErr(err)
+	is.Equal(string(data), "PNG")
+	data, err = reader.ReadResource("/Apple.mp3")
+	is.NotErr(err)
+	is.Equal(string(data), "ID3")
+	data, err = reader.ReadResource("style.css")
+	is.NotErr(err)
+	is.Equal(string(data), "b {}")
+	_, err = reader.ReadResource("missing.png")
+	is.True(errors.Is(err, fs.ErrNotExist))
+	_, err = reader.ReadResource("../test.mdx")
+	is.True(errors.Is(err, fs.ErrNotExist))
+
+	dic.Close()
+	is.False(dic.Loaded())
+}
@@ -0,0 +1,89 @@
+package mdict
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// RIPEMD-128 is only used to make the key for decrypting key block info
+// of MDict files, so it's a simple function instead of a hash.Hash
+
+var (
+	ripemdLeftWords = [64]uint8{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		7, 4, 13, 1, 10, 6, 15, 3, 12, 0, 9, 5, 2, 14, 11, 8,
+		3, 10, 14, 4, 9, 15, 8, 1, 2, 7, 0, 6, 13, 11, 5, 12,
+		1, 9, 11, 10, 0, 8, 12, 4, 13, 3, 7, 15, 14, 5, 6, 2,
+	}
+	ripemdRightWords = [64]uint8{
+		5, 14, 7, 0, 9, 2, 11, 4, 13, 6, 15, 8, 1, 10, 3, 12,
+		6, 11, 3, 7, 0, 13, 5, 10, 14, 15, 8, 12, 4, 9, 1, 2,
+		15, 5, 1, 3, 7, 14, 6, 9, 11, 8, 12, 2, 10, 0, 4, 13,
+		8, 6, 4, 1, 3, 11, 15, 0, 5, 12, 2, 13, 9, 7, 10, 14,
+	}
+	ripemdLeftShifts = [64]uint8{
+		11, 14, 15, 12, 5, 8, 7, 9, 11, 13, 14, 15, 6, 7, 9, 8,
+		7, 6, 8, 13, 11, 9, 7, 15, 7, 12, 15, 9, 11, 7, 13, 12,
+		11, 13, 6, 7, 14, 9, 13, 15, 14, 8, 13, 6, 5, 12, 7, 5,
+		11, 12, 14, 15, 14, 15, 9, 8, 9, 14, 5, 6, 8, 6, 5, 12,
+	}
+	ripemdRightShifts = [64]uint8{
+		8, 9, 9, 11, 13, 15, 15, 5, 7, 7, 8, 11, 14, 14, 12, 6,
+		9, 13, 15, 7, 12, 8, 9, 11, 7, 7, 12, 7, 6, 15, 13, 11,
+		9, 7, 15, 11, 8, 6, 6, 14, 12, 13, 5, 14, 13, 13, 7, 5,
+		15, 5, 8, 11, 14, 14, 6, 14, 6, 9, 12, 9, 12, 5, 15, 8,
+	}
+	ripemdLeftK  = [4]uint32{0x00000000, 0x5A827999, 0x6ED9EBA1, 0x8F1BBCDC}
+	ripemdRightK = [4]uint32{0x50A28BE6, 0x5C4DD124, 0x6D703EF3, 0x00000000}
+)
+
+func ripemdF(round int, x, y, z uint32) uint32 {
+	switch round {
+	case 0:
+		return x ^ y ^ z
+	case 1:
+		return (x & y) | (^x & z)
+	case 2:
+		return (x | ^y) ^ z
+	}
+	return (x & z) | (y & ^z)
+}
+
+func ripemd128(data []byte) []byte {
+	h := [4]uint32{0x67452301, 0xEFCDAB89, 0x98BADCFE, 0x10325476}
+
+	msg := append([]byte{}, data...)
+	msg = append(msg, 0x80)
+	for len(msg)%64 != 56 {
+		msg = append(msg, 0)
+	}
+	msg = binary.LittleEndian.AppendUint64(msg, uint64(len(data))*8)
+
+	var x [16]uint32
+	for block := msg; len(block) > 0; block = block[64:] {
+		for i := range x {
+			x[i] = binary.LittleEndian.Uint32(block[i*4:])
+		}
+		a, b, c, d := h[0], h[1], h[2], h[3]
+		a2, b2, c2, d2 := a, b, c, d
+		for j := range 64 {
+			round := j / 16
+			t := a + ripemdF(round, b, c, d) + x[ripemdLeftWords[j]] + ripemdLeftK[round]
+			a, b, c, d = d, bits.RotateLeft32(t, int(ripemdLeftShifts[j])), b, c
+
+			t = a2 + ripemdF(3-round, b2, c2, d2) + x[ripemdRightWords[j]] + ripemdRightK[round]
+			a2, b2, c2, d2 = d2, bits.RotateLeft32(t, int(ripemdRightShifts[j])), b2, c2
+		}
+		t := h[1] + c + d2
+		h[1] = h[2] + d + a2
+		h[2] = h[3] + a + b2
+		h[3] = h[0] + b + c2
+		h[0] = t
+	}
+
+	out := make([]byte, 0, 16)
+	for _, v := range h {
+		out = binary.LittleEndian.AppendUint32(out, v)
+	}
+	return out
+}
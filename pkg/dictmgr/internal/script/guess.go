@@ -0,0 +1,165 @@
+package script
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// language names (in English and native), lowercase
+var langByName = map[string]string{
+	"english":    "en",
+	"persian":    "fa",
+	"farsi":      "fa",
+	"فارسی":      "fa",
+	"پارسی":      "fa",
+	"arabic":     "ar",
+	"عربی":       "ar",
+	"العربية":    "ar",
+	"german":     "de",
+	"deutsch":    "de",
+	"french":     "fr",
+	"français":   "fr",
+	"francais":   "fr",
+	"spanish":    "es",
+	"español":    "es",
+	"espanol":    "es",
+	"italian":    "it",
+	"italiano":   "it",
+	"portuguese": "pt",
+	"português":  "pt",
+	"dutch":      "nl",
+	"swedish":    "sv",
+	"danish":     "da",
+	"norwegian":  "no",
+	"finnish":    "fi",
+	"polish":     "pl",
+	"czech":      "cs",
+	"hungarian":  "hu",
+	"romanian":   "ro",
+	"turkish":    "tr",
+	"indonesian": "id",
+	"vietnamese": "vi",
+	"latin":      "la",
+	"esperanto":  "eo",
+	"russian":    "ru",
+	"русский":    "ru",
+	"ukrainian":  "uk",
+	"bulgarian":  "bg",
+	"serbian":    "sr",
+	"greek":      "el",
+	"hebrew":     "he",
+	"armenian":   "hy",
+	"georgian":   "ka",
+	"hindi":      "hi",
+	"urdu":       "ur",
+	"pashto":     "ps",
+	"kurdish":    "ku",
+	"thai":       "th",
+	"korean":     "ko",
+	"japanese":   "ja",
+	"chinese":    "zh",
+}
+
+// ISO 639-2 codes, only used in pairs like "eng-fas"
+var langByCode3 = map[string]string{
+	"eng": "en", "fas": "fa", "per": "fa", "ara": "ar", "deu": "de",
+	"ger": "de", "fra": "fr", "fre": "fr", "spa": "es", "ita": "it",
+	"por": "pt", "nld": "nl", "dut": "nl", "swe": "sv", "rus": "ru",
+	"ukr": "uk", "tur": "tr", "pol": "pl", "ell": "el", "gre": "el",
+	"heb": "he", "hin": "hi", "urd": "ur", "jpn": "ja", "kor": "ko",
+	"zho": "zh", "chi": "zh", "lat": "la",
+}
+
+// pairs of language codes like "en-fa", "En_Fa" or "eng-fas"
+var codePairRE = regexp.MustCompile(`(?i)(?:^|[^\pL])(\pL{2,3})\s*(?:-|_|>|→|2)\s*(\pL{2,3})(?:$|[^\pL])`)
+
+// scripts that are used by only one (common) language
+var singleLangScript = map[Script]string{
+	Greek:    "el",
+	Hebrew:   "he",
+	Armenian: "hy",
+	Georgian: "ka",
+	Thai:     "th",
+	Hangul:   "ko",
+	Kana:     "ja",
+}
+
+func langByCode(code string) string {
+	code = strings.ToLower(code)
+	if len(code) == 3 {
+		return langByCode3[code]
+	}
+	if _, ok := langScript[code]; ok {
+		return code
+	}
+	return ""
+}
+
+// langsInText returns languages mentioned in text by name, in order
+func langsInText(text string) []string {
+	langs := []string{}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsMark(r)
+	})
+	for _, word := range words {
+		lang := langByName[word]
+		if lang == "" {
+			continue
+		}
+		if len(langs) > 0 && langs[len(langs)-1] == lang {
+			continue
+		}
+		langs = append(langs, lang)
+	}
+	return langs
+}
+
+// langsInName returns languages of a dictionary name, either by names
+// of languages, or by a pair of language codes
+func langsInName(name string) []string {
+	langs := langsInText(name)
+	if len(langs) > 0 {
+		return langs
+	}
+	for _, match := range codePairRE.FindAllStringSubmatch(name, -1) {
+		source := langByCode(match[1])
+		target := langByCode(match[2])
+		if source != "" && target != "" {
+			return []string{source, target}
+		}
+	}
+	return nil
+}
+
+// GuessLangs guesses source and target languages of a dictionary from its
+// name and description, and script of its headwords (which can be Unknown).
+// Returns empty strings for languages that can not be guessed.
+// A single language found in name or description is used for both
+func GuessLangs(name string, description string, headwordScript Script) (string, string) {
+	langs := langsInName(name)
+	if len(langs) == 0 {
+		langs = langsInText(description)
+	}
+	source, target := "", ""
+	switch len(langs) {
+	case 0:
+	case 1:
+		source, target = langs[0], langs[0]
+	default:
+		source, target = langs[0], langs[1]
+	}
+	if headwordScript == Unknown {
+		return source, target
+	}
+	sourceScript, _ := OfLang(source)
+	targetScript, _ := OfLang(target)
+	if source != "" && sourceScript != headwordScript && targetScript == headwordScript {
+		// name is like "Persian-English" while headwords are in English
+		return target, source
+	}
+	if source == "" {
+		source = singleLangScript[headwordScript]
+	}
+	return source, target
+}
@@ -0,0 +1,130 @@
+// Package script detects writing system (script) of text, maps languages
+// to their usual script, and guesses languages of dictionaries
+package script
+
+import (
+	"strings"
+	"unicode"
+)
+
+type Script string
+
+const (
+	Unknown    Script = ""
+	Latin      Script = "Latin"
+	Arabic     Script = "Arabic"
+	Cyrillic   Script = "Cyrillic"
+	Greek      Script = "Greek"
+	Hebrew     Script = "Hebrew"
+	Armenian   Script = "Armenian"
+	Georgian   Script = "Georgian"
+	Devanagari Script = "Devanagari"
+	Thai       Script = "Thai"
+	Hangul     Script = "Hangul"
+	Kana       Script = "Kana"
+	Han        Script = "Han"
+)
+
+var scriptTables = []struct {
+	script Script
+	tables []*unicode.RangeTable
+}{
+	{Latin, []*unicode.RangeTable{unicode.Latin}},
+	{Arabic, []*unicode.RangeTable{unicode.Arabic}},
+	{Cyrillic, []*unicode.RangeTable{unicode.Cyrillic}},
+	{Greek, []*unicode.RangeTable{unicode.Greek}},
+	{Hebrew, []*unicode.RangeTable{unicode.Hebrew}},
+	{Armenian, []*unicode.RangeTable{unicode.Armenian}},
+	{Georgian, []*unicode.RangeTable{unicode.Georgian}},
+	{Devanagari, []*unicode.RangeTable{unicode.Devanagari}},
+	{Thai, []*unicode.RangeTable{unicode.Thai}},
+	{Hangul, []*unicode.RangeTable{unicode.Hangul}},
+	{Kana, []*unicode.RangeTable{unicode.Hiragana, unicode.Katakana}},
+	{Han, []*unicode.RangeTable{unicode.Han}},
+}
+
+var langScript = map[string]Script{
+	"en": Latin, "de": Latin, "fr": Latin, "es": Latin, "it": Latin,
+	"pt": Latin, "nl": Latin, "sv": Latin, "da": Latin, "no": Latin,
+	"nb": Latin, "fi": Latin, "pl": Latin, "cs": Latin, "sk": Latin,
+	"hu": Latin, "ro": Latin, "tr": Latin, "id": Latin, "ms": Latin,
+	"vi": Latin, "la": Latin, "eo": Latin, "hr": Latin, "sl": Latin,
+	"et": Latin, "lv": Latin, "lt": Latin, "az": Latin, "uz": Latin,
+	"fa": Arabic, "ar": Arabic, "ur": Arabic, "ps": Arabic, "ku": Arabic,
+	"ru": Cyrillic, "uk": Cyrillic, "be": Cyrillic, "bg": Cyrillic,
+	"sr": Cyrillic, "mk": Cyrillic, "kk": Cyrillic, "mn": Cyrillic,
+	"el": Greek,
+	"he": Hebrew, "yi": Hebrew,
+	"hy": Armenian,
+	"ka": Georgian,
+	"hi": Devanagari, "mr": Devanagari, "ne": Devanagari, "sa": Devanagari,
+	"th": Thai,
+	"ko": Hangul,
+	"ja": Kana,
+	"zh": Han,
+}
+
+// OfRune returns script of rune, or Unknown
+func OfRune(r rune) Script {
+	for _, item := range scriptTables {
+		if unicode.In(r, item.tables...) {
+			return item.script
+		}
+	}
+	return Unknown
+}
+
+// Detect returns the script of most letters in text, or Unknown
+// if text has no letter of known scripts
+func Detect(text string) Script {
+	counts := map[Script]int{}
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		if s := OfRune(r); s != Unknown {
+			counts[s]++
+		}
+	}
+	return mostCommon(counts)
+}
+
+// DetectAll is like Detect, but for list of texts, each counted once
+func DetectAll(texts []string) Script {
+	counts := map[Script]int{}
+	for _, text := range texts {
+		if s := Detect(text); s != Unknown {
+			counts[s]++
+		}
+	}
+	return mostCommon(counts)
+}
+
+func mostCommon(counts map[Script]int) Script {
+	best := Unknown
+	bestCount := 0
+	for _, item := range scriptTables {
+		if counts[item.script] > bestCount {
+			best = item.script
+			bestCount = counts[item.script]
+		}
+	}
+	return best
+}
+
+// BaseLang returns lowercase language code without region,
+// like "en" for "en-US"
+func BaseLang(lang string) string {
+	lang = strings.ToLower(lang)
+	if i := strings.IndexAny(lang, "-_"); i > 0 {
+		return lang[:i]
+	}
+	return lang
+}
+
+// OfLang returns usual script of language (ISO 639-1 code, or a tag like
+// "en-US"), and false if language is not known
+func OfLang(lang string) (Script, bool) {
+	s, ok := langScript[BaseLang(lang)]
+	return s, ok
+}
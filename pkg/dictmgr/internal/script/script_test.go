@@ -0,0 +1,48 @@
+package script
+
+import (
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+func TestDetect(t *testing.T) {
+	is := is.New(t)
+	is.Equal(Detect("hello"), Latin)
+	is.Equal(Detect("کتاب"), Arabic)
+	is.Equal(Detect("книга"), Cyrillic)
+	is.Equal(Detect("کتاب book books"), Latin)
+	is.Equal(Detect("123 - !"), Unknown)
+	is.Equal(DetectAll([]string{"a", "کتاب", "b"}), Latin)
+}
+
+func TestOfLang(t *testing.T) {
+	is := is.New(t)
+	s, ok := OfLang("fa")
+	is.True(ok)
+	is.Equal(s, Arabic)
+	s, ok = OfLang("en-US")
+	is.True(ok)
+	is.Equal(s, Latin)
+	_, ok = OfLang("xx")
+	is.False(ok)
+}
+
+func TestGuessLangs(t *testing.T) {
+	is := is.New(t)
+	test := func(name string, description string, hw Script, source string, target string) {
+		t.Helper()
+		actualSource, actualTarget := GuessLangs(name, description, hw)
+		is.Msg("name=%#v", name).Equal(actualSource, source)
+		is.Msg("name=%#v", name).Equal(actualTarget, target)
+	}
+	test("English-Persian Dictionary", "", Latin, "en", "fa")
+	test("Persian - English", "", Latin, "en", "fa")
+	test("Oxford English Dictionary", "", Latin, "en", "en")
+	test("WordNet", "A lexical database of English", Latin, "en", "en")
+	test("Millon En-Fa", "", Unknown, "en", "fa")
+	test("freedict eng-fas", "", Latin, "en", "fa")
+	test("Some Dict", "", Greek, "el", "")
+	test("Some Dict", "", Latin, "", "")
+	test("Better", "", Latin, "", "")
+}
@@ -0,0 +1,387 @@
+package slob
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ulikunitz/xz/lzma"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+const magic = "!-1SLOB\x1f"
+
+// ref is a key of slob file, that points to an item of a bin
+type ref struct {
+	key       string
+	binIndex  uint32
+	itemIndex uint16
+}
+
+// slobFile is an open .slob file, bins are read and decompressed
+// when needed
+type slobFile struct {
+	file *os.File
+
+	// nil for UTF-8
+	enc          encoding.Encoding
+	compression  string
+	tags         map[string]string
+	contentTypes []string
+	storeOffset  int64
+	refsOffset   int64
+
+	// last decompressed bin, as items are often read from the same bin
+	cacheMu    sync.Mutex
+	cacheIndex int64
+	cacheIDs   []byte
+	cacheData  []byte
+}
+
+// byteReader reads big-endian numbers and strings sequentially,
+// keeping the first error
+type byteReader struct {
+	r   *bufio.Reader
+	pos int64
+	err error
+}
+
+func newByteReader(file *os.File, offset int64) *byteReader {
+	return &byteReader{
+		r:   bufio.NewReaderSize(io.NewSectionReader(file, offset, 1<<62), 64*1024),
+		pos: offset,
+	}
+}
+
+func (r *byteReader) read(size int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	buf := make([]byte, size)
+	_, err := io.ReadFull(r.r, buf)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		r.err = fmt.Errorf("error reading at %d: %w", r.pos, err)
+		return nil
+	}
+	r.pos += int64(size)
+	return buf
+}
+
+func (r *byteReader) skip(size int) {
+	if r.err != nil {
+		return
+	}
+	_, err := r.r.Discard(size)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		r.err = fmt.Errorf("error reading at %d: %w", r.pos, err)
+		return
+	}
+	r.pos += int64(size)
+}
+
+func (r *byteReader) uint8() uint8 {
+	buf := r.read(1)
+	if buf == nil {
+		return 0
+	}
+	return buf[0]
+}
+
+func (r *byteReader) uint16() uint16 {
+	buf := r.read(2)
+	if buf == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(buf)
+}
+
+func (r *byteReader) uint32() uint32 {
+	buf := r.read(4)
+	if buf == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(buf)
+}
+
+func (r *byteReader) uint64() uint64 {
+	buf := r.read(8)
+	if buf == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(buf)
+}
+
+// tinyText reads a string with 1-byte length
+func (r *byteReader) tinyText() []byte {
+	return r.read(int(r.uint8()))
+}
+
+// text reads a string with 2-byte length
+func (r *byteReader) text() []byte {
+	return r.read(int(r.uint16()))
+}
+
+// openFile opens a slob file and reads its header
+func openFile(path string) (*slobFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &slobFile{file: file, cacheIndex: -1}
+	err = f.readHeader()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+func (f *slobFile) Close() error {
+	return f.file.Close()
+}
+
+func (f *slobFile) readHeader() error {
+	r := newByteReader(f.file, 0)
+	if string(r.read(len(magic))) != magic {
+		if r.err != nil {
+			return r.err
+		}
+		return errors.New("invalid slob file: bad magic")
+	}
+	// UUID
+	r.skip(16)
+	encName := string(r.tinyText())
+	if r.err != nil {
+		return r.err
+	}
+	switch strings.ToLower(encName) {
+	case "utf-8", "utf8":
+	default:
+		enc, err := htmlindex.Get(encName)
+		if err != nil {
+			return fmt.Errorf("unsupported encoding %#v", encName)
+		}
+		f.enc = enc
+	}
+	f.compression = string(r.tinyText())
+	switch f.compression {
+	case "", "zlib", "bz2", "lzma2":
+	default:
+		return fmt.Errorf("unsupported compression %#v", f.compression)
+	}
+	f.tags = map[string]string{}
+	tagCount := int(r.uint8())
+	for range tagCount {
+		key := f.decodeText(r.tinyText())
+		// tag values are padded with null bytes to be editable in place
+		value := f.decodeText(bytes.TrimRight(r.tinyText(), "\x00"))
+		f.tags[key] = value
+	}
+	typeCount := int(r.uint8())
+	for range typeCount {
+		f.contentTypes = append(f.contentTypes, f.decodeText(r.text()))
+	}
+	// blob count
+	r.uint32()
+	f.storeOffset = int64(r.uint64())
+	// file size
+	r.uint64()
+	f.refsOffset = r.pos
+	return r.err
+}
+
+func (f *slobFile) decodeText(data []byte) string {
+	if f.enc == nil {
+		return string(data)
+	}
+	text, err := f.enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return string(data)
+	}
+	return string(text)
+}
+
+// readRefs reads all keys
+func (f *slobFile) readRefs() ([]ref, error) {
+	r := newByteReader(f.file, f.refsOffset)
+	count := r.uint32()
+	if r.err != nil {
+		return nil, r.err
+	}
+	// skip positions of refs, they are written in order
+	r.skip(int(count) * 8)
+	refs := make([]ref, 0, count)
+	for range count {
+		key := f.decodeText(r.text())
+		binIndex := r.uint32()
+		itemIndex := r.uint16()
+		// fragment (anchor in article)
+		r.tinyText()
+		if r.err != nil {
+			return nil, r.err
+		}
+		refs = append(refs, ref{
+			key:       key,
+			binIndex:  binIndex,
+			itemIndex: itemIndex,
+		})
+	}
+	return refs, nil
+}
+
+// readAt reads size bytes at offset of file
+func (f *slobFile) readAt(offset int64, size uint64) ([]byte, error) {
+	if size > 1<<31 {
+		return nil, fmt.Errorf("invalid size %d at %d", size, offset)
+	}
+	buf := make([]byte, size)
+	_, err := f.file.ReadAt(buf, offset)
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// storeItem returns content type ids of items of a bin, and its
+// compressed data if withData is true
+func (f *slobFile) storeItem(binIndex uint32, withData bool) ([]byte, []byte, error) {
+	countBytes, err := f.readAt(f.storeOffset, 4)
+	if err != nil {
+		return nil, nil, err
+	}
+	count := binary.BigEndian.Uint32(countBytes)
+	if binIndex >= count {
+		return nil, nil, fmt.Errorf("invalid bin index %d", binIndex)
+	}
+	posBytes, err := f.readAt(f.storeOffset+4+int64(binIndex)*8, 8)
+	if err != nil {
+		return nil, nil, err
+	}
+	offset := f.storeOffset + 4 + int64(count)*8 + int64(binary.BigEndian.Uint64(posBytes))
+	idCountBytes, err := f.readAt(offset, 4)
+	if err != nil {
+		return nil, nil, err
+	}
+	idCount := binary.BigEndian.Uint32(idCountBytes)
+	ids, err := f.readAt(offset+4, uint64(idCount)+4)
+	if err != nil {
+		return nil, nil, err
+	}
+	ids, sizeBytes := ids[:idCount], ids[idCount:]
+	if !withData {
+		return ids, nil, nil
+	}
+	data, err := f.readAt(offset+4+int64(idCount)+4, uint64(binary.BigEndian.Uint32(sizeBytes)))
+	if err != nil {
+		return nil, nil, err
+	}
+	return ids, data, nil
+}
+
+// contentType returns content type of an item of a bin, given the
+// content type ids of bin
+func (f *slobFile) contentType(ids []byte, itemIndex uint16) string {
+	if int(itemIndex) >= len(ids) || int(ids[itemIndex]) >= len(f.contentTypes) {
+		return ""
+	}
+	return f.contentTypes[ids[itemIndex]]
+}
+
+func (f *slobFile) decompress(data []byte) ([]byte, error) {
+	var reader io.Reader
+	switch f.compression {
+	case "":
+		return data, nil
+	case "zlib":
+		zReader, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zReader.Close()
+		reader = zReader
+	case "bz2":
+		reader = bzip2.NewReader(bytes.NewReader(data))
+	case "lzma2":
+		lzReader, err := lzma.NewReader2(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		reader = lzReader
+	}
+	return io.ReadAll(reader)
+}
+
+// bin returns content type ids and decompressed data of a bin
+func (f *slobFile) bin(binIndex uint32) ([]byte, []byte, error) {
+	f.cacheMu.Lock()
+	defer f.cacheMu.Unlock()
+	if f.cacheIndex == int64(binIndex) {
+		return f.cacheIDs, f.cacheData, nil
+	}
+	ids, compData, err := f.storeItem(binIndex, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := f.decompress(compData)
+	if err != nil {
+		return nil, nil, err
+	}
+	f.cacheIndex = int64(binIndex)
+	f.cacheIDs = ids
+	f.cacheData = data
+	return ids, data, nil
+}
+
+// binItem returns an item of decompressed bin data, which has number
+// of items, their positions, and items with their sizes
+func binItem(data []byte, itemIndex uint16) ([]byte, error) {
+	errInvalid := fmt.Errorf("invalid bin item %d", itemIndex)
+	if len(data) < 4 {
+		return nil, errInvalid
+	}
+	count := uint64(binary.BigEndian.Uint32(data))
+	itemsStart := 4 + count*4
+	if uint64(itemIndex) >= count || itemsStart > uint64(len(data)) {
+		return nil, errInvalid
+	}
+	pos := itemsStart + uint64(binary.BigEndian.Uint32(data[4+int(itemIndex)*4:]))
+	if pos+4 > uint64(len(data)) {
+		return nil, errInvalid
+	}
+	end := pos + 4 + uint64(binary.BigEndian.Uint32(data[pos:]))
+	if end > uint64(len(data)) {
+		return nil, errInvalid
+	}
+	return data[pos+4 : end], nil
+}
+
+// blob returns content type and content of an item
+func (f *slobFile) blob(binIndex uint32, itemIndex uint16) (string, []byte, error) {
+	ids, data, err := f.bin(binIndex)
+	if err != nil {
+		return "", nil, err
+	}
+	content, err := binItem(data, itemIndex)
+	if err != nil {
+		return "", nil, err
+	}
+	return f.contentType(ids, itemIndex), content, nil
+}
@@ -0,0 +1,34 @@
+package slob
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dictfiles"
+	common "github.com/ilius/go-dict-commons"
+)
+
+func isDictFile(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".slob")
+}
+
+// Open finds slob dictionaries in given paths, each of them can be
+// a .slob file, or a directory which is searched for .slob files
+// (also in its direct sub-directories).
+// Relative paths are relative to home directory, like stardict.Open
+func Open(pathList []string, order map[string]int) []common.Dictionary {
+	var dicList []common.Dictionary
+	for _, fpath := range dictfiles.FindAll(pathList, isDictFile) {
+		dic, err := NewDictionary(fpath)
+		if err != nil {
+			slog.Error("error opening slob dictionary", "err", err, "path", fpath)
+			continue
+		}
+		if order[dic.DictName()] < 0 {
+			dic.disabled = true
+		}
+		dicList = append(dicList, dic)
+	}
+	dictfiles.LoadAll(dicList, "slob")
+	return dicList
+}
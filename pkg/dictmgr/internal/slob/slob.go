@@ -0,0 +1,416 @@
+// Package slob implements common.Dictionary for Aard2 .slob files
+// (like offline Wikipedia and Wiktionary dumps).
+// Items with text/html and text/plain content types are articles,
+// other items (like CSS files and images) are resources, which are
+// read by ReadResource when needed
+package slob
+
+import (
+	"crypto/sha1"
+	"errors"
+	"html"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/termsearch"
+	common "github.com/ilius/go-dict-commons"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// tags that are shown in description, in this order
+var descriptionTags = []string{
+	"uri",
+	"source",
+	"copyright",
+	"license.name",
+	"license.url",
+	"created.at",
+}
+
+var hrefRE = regexp.MustCompile(`(<a [^<>]*?href=)"([^"]*)"`)
+
+type location struct {
+	binIndex  uint32
+	itemIndex uint16
+}
+
+type entry struct {
+	terms []string
+	location
+}
+
+// dictionaryImp is a slob dictionary, keys are kept in memory and
+// items are read from file when needed
+type dictionaryImp struct {
+	path        string
+	name        string
+	description string
+
+	mu       sync.RWMutex
+	disabled bool
+	file     *slobFile
+	entries  []*entry
+	// location of resources by key
+	resources map[string]location
+}
+
+var _ common.Dictionary = (*dictionaryImp)(nil)
+
+// NewDictionary reads header of slob file, without loading keys
+func NewDictionary(path string) (*dictionaryImp, error) {
+	f, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d := &dictionaryImp{
+		path: path,
+		name: f.tags["label"],
+	}
+	if d.name == "" {
+		d.name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	lines := []string{}
+	for _, key := range descriptionTags {
+		if value := f.tags[key]; value != "" {
+			lines = append(lines, key+": "+value)
+		}
+	}
+	d.description = strings.Join(lines, "\n")
+	return d, nil
+}
+
+// mediaType returns lowercase media type of content type, without
+// parameters like charset
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mt, _, _ = strings.Cut(contentType, ";")
+		return strings.ToLower(strings.TrimSpace(mt))
+	}
+	return mt
+}
+
+func isArticleType(mt string) bool {
+	return mt == "text/html" || mt == "text/plain"
+}
+
+func (d *dictionaryImp) Disabled() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.disabled
+}
+
+func (d *dictionaryImp) SetDisabled(disabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.disabled = disabled
+}
+
+func (d *dictionaryImp) Loaded() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.file != nil
+}
+
+// Load reads keys, and content types of items to separate articles
+// from resources. Keys of the same article are merged into one entry
+func (d *dictionaryImp) Load() error {
+	f, err := openFile(d.path)
+	if err != nil {
+		return err
+	}
+	refs, err := f.readRefs()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	binTypeIDs := map[uint32][]byte{}
+	entries := []*entry{}
+	byLocation := map[location]*entry{}
+	resources := map[string]location{}
+	for _, r := range refs {
+		ids, ok := binTypeIDs[r.binIndex]
+		if !ok {
+			ids, _, err = f.storeItem(r.binIndex, false)
+			if err != nil {
+				f.Close()
+				return err
+			}
+			binTypeIDs[r.binIndex] = ids
+		}
+		loc := location{binIndex: r.binIndex, itemIndex: r.itemIndex}
+		if !isArticleType(mediaType(f.contentType(ids, r.itemIndex))) {
+			if _, ok := resources[r.key]; !ok {
+				resources[r.key] = loc
+			}
+			continue
+		}
+		if e := byLocation[loc]; e != nil {
+			if !slices.Contains(e.terms, r.key) {
+				e.terms = append(e.terms, r.key)
+			}
+			continue
+		}
+		e := &entry{terms: []string{r.key}, location: loc}
+		byLocation[loc] = e
+		entries = append(entries, e)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.file != nil {
+		d.file.Close()
+	}
+	d.file = f
+	d.entries = entries
+	d.resources = resources
+	return nil
+}
+
+func (d *dictionaryImp) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.file == nil {
+		return
+	}
+	err := d.file.Close()
+	if err != nil {
+		slog.Error("error closing file", "err", err, "path", d.path)
+	}
+	d.file = nil
+	d.entries = nil
+	d.resources = nil
+}
+
+func (d *dictionaryImp) DictName() string {
+	return d.name
+}
+
+func (d *dictionaryImp) EntryCount() (int, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.file == nil {
+		return 0, errors.New("dictionary is not loaded")
+	}
+	return len(d.entries), nil
+}
+
+func (d *dictionaryImp) Description() string {
+	return d.description
+}
+
+func (d *dictionaryImp) ResourceDir() string {
+	return ""
+}
+
+func (d *dictionaryImp) ResourceURL() string {
+	return ""
+}
+
+// ReadResource returns content of a resource item by its key,
+// like "~/css/style.css"
+func (d *dictionaryImp) ReadResource(relPath string) ([]byte, error) {
+	d.mu.RLock()
+	f := d.file
+	loc, ok := d.resources[relPath]
+	d.mu.RUnlock()
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	_, data, err := f.blob(loc.binIndex, loc.itemIndex)
+	return data, err
+}
+
+func (d *dictionaryImp) IndexPath() string {
+	return d.path
+}
+
+func (d *dictionaryImp) IndexFileSize() uint64 {
+	fi, err := os.Stat(d.path)
+	if err != nil {
+		return 0
+	}
+	return uint64(fi.Size())
+}
+
+func (d *dictionaryImp) InfoPath() string {
+	return ""
+}
+
+// CalcHash returns hash of header of file, which has its UUID.
+// Hashing the whole file would be too slow, as slob files can be
+// several gigabytes
+func (d *dictionaryImp) CalcHash() ([]byte, error) {
+	f, err := openFile(d.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	header, err := f.readAt(0, uint64(f.refsOffset))
+	if err != nil {
+		return nil, err
+	}
+	hash := sha1.Sum(header)
+	return hash[:], nil
+}
+
+// decodeCharset converts text to UTF-8 if content type has another charset
+func decodeCharset(data []byte, contentType string) string {
+	_, params, _ := mime.ParseMediaType(contentType)
+	charset := strings.ToLower(params["charset"])
+	if charset == "" || charset == "utf-8" || charset == "utf8" {
+		return string(data)
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return string(data)
+	}
+	text, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return string(data)
+	}
+	return string(text)
+}
+
+// fixLinks converts links to other articles, which are relative URLs
+// like "Other%20article#section", to bword:// links
+func (d *dictionaryImp) fixLinks(text string) string {
+	return hrefRE.ReplaceAllStringFunc(text, func(match string) string {
+		sub := hrefRE.FindStringSubmatch(match)
+		href := html.UnescapeString(sub[2])
+		if href == "" || strings.HasPrefix(href, "#") || strings.Contains(href, "://") {
+			return match
+		}
+		for _, prefix := range []string{"mailto:", "javascript:", "data:"} {
+			if strings.HasPrefix(href, prefix) {
+				return match
+			}
+		}
+		key, _, _ := strings.Cut(href, "#")
+		if unescaped, err := url.PathUnescape(key); err == nil {
+			key = unescaped
+		}
+		d.mu.RLock()
+		_, isResource := d.resources[key]
+		d.mu.RUnlock()
+		if isResource {
+			return match
+		}
+		return sub[1] + `"bword://` + html.EscapeString(key) + `"`
+	})
+}
+
+// items reads article of entry
+func (d *dictionaryImp) items(e *entry) []*common.SearchResultItem {
+	d.mu.RLock()
+	f := d.file
+	d.mu.RUnlock()
+	if f == nil {
+		return nil
+	}
+	contentType, data, err := f.blob(e.binIndex, e.itemIndex)
+	if err != nil {
+		slog.Error("error reading item", "err", err, "dictName", d.name)
+		return nil
+	}
+	text := decodeCharset(data, contentType)
+	if mediaType(contentType) == "text/plain" {
+		return []*common.SearchResultItem{{
+			Type: 'm',
+			Data: []byte(text),
+		}}
+	}
+	return []*common.SearchResultItem{{
+		Type: 'h',
+		Data: []byte(d.fixLinks(text)),
+	}}
+}
+
+// entryList implements termsearch.Entries
+type entryList struct {
+	d       *dictionaryImp
+	entries []*entry
+}
+
+func (l entryList) Len() int {
+	return len(l.entries)
+}
+
+func (l entryList) Terms(index int) []string {
+	return l.entries[index].terms
+}
+
+func (l entryList) Result(index int, score uint8) *common.SearchResultLow {
+	e := l.entries[index]
+	return &common.SearchResultLow{
+		F_Score: score,
+		F_Terms: e.terms,
+		Items: func() []*common.SearchResultItem {
+			return l.d.items(e)
+		},
+		F_EntryIndex: uint64(index),
+	}
+}
+
+func (d *dictionaryImp) entryList() entryList {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return entryList{d: d, entries: d.entries}
+}
+
+func (d *dictionaryImp) EntryByIndex(index int) *common.SearchResultLow {
+	list := d.entryList()
+	if index < 0 || index >= list.Len() {
+		return nil
+	}
+	return list.Result(index, 0)
+}
+
+func (d *dictionaryImp) SearchFuzzy(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	return termsearch.Fuzzy(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchStartWith(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	return termsearch.StartWith(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchWordMatch(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	return termsearch.WordMatch(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchRegex(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, error) {
+	return termsearch.Regex(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchGlob(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, error) {
+	return termsearch.Glob(d.entryList(), query, workerCount, timeout)
+}
@@ -0,0 +1,235 @@
+package slob
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ilius/is/v2"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+type testItem struct {
+	keys        []string
+	contentType string
+	content     string
+}
+
+func appendTinyText(buf []byte, text string) []byte {
+	buf = append(buf, byte(len(text)))
+	return append(buf, text...)
+}
+
+func appendText(buf []byte, text string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(text)))
+	return append(buf, text...)
+}
+
+// appendItemList appends count, positions and data of items
+func appendItemList(buf []byte, items [][]byte) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(items)))
+	pos := 0
+	for _, item := range items {
+		buf = binary.BigEndian.AppendUint64(buf, uint64(pos))
+		pos += len(item)
+	}
+	for _, item := range items {
+		buf = append(buf, item...)
+	}
+	return buf
+}
+
+func compress(t *testing.T, compression string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	switch compression {
+	case "":
+		return data
+	case "zlib":
+		writer := zlib.NewWriter(&buf)
+		writer.Write(data)
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+	case "lzma2":
+		writer, err := lzma.NewWriter2(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer.Write(data)
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+	default:
+		t.Fatalf("unsupported compression %#v", compression)
+	}
+	return buf.Bytes()
+}
+
+// writeSlob writes a slob file with binSize items in each bin
+func writeSlob(
+	t *testing.T,
+	path string,
+	compression string,
+	tags map[string]string,
+	binSize int,
+	items ...testItem,
+) {
+	t.Helper()
+	contentTypes := []string{}
+	refs := [][]byte{}
+	storeItems := [][]byte{}
+	for start := 0; start < len(items); start += binSize {
+		binItems := items[start:min(start+binSize, len(items))]
+		binIndex := len(storeItems)
+		ids := []byte{}
+		bin := binary.BigEndian.AppendUint32(nil, uint32(len(binItems)))
+		var itemData []byte
+		for itemIndex, item := range binItems {
+			typeID := slices.Index(contentTypes, item.contentType)
+			if typeID < 0 {
+				typeID = len(contentTypes)
+				contentTypes = append(contentTypes, item.contentType)
+			}
+			ids = append(ids, byte(typeID))
+			bin = binary.BigEndian.AppendUint32(bin, uint32(len(itemData)))
+			itemData = binary.BigEndian.AppendUint32(itemData, uint32(len(item.content)))
+			itemData = append(itemData, item.content...)
+			for _, key := range item.keys {
+				r := appendText(nil, key)
+				r = binary.BigEndian.AppendUint32(r, uint32(binIndex))
+				r = binary.BigEndian.AppendUint16(r, uint16(itemIndex))
+				refs = append(refs, appendTinyText(r, ""))
+			}
+		}
+		compData := compress(t, compression, append(bin, itemData...))
+		storeItem := binary.BigEndian.AppendUint32(nil, uint32(len(ids)))
+		storeItem = append(storeItem, ids...)
+		storeItem = binary.BigEndian.AppendUint32(storeItem, uint32(len(compData)))
+		storeItems = append(storeItems, append(storeItem, compData...))
+	}
+
+	header := func(storeOffset uint64, size uint64) []byte {
+		buf := []byte(magic)
+		buf = append(buf, "0123456789abcdef"...)
+		buf = appendTinyText(buf, "utf-8")
+		buf = appendTinyText(buf, compression)
+		buf = append(buf, byte(len(tags)))
+		for key, value := range tags {
+			buf = appendTinyText(buf, key)
+			buf = appendTinyText(buf, value+strings.Repeat("\x00", 255-len(value)))
+		}
+		buf = append(buf, byte(len(contentTypes)))
+		for _, contentType := range contentTypes {
+			buf = appendText(buf, contentType)
+		}
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(items)))
+		buf = binary.BigEndian.AppendUint64(buf, storeOffset)
+		return binary.BigEndian.AppendUint64(buf, size)
+	}
+	refList := appendItemList(nil, refs)
+	store := appendItemList(nil, storeItems)
+	headerSize := len(header(0, 0))
+	size := headerSize + len(refList) + len(store)
+	data := header(uint64(headerSize+len(refList)), uint64(size))
+	data = append(data, refList...)
+	data = append(data, store...)
+	err := os.WriteFile(path, data, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+const testArticle = `<link rel="stylesheet" href="~/css/style.css"/>` +
+	`<img src="~/images/cat.png"/> The <a href="Dog#Etymology">dog</a>'s ` +
+	`<a href="Big%20cat">relative</a>, see <a href="https://example.com">site</a>, ` +
+	`<a href="#top">top</a> and <a href="~/images/cat.png">image</a>`
+
+func TestOpen(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	writeSlob(
+		t, filepath.Join(dir, "animals.slob"), "zlib",
+		map[string]string{
+			"label":        "Animals Wiki",
+			"uri":          "https://example.com/wiki",
+			"license.name": "CC BY-SA",
+		},
+		2,
+		testItem{[]string{"~/css/style.css"}, "text/css", "b { color: red; }"},
+		testItem{[]string{"cat", "Cat"}, "text/html; charset=utf-8", testArticle},
+		testItem{[]string{"dog"}, "text/plain", "a <pet>"},
+		testItem{[]string{"~/images/cat.png"}, "image/png", "\x89PNG"},
+		testItem{[]string{"kitten"}, "text/html;charset=iso-8859-1", "b\xe9b\xe9 chat"},
+	)
+	err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755)
+	is.NotErr(err)
+	writeSlob(
+		t, filepath.Join(dir, "sub", "fruits.slob"), "lzma2",
+		map[string]string{},
+		10,
+		testItem{[]string{"apple"}, "text/html", "<b>pomme</b>"},
+	)
+
+	dicList := Open([]string{dir}, map[string]int{"fruits": -1})
+	is.Equal(len(dicList), 2)
+
+	dic := dicList[0]
+	is.Equal(dic.DictName(), "Animals Wiki")
+	is.Equal(dic.Description(), "uri: https://example.com/wiki\nlicense.name: CC BY-SA")
+	is.True(dic.Loaded())
+	count, err := dic.EntryCount()
+	is.NotErr(err)
+	is.Equal(count, 3)
+
+	results := dic.SearchStartWith("Cat", 1, 0)
+	is.Equal(len(results), 1)
+	is.Equal(results[0].F_Terms, []string{"cat", "Cat"})
+	items := results[0].Items()
+	is.Equal(len(items), 1)
+	is.Equal(items[0].Type, 'h')
+	is.Equal(string(items[0].Data), `<link rel="stylesheet" href="~/css/style.css"/>`+
+		`<img src="~/images/cat.png"/> The <a href="bword://Dog">dog</a>'s `+
+		`<a href="bword://Big cat">relative</a>, see <a href="https://example.com">site</a>, `+
+		`<a href="#top">top</a> and <a href="~/images/cat.png">image</a>`)
+
+	items = dic.EntryByIndex(1).Items()
+	is.Equal(items[0].Type, 'm')
+	is.Equal(string(items[0].Data), "a <pet>")
+	is.Equal(string(dic.EntryByIndex(2).Items()[0].Data), "bébé chat")
+
+	reader := dic.(interface {
+		ReadResource(string) ([]byte, error)
+	})
+	data, err := reader.ReadResource("~/css/style.css")
+	is.NotErr(err)
+	is.Equal(string(data), "b { color: red; }")
+	data, err = reader.ReadResource("~/images/cat.png")
+	is.NotErr(err)
+	is.Equal(string(data), "\x89PNG")
+	_, err = reader.ReadResource("cat")
+	is.True(errors.Is(err, fs.ErrNotExist))
+
+	hash, err := dic.CalcHash()
+	is.NotErr(err)
+	is.Equal(len(hash), 20)
+
+	other := dicList[1]
+	is.Equal(other.DictName(), "fruits")
+	is.Equal(other.Description(), "")
+	is.True(other.Disabled())
+	is.False(other.Loaded())
+	is.NotErr(other.Load())
+	entry := other.EntryByIndex(0)
+	is.Equal(entry.F_Terms, []string{"apple"})
+	is.Equal(string(entry.Items()[0].Data), "<b>pomme</b>")
+	other.Close()
+	is.False(other.Loaded())
+}
@@ -0,0 +1,42 @@
+package sqldict
+
+import (
+	"log/slog"
+	"path/filepath"
+	"slices"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dictfiles"
+	common "github.com/ilius/go-dict-commons"
+)
+
+// file extensions that are checked for dictionary tables
+var fileExts = []string{".sqlite", ".sqlite3"}
+
+func isDictFile(name string) bool {
+	return slices.Contains(fileExts, filepath.Ext(name))
+}
+
+// Open finds SQLite dictionaries in given paths, each of them can be
+// a SQLite file, or a directory which is searched for SQLite files
+// (also in its direct sub-directories).
+// Relative paths are relative to home directory, like stardict.Open
+func Open(pathList []string, order map[string]int) []common.Dictionary {
+	var dicList []common.Dictionary
+	for _, fpath := range dictfiles.FindAll(pathList, isDictFile) {
+		dic, err := NewDictionary(fpath)
+		if err != nil {
+			slog.Error("error opening SQLite dictionary", "err", err, "path", fpath)
+			continue
+		}
+		if dic == nil {
+			slog.Debug("SQLite file has no dictionary tables", "path", fpath)
+			continue
+		}
+		if order[dic.DictName()] < 0 {
+			dic.disabled = true
+		}
+		dicList = append(dicList, dic)
+	}
+	dictfiles.LoadAll(dicList, "SQLite")
+	return dicList
+}
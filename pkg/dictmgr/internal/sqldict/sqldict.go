@@ -0,0 +1,420 @@
+// Package sqldict implements common.Dictionary for SQLite files
+// with this schema (info and resource tables are optional):
+//
+//	CREATE TABLE entry (
+//		id INTEGER PRIMARY KEY,
+//		definition TEXT NOT NULL,
+//		type TEXT NOT NULL DEFAULT 'h' -- 'h' for HTML, 'm' for plain text
+//	);
+//	CREATE TABLE headword (
+//		entry_id INTEGER NOT NULL REFERENCES entry(id),
+//		term TEXT NOT NULL,
+//		position INTEGER NOT NULL DEFAULT 0 -- 0 for main headword
+//	);
+//	CREATE TABLE info (key TEXT PRIMARY KEY, value TEXT NOT NULL);
+//	CREATE TABLE resource (path TEXT PRIMARY KEY, data BLOB NOT NULL);
+//
+// Supported info keys are "name" and "description". Name of dictionary
+// defaults to file name without extension.
+// Resources (images, audio files, etc) are extracted to cache directory
+// when dictionary is loaded, and paths in definitions are relative to it
+package sqldict
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dictfiles"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/termsearch"
+	common "github.com/ilius/go-dict-commons"
+
+	// pure-Go SQLite driver, registered as "sqlite"
+	_ "modernc.org/sqlite"
+)
+
+const driverName = "sqlite"
+
+type entry struct {
+	id    int64
+	terms []string
+}
+
+// dictionaryImp is a SQLite dictionary, headwords are loaded in memory
+// and definitions are read from database when needed
+type dictionaryImp struct {
+	path        string
+	name        string
+	description string
+
+	mu       sync.RWMutex
+	disabled bool
+	db       *sql.DB
+	entries  []*entry
+	resDir   string
+}
+
+var _ common.Dictionary = (*dictionaryImp)(nil)
+
+// openDB opens SQLite file read-only, path is escaped so that
+// characters like "?", "#" and "%" in it are not parsed as URI parts
+func openDB(path string) (*sql.DB, error) {
+	uri := &url.URL{Scheme: "file", Path: path, RawQuery: "mode=ro"}
+	return sql.Open(driverName, uri.String())
+}
+
+// NewDictionary checks tables and reads info of SQLite file, returns
+// nil dictionary (and nil error) if it does not have dictionary tables
+func NewDictionary(path string) (*dictionaryImp, error) {
+	db, err := openDB(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	tables, err := tableNames(db)
+	if err != nil {
+		return nil, err
+	}
+	if !tables["entry"] || !tables["headword"] {
+		return nil, nil
+	}
+	d := &dictionaryImp{
+		path: path,
+		name: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+	}
+	if !tables["info"] {
+		return d, nil
+	}
+	rows, err := db.Query("SELECT key, value FROM info")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, value string
+		err := rows.Scan(&key, &value)
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "name":
+			if value != "" {
+				d.name = value
+			}
+		case "description":
+			d.description = value
+		}
+	}
+	return d, rows.Err()
+}
+
+func tableNames(db *sql.DB) (map[string]bool, error) {
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tables := map[string]bool{}
+	for rows.Next() {
+		var name string
+		err := rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		tables[name] = true
+	}
+	return tables, rows.Err()
+}
+
+func (d *dictionaryImp) Disabled() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.disabled
+}
+
+func (d *dictionaryImp) SetDisabled(disabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.disabled = disabled
+}
+
+func (d *dictionaryImp) Loaded() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.db != nil
+}
+
+// Load opens database, reads all headwords and extracts resources
+func (d *dictionaryImp) Load() error {
+	db, err := openDB(d.path)
+	if err != nil {
+		return err
+	}
+	entries, err := loadEntries(db)
+	if err != nil {
+		db.Close()
+		return err
+	}
+	resDir, err := extractResources(db, d.name)
+	if err != nil {
+		slog.Error("error extracting resources", "err", err, "dictName", d.name)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.db != nil {
+		d.db.Close()
+	}
+	d.db = db
+	d.entries = entries
+	d.resDir = resDir
+	return nil
+}
+
+func loadEntries(db *sql.DB) ([]*entry, error) {
+	rows, err := db.Query("SELECT entry_id, term FROM headword ORDER BY entry_id, position")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []*entry{}
+	var last *entry
+	for rows.Next() {
+		var id int64
+		var term string
+		err := rows.Scan(&id, &term)
+		if err != nil {
+			return nil, err
+		}
+		if last == nil || last.id != id {
+			last = &entry{id: id}
+			entries = append(entries, last)
+		}
+		last.terms = append(last.terms, term)
+	}
+	return entries, rows.Err()
+}
+
+// extractResources writes files of resource table (if any) into
+// cache directory, and returns its path
+// returns empty path if there is no resource
+func extractResources(db *sql.DB, dictName string) (string, error) {
+	tables, err := tableNames(db)
+	if err != nil {
+		return "", err
+	}
+	if !tables["resource"] {
+		return "", nil
+	}
+	rows, err := db.Query("SELECT path, data FROM resource")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	resDir := dictfiles.ResourceDir("sqldict", dictName)
+	count := 0
+	for rows.Next() {
+		var relPath string
+		var data []byte
+		err := rows.Scan(&relPath, &data)
+		if err != nil {
+			return "", err
+		}
+		ok, err := dictfiles.WriteResource(resDir, relPath, data)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			slog.Warn("skipping resource with invalid path", "path", relPath, "dictName", dictName)
+			continue
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	if count == 0 {
+		return "", nil
+	}
+	return resDir, nil
+}
+
+func (d *dictionaryImp) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.db == nil {
+		return
+	}
+	err := d.db.Close()
+	if err != nil {
+		slog.Error("error closing database", "err", err, "dictName", d.name)
+	}
+	d.db = nil
+}
+
+func (d *dictionaryImp) DictName() string {
+	return d.name
+}
+
+func (d *dictionaryImp) EntryCount() (int, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.db == nil {
+		return 0, errors.New("dictionary is not loaded")
+	}
+	return len(d.entries), nil
+}
+
+func (d *dictionaryImp) Description() string {
+	return d.description
+}
+
+func (d *dictionaryImp) ResourceDir() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.resDir
+}
+
+func (d *dictionaryImp) ResourceURL() string {
+	return dictfiles.FileURL(d.ResourceDir())
+}
+
+func (d *dictionaryImp) IndexPath() string {
+	return d.path
+}
+
+func (d *dictionaryImp) IndexFileSize() uint64 {
+	fi, err := os.Stat(d.path)
+	if err != nil {
+		return 0
+	}
+	return uint64(fi.Size())
+}
+
+func (d *dictionaryImp) InfoPath() string {
+	return ""
+}
+
+func (d *dictionaryImp) CalcHash() ([]byte, error) {
+	file, err := os.Open(d.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	hash := sha1.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// items reads definition of entry from database
+func (d *dictionaryImp) items(id int64) []*common.SearchResultItem {
+	d.mu.RLock()
+	db := d.db
+	d.mu.RUnlock()
+	if db == nil {
+		return nil
+	}
+	var defi, defiType string
+	err := db.QueryRow("SELECT definition, type FROM entry WHERE id = ?", id).Scan(&defi, &defiType)
+	if err != nil {
+		slog.Error("error reading definition", "err", err, "id", id, "dictName", d.name)
+		return nil
+	}
+	itemType := 'h'
+	if defiType != "" {
+		itemType = []rune(defiType)[0]
+	}
+	return []*common.SearchResultItem{{
+		Type: itemType,
+		Data: []byte(defi),
+	}}
+}
+
+// entryList implements termsearch.Entries
+type entryList struct {
+	d       *dictionaryImp
+	entries []*entry
+}
+
+func (l entryList) Len() int {
+	return len(l.entries)
+}
+
+func (l entryList) Terms(index int) []string {
+	return l.entries[index].terms
+}
+
+func (l entryList) Result(index int, score uint8) *common.SearchResultLow {
+	e := l.entries[index]
+	return &common.SearchResultLow{
+		F_Score: score,
+		F_Terms: e.terms,
+		Items: func() []*common.SearchResultItem {
+			return l.d.items(e.id)
+		},
+		F_EntryIndex: uint64(index),
+	}
+}
+
+func (d *dictionaryImp) entryList() entryList {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return entryList{d: d, entries: d.entries}
+}
+
+func (d *dictionaryImp) EntryByIndex(index int) *common.SearchResultLow {
+	list := d.entryList()
+	if index < 0 || index >= list.Len() {
+		return nil
+	}
+	return list.Result(index, 0)
+}
+
+func (d *dictionaryImp) SearchFuzzy(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	return termsearch.Fuzzy(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchStartWith(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	return termsearch.StartWith(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchWordMatch(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	return termsearch.WordMatch(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchRegex(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, error) {
+	return termsearch.Regex(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchGlob(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, error) {
+	return termsearch.Glob(d.entryList(), query, workerCount, timeout)
+}
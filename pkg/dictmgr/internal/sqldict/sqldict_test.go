@@ -0,0 +1,121 @@
+package sqldict
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dictfiles"
+	"github.com/ilius/is/v2"
+)
+
+func createTestDB(t *testing.T, path string, statements ...string) {
+	t.Helper()
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(driverName, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stm := range statements {
+		_, err := db.Exec(stm)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+var testSchema = []string{
+	"CREATE TABLE entry (id INTEGER PRIMARY KEY, definition TEXT NOT NULL, type TEXT NOT NULL DEFAULT 'h')",
+	"CREATE TABLE headword (entry_id INTEGER NOT NULL, term TEXT NOT NULL, position INTEGER NOT NULL DEFAULT 0)",
+}
+
+func TestOpen(t *testing.T) {
+	is := is.New(t)
+	dictfiles.ResCacheDir = t.TempDir()
+	dir := t.TempDir()
+	createTestDB(t, filepath.Join(dir, "glossary.sqlite"), append(
+		testSchema,
+		"CREATE TABLE info (key TEXT PRIMARY KEY, value TEXT NOT NULL)",
+		"CREATE TABLE resource (path TEXT PRIMARY KEY, data BLOB NOT NULL)",
+		"INSERT INTO info VALUES ('name', 'Team Glossary'), ('description', 'terms we use')",
+		`INSERT INTO entry VALUES (1, '<b>apple</b> <img src="img/apple.png">', 'h'), (2, 'yellow fruit', 'm')`,
+		"INSERT INTO headword VALUES (1, 'apples', 1), (1, 'apple', 0), (2, 'banana', 0)",
+		"INSERT INTO resource VALUES ('img/apple.png', x'89504e47')",
+	)...)
+	createTestDB(t, filepath.Join(dir, "sub", "other.sqlite3"), append(
+		testSchema,
+		"INSERT INTO entry VALUES (5, 'a fruit', 'h')",
+		"INSERT INTO headword VALUES (5, 'cherry', 0)",
+	)...)
+	createTestDB(t, filepath.Join(dir, "not-dict.sqlite"), "CREATE TABLE foo (bar TEXT)")
+
+	dicList := Open([]string{dir}, map[string]int{"other": -1})
+	is.Equal(len(dicList), 2)
+
+	dic := dicList[0]
+	is.Equal(dic.DictName(), "Team Glossary")
+	is.Equal(dic.Description(), "terms we use")
+	is.True(dic.Loaded())
+	count, err := dic.EntryCount()
+	is.NotErr(err)
+	is.Equal(count, 2)
+
+	results := dic.SearchStartWith("app", 1, 0)
+	is.Equal(len(results), 1)
+	is.Equal(results[0].F_Terms, []string{"apple", "apples"})
+	items := results[0].Items()
+	is.Equal(len(items), 1)
+	is.Equal(items[0].Type, 'h')
+	is.Equal(string(items[0].Data), `<b>apple</b> <img src="img/apple.png">`)
+
+	entry := dic.EntryByIndex(1)
+	is.Equal(entry.F_Terms, []string{"banana"})
+	is.Equal(entry.Items()[0].Type, 'm')
+
+	resDir := dic.ResourceDir()
+	is.Equal(resDir, filepath.Join(dictfiles.ResCacheDir, "sqldict", "Team Glossary"))
+	data, err := os.ReadFile(filepath.Join(resDir, "img", "apple.png"))
+	is.NotErr(err)
+	is.Equal(data, []byte{0x89, 'P', 'N', 'G'})
+
+	hash, err := dic.CalcHash()
+	is.NotErr(err)
+	is.Equal(len(hash), 20)
+
+	other := dicList[1]
+	is.Equal(other.DictName(), "other")
+	is.True(other.Disabled())
+	is.False(other.Loaded())
+	is.NotErr(other.Load())
+	is.Equal(other.SearchFuzzy("cherry", 1, 0)[0].F_Terms, []string{"cherry"})
+	other.Close()
+	is.False(other.Loaded())
+}
+
+func TestOpenSpecialPath(t *testing.T) {
+	is := is.New(t)
+	dictfiles.ResCacheDir = t.TempDir()
+	tmpDir := t.TempDir()
+	createTestDB(t, filepath.Join(tmpDir, "plain", "glossary.sqlite"), append(
+		testSchema,
+		"INSERT INTO entry VALUES (1, 'a fruit', 'h')",
+		"INSERT INTO headword VALUES (1, 'cherry', 0)",
+	)...)
+	// createTestDB does not escape path, so it can not create the file here
+	dir := filepath.Join(tmpDir, "a?b#c%20 d")
+	is.NotErr(os.Rename(filepath.Join(tmpDir, "plain"), dir))
+
+	dicList := Open([]string{dir}, map[string]int{})
+	is.Equal(len(dicList), 1)
+	dic := dicList[0]
+	is.NotErr(dic.Load())
+	defer dic.Close()
+	count, err := dic.EntryCount()
+	is.NotErr(err)
+	is.Equal(count, 1)
+}
@@ -0,0 +1,178 @@
+// Package termsearch implements search methods of common.Dictionary
+// for dictionaries whose headwords are kept in memory, using the same
+// scoring functions and minimum scores as go-stardict
+package termsearch
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ilius/glob"
+	common "github.com/ilius/go-dict-commons"
+	su "github.com/ilius/go-dict-commons/search_utils"
+)
+
+const (
+	minScoreFuzzy   = uint8(64)
+	minScoreDefault = uint8(140)
+)
+
+// Entries is a list of dictionary entries with headwords in memory
+type Entries interface {
+	Len() int
+
+	// Terms returns headwords of entry, first one is the main headword
+	Terms(index int) []string
+
+	// Result returns the search result for entry, with given score
+	Result(index int, score uint8) *common.SearchResultLow
+}
+
+func search(
+	entries Entries,
+	workerCount int,
+	timeout time.Duration,
+	minScore uint8,
+	newScorer func() func(terms []string) uint8,
+) []*common.SearchResultLow {
+	return su.RunWorkers(
+		entries.Len(),
+		workerCount,
+		timeout,
+		func(start int, end int) []*common.SearchResultLow {
+			var results []*common.SearchResultLow
+			score := newScorer()
+			for index := start; index < end; index++ {
+				entryScore := score(entries.Terms(index))
+				if entryScore < minScore {
+					continue
+				}
+				results = append(results, entries.Result(index, entryScore))
+			}
+			return results
+		},
+	)
+}
+
+func Fuzzy(
+	entries Entries,
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+	queryWords := strings.Split(query, " ")
+	mainWordIndex := 0
+	for mainWordIndex < len(queryWords)-1 && queryWords[mainWordIndex] == "*" {
+		mainWordIndex++
+	}
+	minWordCount := 1
+	queryWordCount := 0
+	for _, word := range queryWords {
+		if word == "*" {
+			minWordCount++
+			continue
+		}
+		queryWordCount++
+	}
+	args := &su.ScoreFuzzyArgs{
+		Query:          query,
+		QueryRunes:     []rune(query),
+		QueryMainWord:  []rune(queryWords[mainWordIndex]),
+		QueryWordCount: queryWordCount,
+		MinWordCount:   minWordCount,
+		MainWordIndex:  mainWordIndex,
+	}
+	return search(entries, workerCount, timeout, minScoreFuzzy, func() func([]string) uint8 {
+		// buff can not be shared between workers
+		buff := make([]uint16, 500)
+		return func(terms []string) uint8 {
+			return su.ScoreFuzzy(terms, args, buff)
+		}
+	})
+}
+
+func StartWith(
+	entries Entries,
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+	return search(entries, workerCount, timeout, minScoreDefault, func() func([]string) uint8 {
+		return func(terms []string) uint8 {
+			return su.ScoreStartsWith(terms, query)
+		}
+	})
+}
+
+func WordMatch(
+	entries Entries,
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+	return search(entries, workerCount, timeout, minScoreDefault, func() func([]string) uint8 {
+		return func(terms []string) uint8 {
+			return su.ScoreWordMatch(terms, query)
+		}
+	})
+}
+
+func patternScore(terms []string, match func(string) bool) uint8 {
+	for _, term := range terms {
+		if !match(term) {
+			continue
+		}
+		if len(term) < 20 {
+			return 200 - uint8(len(term))
+		}
+		return 180
+	}
+	return 0
+}
+
+func Regex(
+	entries Entries,
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, error) {
+	re, err := regexp.Compile("^" + query + "$")
+	if err != nil {
+		return nil, err
+	}
+	return search(entries, workerCount, timeout, minScoreDefault, func() func([]string) uint8 {
+		return func(terms []string) uint8 {
+			return patternScore(terms, re.MatchString)
+		}
+	}), nil
+}
+
+func Glob(
+	entries Entries,
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, error) {
+	pattern, err := glob.Compile(query)
+	if err != nil {
+		return nil, err
+	}
+	return search(entries, workerCount, timeout, minScoreDefault, func() func([]string) uint8 {
+		return func(terms []string) uint8 {
+			return patternScore(terms, pattern.Match)
+		}
+	}), nil
+}
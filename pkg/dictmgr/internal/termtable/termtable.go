@@ -0,0 +1,155 @@
+// Package termtable keeps lowercase headwords of a dictionary in memory,
+// for exact, suffix and substring search without scanning the dictionary
+package termtable
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+
+	common "github.com/ilius/go-dict-commons"
+)
+
+// check ctx every this many entries while building table
+const ctxCheckInterval = 1000
+
+type reversedTerm struct {
+	term  string
+	entry uint32
+}
+
+// Table of lowercase headwords of a dictionary
+type Table struct {
+	// Terms are lowercase terms of each entry, by entry index
+	Terms [][]string
+
+	exact    map[string][]uint32
+	reversed []reversedTerm
+}
+
+// Normalize converts query or headword to the form used in table
+func Normalize(term string) string {
+	return strings.ToLower(strings.TrimSpace(term))
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	slices.Reverse(runes)
+	return string(runes)
+}
+
+// Build reads headwords of all entries of dictionary and creates the table
+// returns ctx.Err() if ctx is done before it's finished
+func Build(ctx context.Context, dic common.Dictionary) (*Table, error) {
+	count, err := dic.EntryCount()
+	if err != nil {
+		return nil, err
+	}
+	t := &Table{
+		Terms: make([][]string, count),
+		exact: make(map[string][]uint32, count),
+	}
+	for entryIndex := range count {
+		if entryIndex%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		entry := dic.EntryByIndex(entryIndex)
+		if entry == nil {
+			continue
+		}
+		t.add(uint32(entryIndex), entry.F_Terms)
+	}
+	t.sort()
+	return t, nil
+}
+
+// NormalizeTerms returns unique normalized terms of an entry,
+// the same as Terms of table for that entry
+func NormalizeTerms(terms []string) []string {
+	lowerTerms := make([]string, 0, len(terms))
+	for _, term := range terms {
+		term = Normalize(term)
+		if slices.Contains(lowerTerms, term) {
+			continue
+		}
+		lowerTerms = append(lowerTerms, term)
+	}
+	return lowerTerms
+}
+
+func (t *Table) add(entryIndex uint32, terms []string) {
+	lowerTerms := NormalizeTerms(terms)
+	for _, term := range lowerTerms {
+		t.exact[term] = append(t.exact[term], entryIndex)
+		t.reversed = append(t.reversed, reversedTerm{
+			term:  reverse(term),
+			entry: entryIndex,
+		})
+	}
+	t.Terms[entryIndex] = lowerTerms
+}
+
+func (t *Table) sort() {
+	sort.Slice(t.reversed, func(i, j int) bool {
+		return t.reversed[i].term < t.reversed[j].term
+	})
+}
+
+// Exact returns sorted indexes of entries that have a headword
+// equal to query (case-insensitive)
+func (t *Table) Exact(query string) []uint32 {
+	return t.exact[Normalize(query)]
+}
+
+// EndsWith returns sorted indexes of entries that have a headword
+// ending with suffix (case-insensitive)
+func (t *Table) EndsWith(suffix string) []uint32 {
+	prefix := reverse(Normalize(suffix))
+	if prefix == "" {
+		return nil
+	}
+	start := sort.Search(len(t.reversed), func(i int) bool {
+		return t.reversed[i].term >= prefix
+	})
+	result := []uint32{}
+	for _, item := range t.reversed[start:] {
+		if !strings.HasPrefix(item.term, prefix) {
+			break
+		}
+		result = append(result, item.entry)
+	}
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+// Contains returns sorted indexes of entries that have a headword
+// containing sub (case-insensitive). If candidates is not nil, only
+// those entries are checked
+func (t *Table) Contains(sub string, candidates []uint32) []uint32 {
+	sub = Normalize(sub)
+	if sub == "" {
+		return nil
+	}
+	result := []uint32{}
+	check := func(entryIndex uint32) {
+		for _, term := range t.Terms[entryIndex] {
+			if strings.Contains(term, sub) {
+				result = append(result, entryIndex)
+				return
+			}
+		}
+	}
+	if candidates != nil {
+		for _, entryIndex := range candidates {
+			if int(entryIndex) < len(t.Terms) {
+				check(entryIndex)
+			}
+		}
+		return result
+	}
+	for entryIndex := range t.Terms {
+		check(uint32(entryIndex))
+	}
+	return result
+}
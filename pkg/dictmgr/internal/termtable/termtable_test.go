@@ -0,0 +1,40 @@
+package termtable
+
+import (
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+func newTestTable(entries ...[]string) *Table {
+	t := &Table{
+		Terms: make([][]string, len(entries)),
+		exact: map[string][]uint32{},
+	}
+	for i, terms := range entries {
+		t.add(uint32(i), terms)
+	}
+	t.sort()
+	return t
+}
+
+func TestTable(t *testing.T) {
+	is := is.New(t)
+	table := newTestTable(
+		[]string{"Biology"},
+		[]string{"graph", "Graphs"},
+		[]string{"paragraph"},
+		[]string{"geology", "ology"},
+		[]string{"کتاب"},
+	)
+	is.Equal(table.Exact("GRAPH"), []uint32{1})
+	is.Equal(len(table.Exact("grap")), 0)
+	is.Equal(table.EndsWith("ology"), []uint32{0, 3})
+	is.Equal(table.EndsWith("graph"), []uint32{1, 2})
+	is.Equal(table.EndsWith("تاب"), []uint32{4})
+	is.Equal(len(table.EndsWith("xyz")), 0)
+	is.Equal(table.Contains("graph", nil), []uint32{1, 2})
+	is.Equal(table.Contains("olo", nil), []uint32{0, 3})
+	is.Equal(table.Contains("olo", []uint32{3, 4}), []uint32{3})
+	is.Equal(len(table.Contains("", nil)), 0)
+}
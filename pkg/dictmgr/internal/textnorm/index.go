@@ -0,0 +1,45 @@
+package textnorm
+
+import (
+	"context"
+
+	common "github.com/ilius/go-dict-commons"
+)
+
+// KindPrefix is prefix of the name of index used in indexcache,
+// followed by profile key
+// increase version when format or any normalization step is changed
+const KindPrefix = "normalized-v1-"
+
+// check ctx every this many entries while building index
+const ctxCheckInterval = 1000
+
+// Index keeps normalized headwords of each entry of a dictionary
+type Index struct {
+	Terms [][]string
+}
+
+// Build reads headwords of all entries of dictionary and creates the index
+// returns ctx.Err() if ctx is done before it's finished
+func Build(ctx context.Context, dic common.Dictionary, profile *Profile) (*Index, error) {
+	count, err := dic.EntryCount()
+	if err != nil {
+		return nil, err
+	}
+	terms := make([][]string, count)
+	for entryIndex := range count {
+		if entryIndex%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		entry := dic.EntryByIndex(entryIndex)
+		if entry == nil {
+			continue
+		}
+		entryTerms := make([]string, len(entry.F_Terms))
+		for i, term := range entry.F_Terms {
+			entryTerms[i] = profile.Normalize(term)
+		}
+		terms[entryIndex] = entryTerms
+	}
+	return &Index{Terms: terms}, nil
+}
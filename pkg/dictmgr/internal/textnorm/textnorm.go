@@ -0,0 +1,119 @@
+// Package textnorm implements normalization profiles that are applied
+// to both query and headwords, to make matching insensitive to case,
+// diacritics, and variants of Persian/Arabic letters
+package textnorm
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// names of normalization steps, which make up a profile
+const (
+	// Unicode compatibility normalization (NFKC)
+	StepNFKC = "nfkc"
+	// convert to lowercase
+	StepCase = "case"
+	// remove diacritics (accents of Latin letters, Arabic harakat, etc)
+	StepDiacritics = "diacritics"
+	// fold Arabic letters into their Persian variants, like ي -> ی and ك -> ک
+	StepArabic = "arabic"
+	// replace ZWNJ (zero-width non-joiner) with space, and remove tatweel
+	StepZWNJ = "zwnj"
+
+	// None is used (alone) to disable normalization
+	None = "none"
+)
+
+var stepFuncs = map[string]func(string) string{
+	StepNFKC:       norm.NFKC.String,
+	StepCase:       strings.ToLower,
+	StepDiacritics: removeDiacritics,
+	StepArabic:     foldArabic,
+	StepZWNJ:       replaceZWNJ,
+}
+
+// Profile is a list of normalization steps that are applied in order
+type Profile struct {
+	steps []string
+}
+
+// NewProfile validates step names and creates a profile
+// returns nil (and no error) for empty list or for None
+func NewProfile(steps []string) (*Profile, error) {
+	if len(steps) == 0 || len(steps) == 1 && steps[0] == None {
+		return nil, nil
+	}
+	for _, step := range steps {
+		if stepFuncs[step] == nil {
+			return nil, fmt.Errorf("invalid normalization step %#v", step)
+		}
+	}
+	return &Profile{steps: steps}, nil
+}
+
+// Key is a unique name of profile, used to name index files
+func (p *Profile) Key() string {
+	return strings.Join(p.steps, "+")
+}
+
+// Normalize applies all steps of profile to s
+func (p *Profile) Normalize(s string) string {
+	for _, step := range p.steps {
+		s = stepFuncs[step](s)
+	}
+	return s
+}
+
+func removeDiacritics(s string) string {
+	decomposed := norm.NFD.String(s)
+	var sb strings.Builder
+	sb.Grow(len(decomposed))
+	for _, c := range decomposed {
+		if unicode.Is(unicode.Mn, c) {
+			continue
+		}
+		sb.WriteRune(c)
+	}
+	return norm.NFC.String(sb.String())
+}
+
+var arabicReplacer = strings.NewReplacer(
+	"ي", "ی",
+	"ى", "ی",
+	"ئ", "ی",
+	"ك", "ک",
+	"ة", "ه",
+	"ۀ", "ه",
+	"أ", "ا",
+	"إ", "ا",
+	"آ", "ا",
+	"ٱ", "ا",
+	"ؤ", "و",
+	"٠", "۰",
+	"١", "۱",
+	"٢", "۲",
+	"٣", "۳",
+	"٤", "۴",
+	"٥", "۵",
+	"٦", "۶",
+	"٧", "۷",
+	"٨", "۸",
+	"٩", "۹",
+)
+
+func foldArabic(s string) string {
+	return arabicReplacer.Replace(s)
+}
+
+var zwnjReplacer = strings.NewReplacer(
+	"‌", " ",
+	"ـ", "", // tatweel
+)
+
+func replaceZWNJ(s string) string {
+	return zwnjReplacer.Replace(s)
+}
@@ -0,0 +1,42 @@
+package textnorm
+
+import (
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+func TestNewProfile(t *testing.T) {
+	is := is.New(t)
+	p, err := NewProfile(nil)
+	is.NotErr(err)
+	is.Nil(p)
+	p, err = NewProfile([]string{None})
+	is.NotErr(err)
+	is.Nil(p)
+	_, err = NewProfile([]string{"nfkc", "foo"})
+	is.Err(err)
+	p, err = NewProfile([]string{StepNFKC, StepCase})
+	is.NotErr(err)
+	is.Equal(p.Key(), "nfkc+case")
+}
+
+func TestNormalize(t *testing.T) {
+	is := is.New(t)
+	p, err := NewProfile([]string{StepNFKC, StepCase, StepDiacritics, StepArabic, StepZWNJ})
+	is.NotErr(err)
+	is.Equal(p.Normalize("Café"), "cafe")
+	is.Equal(p.Normalize("ﬁne"), "fine")
+	is.Equal(p.Normalize("كتاب"), "کتاب")
+	is.Equal(p.Normalize("عَرَبِيّ"), "عربی")
+	is.Equal(p.Normalize("می‌روم"), "می روم")
+	is.Equal(p.Normalize("کـتاب"), "کتاب")
+}
+
+func TestNormalizeOrder(t *testing.T) {
+	is := is.New(t)
+	p, err := NewProfile([]string{StepDiacritics})
+	is.NotErr(err)
+	// case is kept without StepCase
+	is.Equal(p.Normalize("Éclair"), "Eclair")
+}
@@ -0,0 +1,90 @@
+package trigram
+
+import (
+	"regexp/syntax"
+	"strings"
+)
+
+// GlobLiterals returns literal parts of a glob pattern,
+// which must appear in any matching term
+func GlobLiterals(pattern string) []string {
+	literals := []string{}
+	current := []rune{}
+	flush := func() {
+		if len(current) > 0 {
+			literals = append(literals, string(current))
+			current = current[:0]
+		}
+	}
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch c {
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+				current = append(current, runes[i])
+			}
+		case '*', '?':
+			flush()
+		case '[', '{':
+			flush()
+			closing := ']'
+			if c == '{' {
+				closing = '}'
+			}
+			for i < len(runes) && runes[i] != closing {
+				i++
+			}
+		default:
+			current = append(current, c)
+		}
+	}
+	flush()
+	return literals
+}
+
+// RegexLiterals returns literal parts of a regular expression,
+// which must appear in any matching term
+// returns nil if pattern is invalid or has no such literal part
+func RegexLiterals(pattern string) []string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	return regexLiterals(re.Simplify())
+}
+
+func regexLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCapture, syntax.OpPlus:
+		return regexLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			return regexLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		literals := []string{}
+		current := []string{}
+		flush := func() {
+			if len(current) > 0 {
+				literals = append(literals, strings.Join(current, ""))
+				current = current[:0]
+			}
+		}
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpLiteral {
+				// adjacent literals form a longer literal
+				current = append(current, string(sub.Rune))
+				continue
+			}
+			flush()
+			literals = append(literals, regexLiterals(sub)...)
+		}
+		flush()
+		return literals
+	}
+	return nil
+}
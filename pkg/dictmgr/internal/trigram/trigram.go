@@ -0,0 +1,162 @@
+// Package trigram implements a trigram index over headwords of a dictionary
+// which is used to find candidate entries for Fuzzy, Glob and Regex search
+// before scoring them, instead of scanning all entries
+package trigram
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	common "github.com/ilius/go-dict-commons"
+)
+
+// Kind is the name of index used in indexcache
+// increase version when format or trigram extraction is changed
+const Kind = "trigram-v1"
+
+// check ctx every this many entries while building index
+const ctxCheckInterval = 1000
+
+// Index maps each trigram to sorted list of entry indexes
+// that have at least one term containing that trigram
+type Index struct {
+	Grams map[string][]uint32
+}
+
+// Trigrams returns unique trigrams of lowercase s padded with a space
+// on each side, so that start and end of words are also indexed
+func Trigrams(s string) []string {
+	runes := []rune(" " + strings.ToLower(s) + " ")
+	if len(runes) < 3 {
+		return nil
+	}
+	seen := make(map[string]bool, len(runes))
+	grams := make([]string, 0, len(runes)-2)
+	for i := 0; i+3 <= len(runes); i++ {
+		gram := string(runes[i : i+3])
+		if seen[gram] {
+			continue
+		}
+		seen[gram] = true
+		grams = append(grams, gram)
+	}
+	return grams
+}
+
+// InnerTrigrams returns unique trigrams of lowercase s without padding
+// used for literal parts of a pattern that may appear anywhere in term
+func InnerTrigrams(s string) []string {
+	runes := []rune(strings.ToLower(s))
+	seen := map[string]bool{}
+	grams := []string{}
+	for i := 0; i+3 <= len(runes); i++ {
+		gram := string(runes[i : i+3])
+		if seen[gram] {
+			continue
+		}
+		seen[gram] = true
+		grams = append(grams, gram)
+	}
+	return grams
+}
+
+// Build reads headwords of all entries of dictionary and creates the index
+// returns ctx.Err() if ctx is done before it's finished
+func Build(ctx context.Context, dic common.Dictionary) (*Index, error) {
+	count, err := dic.EntryCount()
+	if err != nil {
+		return nil, err
+	}
+	grams := map[string][]uint32{}
+	for entryIndex := range count {
+		if entryIndex%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		entry := dic.EntryByIndex(entryIndex)
+		if entry == nil {
+			continue
+		}
+		seen := map[string]bool{}
+		for _, term := range entry.F_Terms {
+			for _, gram := range Trigrams(term) {
+				if seen[gram] {
+					continue
+				}
+				seen[gram] = true
+				// entryIndex is increasing, so lists stay sorted
+				grams[gram] = append(grams[gram], uint32(entryIndex))
+			}
+		}
+	}
+	return &Index{Grams: grams}, nil
+}
+
+// AtLeast returns sorted indexes of entries that contain
+// at least minCount of the given (unique) trigrams
+func (idx *Index) AtLeast(grams []string, minCount int) []uint32 {
+	if minCount < 1 {
+		minCount = 1
+	}
+	counts := map[uint32]int{}
+	for _, gram := range grams {
+		for _, entryIndex := range idx.Grams[gram] {
+			counts[entryIndex]++
+		}
+	}
+	result := []uint32{}
+	for entryIndex, count := range counts {
+		if count >= minCount {
+			result = append(result, entryIndex)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+	return result
+}
+
+// All returns sorted indexes of entries that contain all given trigrams
+func (idx *Index) All(grams []string) []uint32 {
+	if len(grams) == 0 {
+		return nil
+	}
+	lists := make([][]uint32, len(grams))
+	for i, gram := range grams {
+		list := idx.Grams[gram]
+		if len(list) == 0 {
+			return nil
+		}
+		lists[i] = list
+	}
+	// start from the shortest list
+	sort.Slice(lists, func(i, j int) bool {
+		return len(lists[i]) < len(lists[j])
+	})
+	result := lists[0]
+	for _, list := range lists[1:] {
+		result = intersect(result, list)
+		if len(result) == 0 {
+			return nil
+		}
+	}
+	return result
+}
+
+func intersect(a []uint32, b []uint32) []uint32 {
+	result := []uint32{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
@@ -0,0 +1,48 @@
+package trigram
+
+import (
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+func TestTrigrams(t *testing.T) {
+	is := is.New(t)
+	is.Equal(Trigrams("Leaf"), []string{" le", "lea", "eaf", "af "})
+	is.Equal(Trigrams("a"), []string{" a "})
+	is.Equal(Trigrams("aaaa"), []string{" aa", "aaa", "aa "})
+	is.Equal(InnerTrigrams("graph"), []string{"gra", "rap", "aph"})
+	is.Equal(InnerTrigrams("gr"), []string{})
+}
+
+func TestGlobLiterals(t *testing.T) {
+	is := is.New(t)
+	is.Equal(GlobLiterals("*graph*"), []string{"graph"})
+	is.Equal(GlobLiterals("un*able"), []string{"un", "able"})
+	is.Equal(GlobLiterals("a?c[xy]def{g,h}ij"), []string{"a", "c", "def", "ij"})
+	is.Equal(GlobLiterals(`a\*b`), []string{"a*b"})
+	is.Equal(GlobLiterals("*"), []string{})
+}
+
+func TestRegexLiterals(t *testing.T) {
+	is := is.New(t)
+	is.Equal(RegexLiterals("un.*able"), []string{"un", "able"})
+	is.Equal(RegexLiterals("(graph)+ics"), []string{"graph", "ics"})
+	is.Equal(len(RegexLiterals("abc|def")), 0)
+	is.Equal(len(RegexLiterals(".*")), 0)
+	is.Equal(len(RegexLiterals("(")), 0)
+}
+
+func TestIndex(t *testing.T) {
+	is := is.New(t)
+	idx := &Index{Grams: map[string][]uint32{
+		"gra": {1, 3, 5},
+		"rap": {1, 3, 6},
+		"aph": {1, 3, 7},
+		" le": {2, 3},
+	}}
+	is.Equal(idx.All([]string{"gra", "rap", "aph"}), []uint32{1, 3})
+	is.Equal(len(idx.All([]string{"gra", "xyz"})), 0)
+	is.Equal(idx.AtLeast([]string{"gra", "rap", "aph"}, 1), []uint32{1, 3, 5, 6, 7})
+	is.Equal(idx.AtLeast([]string{"gra", "rap", "aph", " le"}, 3), []uint32{1, 3})
+}
@@ -0,0 +1,35 @@
+package xdxf
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dictfiles"
+	common "github.com/ilius/go-dict-commons"
+)
+
+func isDictFile(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasSuffix(name, ".xdxf") || strings.HasSuffix(name, ".xdxf.dz")
+}
+
+// Open finds XDXF dictionaries in given paths, each of them can be
+// an XDXF file, or a directory which is searched for XDXF files
+// (also in its direct sub-directories).
+// Relative paths are relative to home directory, like stardict.Open
+func Open(pathList []string, order map[string]int) []common.Dictionary {
+	var dicList []common.Dictionary
+	for _, fpath := range dictfiles.FindAll(pathList, isDictFile) {
+		dic, err := NewDictionary(fpath)
+		if err != nil {
+			slog.Error("error opening XDXF dictionary", "err", err, "path", fpath)
+			continue
+		}
+		if order[dic.DictName()] < 0 {
+			dic.disabled = true
+		}
+		dicList = append(dicList, dic)
+	}
+	dictfiles.LoadAll(dicList, "XDXF")
+	return dicList
+}
@@ -0,0 +1,184 @@
+package xdxf
+
+import (
+	"encoding/xml"
+	"html"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dictfiles"
+)
+
+type htmlTag struct {
+	open  string
+	close string
+}
+
+// XDXF tags and their HTML, styled like the same tags of DSL.
+// Tags that are not here (like <ar>, <def>, <deftext>) are removed,
+// keeping their content
+var htmlTags = map[string]htmlTag{
+	"k":          {"<b>", "</b>"},
+	"tr":         {`<span class="transcription">[`, "]</span>"},
+	"ex":         {`<span style="color:steelblue">`, "</span>"},
+	"abr":        {`<i style="color:green">`, "</i>"},
+	"abbr":       {`<i style="color:green">`, "</i>"},
+	"gr":         {`<i style="color:green">`, "</i>"},
+	"co":         {`<span style="color:gray">`, "</span>"},
+	"dtrn":       {`<span class="dtrn">`, "</span>"},
+	"b":          {"<b>", "</b>"},
+	"i":          {"<i>", "</i>"},
+	"u":          {"<u>", "</u>"},
+	"sup":        {"<sup>", "</sup>"},
+	"sub":        {"<sub>", "</sub>"},
+	"big":        {"<big>", "</big>"},
+	"small":      {"<small>", "</small>"},
+	"blockquote": {"<blockquote>", "</blockquote>"},
+	"br":         {"<br/>", ""},
+}
+
+// tags whose text content is used as a link or file name
+var contentTags = map[string]bool{
+	"kref": true,
+	"rref": true,
+	"iref": true,
+}
+
+func attrValue(elem xml.StartElement, name string) string {
+	for _, attr := range elem.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// colorAttr returns a safe color name/value of <c> tag, green by default
+func colorAttr(attr string) string {
+	if attr == "" {
+		return "green"
+	}
+	for _, r := range attr {
+		if !(r == '#' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return "green"
+		}
+	}
+	return attr
+}
+
+func newDecoder(reader io.Reader) *xml.Decoder {
+	decoder := xml.NewDecoder(reader)
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	return decoder
+}
+
+// ToHTML converts an XDXF article (like 'x' items of StarDict) to HTML.
+// Links to other words (<kref>) are like <a href="bword://word">, and
+// resources (<rref>) are like sounds and images of StarDict articles,
+// so they are fixed by DictProcessor.FixDefiHTML
+func ToHTML(article string) string {
+	var out strings.Builder
+	decoder := newDecoder(strings.NewReader("<ar>" + article + "</ar>"))
+	// stack of closing HTML of open tags
+	stack := []string{}
+	// content of a tag in contentTags, and the tag itself
+	var content *strings.Builder
+	var contentElem xml.StartElement
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err != io.EOF {
+				slog.Error("error parsing XDXF article", "err", err)
+			}
+			break
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			name := token.Name.Local
+			if content != nil {
+				continue
+			}
+			if contentTags[name] {
+				content = &strings.Builder{}
+				contentElem = token
+				continue
+			}
+			tag, ok := htmlTags[name]
+			if name == "c" {
+				tag, ok = htmlTag{
+					open:  `<font color="` + colorAttr(attrValue(token, "c")) + `">`,
+					close: "</font>",
+				}, true
+			}
+			if !ok {
+				stack = append(stack, "")
+				continue
+			}
+			out.WriteString(tag.open)
+			stack = append(stack, tag.close)
+		case xml.EndElement:
+			if content != nil {
+				if token.Name.Local != contentElem.Name.Local {
+					continue
+				}
+				out.WriteString(contentTagHTML(contentElem, content.String()))
+				content = nil
+				continue
+			}
+			if len(stack) == 0 {
+				continue
+			}
+			out.WriteString(stack[len(stack)-1])
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if content != nil {
+				content.Write(token)
+				continue
+			}
+			text := html.EscapeString(string(token))
+			out.WriteString(strings.ReplaceAll(text, "\n", "<br/>\n"))
+		}
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		out.WriteString(stack[i])
+	}
+	return strings.TrimSuffix(out.String(), "<br/>\n")
+}
+
+func contentTagHTML(elem xml.StartElement, text string) string {
+	text = strings.TrimSpace(text)
+	escaped := html.EscapeString(text)
+	switch elem.Name.Local {
+	case "kref":
+		target := attrValue(elem, "k")
+		if target == "" {
+			target = text
+		}
+		return `<a href="bword://` + html.EscapeString(target) + `">` + escaped + "</a>"
+	case "rref":
+		fname := attrValue(elem, "lctn")
+		if fname == "" {
+			fname = text
+		}
+		if fname == "" {
+			return ""
+		}
+		return dictfiles.ResourceHTML(fname)
+	case "iref":
+		href := attrValue(elem, "href")
+		if href == "" {
+			href = text
+		}
+		if !strings.HasPrefix(href, "http://") && !strings.HasPrefix(href, "https://") {
+			return escaped
+		}
+		if text == "" {
+			escaped = html.EscapeString(href)
+		}
+		return `<a href="` + html.EscapeString(href) + `">` + escaped + "</a>"
+	}
+	return escaped
+}
@@ -0,0 +1,445 @@
+// Package xdxf implements common.Dictionary for XDXF files (.xdxf, or
+// dictzip-compressed .xdxf.dz), in both visual and logical formats,
+// and converts XDXF articles to HTML (see ToHTML).
+// Articles are returned as 'x' items, like XDXF articles of StarDict
+package xdxf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/termsearch"
+	common "github.com/ilius/go-dict-commons"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// maximum number of (decompressed) bytes read to find name and description
+const headerReadSize = 64 * 1024
+
+var xmlEncodingRE = regexp.MustCompile(`^<\?xml[^>]*encoding=["']([^"']+)["']`)
+
+type entry struct {
+	terms []string
+
+	// body is inner XML of <ar>
+	body string
+}
+
+// dictionaryImp is an XDXF dictionary, articles are kept in memory
+// after loading
+type dictionaryImp struct {
+	path        string
+	name        string
+	description string
+
+	mu       sync.RWMutex
+	disabled bool
+	entries  []*entry
+}
+
+var _ common.Dictionary = (*dictionaryImp)(nil)
+
+// NewDictionary reads name and description of XDXF file
+func NewDictionary(path string) (*dictionaryImp, error) {
+	data, err := readFile(path, headerReadSize)
+	if err != nil {
+		return nil, err
+	}
+	// data may be truncated, so errors are ignored here and reported
+	// by Load
+	info, _, _ := parse(data, false)
+	d := &dictionaryImp{
+		path:        path,
+		name:        info.name,
+		description: info.description,
+	}
+	if d.name == "" {
+		d.name = filepath.Base(basePath(path))
+	}
+	return d, nil
+}
+
+// basePath returns path without .xdxf or .xdxf.dz extension
+func basePath(path string) string {
+	lower := strings.ToLower(path)
+	for _, ext := range []string{".xdxf.dz", ".xdxf"} {
+		if strings.HasSuffix(lower, ext) {
+			return path[:len(path)-len(ext)]
+		}
+	}
+	return path
+}
+
+// readFile reads (and decompresses if it's .dz) the file, and converts
+// it to UTF-8 if it has another encoding in XML declaration.
+// Reads at most limit bytes if limit > 0
+func readFile(path string, limit int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	if strings.HasSuffix(strings.ToLower(path), ".dz") {
+		gzReader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gzReader.Close()
+		reader = gzReader
+	}
+	if limit > 0 {
+		reader = io.LimitReader(reader, limit)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	match := xmlEncodingRE.FindSubmatch(data)
+	if match == nil {
+		return data, nil
+	}
+	switch strings.ToUpper(string(match[1])) {
+	case "UTF-8", "UTF8":
+		return data, nil
+	}
+	enc, err := htmlindex.Get(string(match[1]))
+	if err != nil {
+		return nil, err
+	}
+	return enc.NewDecoder().Bytes(data)
+}
+
+type dictInfo struct {
+	name        string
+	description string
+}
+
+// parse parses XDXF data, stops at first article if withEntries is false.
+// info is returned (as far as it's read) even if there is an error
+func parse(data []byte, withEntries bool) (*dictInfo, []*entry, error) {
+	decoder := newDecoder(bytes.NewReader(data))
+	// data is already converted to UTF-8
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	info := &dictInfo{}
+	entries := []*entry{}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return info, nil, err
+		}
+		elem, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch elem.Name.Local {
+		case "full_name", "full_title":
+			text, err := elementText(decoder)
+			if err != nil {
+				return info, nil, err
+			}
+			info.name = text
+		case "title":
+			text, err := elementText(decoder)
+			if err != nil {
+				return info, nil, err
+			}
+			if info.name == "" {
+				info.name = text
+			}
+		case "description":
+			text, err := elementText(decoder)
+			if err != nil {
+				return info, nil, err
+			}
+			info.description = text
+		case "ar":
+			if !withEntries {
+				return info, nil, nil
+			}
+			e, err := parseArticle(decoder, data)
+			if err != nil {
+				return info, nil, err
+			}
+			if len(e.terms) > 0 {
+				entries = append(entries, e)
+			}
+		}
+	}
+	return info, entries, nil
+}
+
+// elementText returns text content of current element
+func elementText(decoder *xml.Decoder) (string, error) {
+	var buf strings.Builder
+	depth := 1
+	for depth > 0 {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			buf.Write(token)
+		}
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// parseArticle reads an article after its <ar> start tag, body of entry
+// is a substring of data
+func parseArticle(decoder *xml.Decoder, data []byte) (*entry, error) {
+	start := decoder.InputOffset()
+	e := &entry{}
+	seen := map[string]bool{}
+	addTerm := func(term string) {
+		term = strings.Join(strings.Fields(term), " ")
+		if term == "" || seen[term] {
+			return
+		}
+		seen[term] = true
+		e.terms = append(e.terms, term)
+	}
+	depth := 1
+	// text of current <k>, with and without <opt> parts
+	var keyFull, keyShort *strings.Builder
+	optDepth := 0
+	for depth > 0 {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			depth++
+			switch token.Name.Local {
+			case "k":
+				keyFull, keyShort = &strings.Builder{}, &strings.Builder{}
+			case "opt":
+				optDepth++
+			}
+		case xml.EndElement:
+			depth--
+			switch token.Name.Local {
+			case "k":
+				if keyFull != nil {
+					addTerm(keyFull.String())
+					addTerm(keyShort.String())
+				}
+				keyFull, keyShort = nil, nil
+			case "opt":
+				optDepth = max(optDepth-1, 0)
+			}
+		case xml.CharData:
+			if keyFull == nil {
+				continue
+			}
+			keyFull.Write(token)
+			if optDepth == 0 {
+				keyShort.Write(token)
+			}
+		}
+	}
+	body := data[start:decoder.InputOffset()]
+	if end := bytes.LastIndex(body, []byte("</")); end >= 0 {
+		body = body[:end]
+	}
+	e.body = strings.TrimSpace(string(body))
+	return e, nil
+}
+
+func (d *dictionaryImp) Disabled() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.disabled
+}
+
+func (d *dictionaryImp) SetDisabled(disabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.disabled = disabled
+}
+
+func (d *dictionaryImp) Loaded() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.entries != nil
+}
+
+// Load reads and parses all articles
+func (d *dictionaryImp) Load() error {
+	data, err := readFile(d.path, 0)
+	if err != nil {
+		return err
+	}
+	_, entries, err := parse(data, true)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries = entries
+	return nil
+}
+
+func (d *dictionaryImp) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries = nil
+}
+
+func (d *dictionaryImp) DictName() string {
+	return d.name
+}
+
+func (d *dictionaryImp) EntryCount() (int, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.entries == nil {
+		return 0, errors.New("dictionary is not loaded")
+	}
+	return len(d.entries), nil
+}
+
+func (d *dictionaryImp) Description() string {
+	return d.description
+}
+
+// ResourceDir returns directory of XDXF file, paths of <rref> tags
+// are relative to it
+func (d *dictionaryImp) ResourceDir() string {
+	return filepath.Dir(d.path)
+}
+
+func (d *dictionaryImp) ResourceURL() string {
+	return "file://" + d.ResourceDir()
+}
+
+func (d *dictionaryImp) IndexPath() string {
+	return d.path
+}
+
+func (d *dictionaryImp) IndexFileSize() uint64 {
+	fi, err := os.Stat(d.path)
+	if err != nil {
+		return 0
+	}
+	return uint64(fi.Size())
+}
+
+func (d *dictionaryImp) InfoPath() string {
+	return ""
+}
+
+func (d *dictionaryImp) CalcHash() ([]byte, error) {
+	file, err := os.Open(d.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	hash := sha1.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// entryList implements termsearch.Entries
+type entryList []*entry
+
+func (l entryList) Len() int {
+	return len(l)
+}
+
+func (l entryList) Terms(index int) []string {
+	return l[index].terms
+}
+
+func (l entryList) Result(index int, score uint8) *common.SearchResultLow {
+	e := l[index]
+	return &common.SearchResultLow{
+		F_Score: score,
+		F_Terms: e.terms,
+		Items: func() []*common.SearchResultItem {
+			return []*common.SearchResultItem{{
+				Type: 'x',
+				Data: []byte(e.body),
+			}}
+		},
+		F_EntryIndex: uint64(index),
+	}
+}
+
+func (d *dictionaryImp) entryList() entryList {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.entries
+}
+
+func (d *dictionaryImp) EntryByIndex(index int) *common.SearchResultLow {
+	list := d.entryList()
+	if index < 0 || index >= list.Len() {
+		return nil
+	}
+	return list.Result(index, 0)
+}
+
+func (d *dictionaryImp) SearchFuzzy(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	return termsearch.Fuzzy(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchStartWith(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	return termsearch.StartWith(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchWordMatch(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	return termsearch.WordMatch(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchRegex(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, error) {
+	return termsearch.Regex(d.entryList(), query, workerCount, timeout)
+}
+
+func (d *dictionaryImp) SearchGlob(
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, error) {
+	return termsearch.Glob(d.entryList(), query, workerCount, timeout)
+}
@@ -0,0 +1,115 @@
+package xdxf
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ilius/is/v2"
+)
+
+func TestToHTML(t *testing.T) {
+	is := is.New(t)
+	test := func(article string, expected string) {
+		t.Helper()
+		is.Equal(ToHTML(article), expected)
+	}
+	test(
+		"<k>dog</k>\n<abr>n.</abr> <dtrn>chien</dtrn>",
+		"<b>dog</b><br/>\n"+`<i style="color:green">n.</i> <span class="dtrn">chien</span>`,
+	)
+	test(
+		`<ex>a <kref>big</kref> dog</ex> <co>rare</co> <kref k="pup">puppy</kref>`,
+		`<span style="color:steelblue">a <a href="bword://big">big</a> dog</span> `+
+			`<span style="color:gray">rare</span> <a href="bword://pup">puppy</a>`,
+	)
+	test(
+		`<c c="red">x</c> <c c="&quot;>">y</c> <c>z</c>`,
+		`<font color="red">x</font> <font color="green">y</font> <font color="green">z</font>`,
+	)
+	test(
+		`<rref>bark.wav</rref> <rref lctn="dog.png"/> <iref href="https://example.com">site</iref>`,
+		`<a href="sound://bark.wav"></a> <img src="dog.png"/> <a href="https://example.com">site</a>`,
+	)
+	test("a &lt;b&gt; &amp; <unknown>c</unknown><br/>d", "a &lt;b&gt; &amp; c<br/>d")
+	test("<b>not closed", "<b>not closed</b>")
+}
+
+const visualXDXF = `<?xml version="1.0" encoding="UTF-8" ?>
+<!DOCTYPE xdxf SYSTEM "http://xdxf.sourceforge.net/xdxf_lousy.dtd">
+<xdxf lang_from="ENG" lang_to="FRA" format="visual">
+<full_name>Animals</full_name>
+<description>English &amp; French</description>
+<ar><k>cat</k>
+<dtrn>chat</dtrn></ar>
+<ar><k>dog</k><k>hound</k>
+<dtrn>chien</dtrn>, see <kref>cat</kref></ar>
+<ar><k>work<opt>s</opt></k>
+travail</ar>
+</xdxf>
+`
+
+const logicalXDXF = `<?xml version="1.0" encoding="UTF-8" ?>
+<xdxf lang_from="ENG" lang_to="FRA" format="logical" revision="033">
+<meta_info>
+<title>Fruits</title>
+<full_title>Fruits Dictionary</full_title>
+<description>Some fruits</description>
+</meta_info>
+<lexicon>
+<ar><k>apple</k><def><deftext><dtrn>pomme</dtrn></deftext></def></ar>
+</lexicon>
+</xdxf>
+`
+
+func TestOpen(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "animals.xdxf"), []byte(visualXDXF), 0o644)
+	is.NotErr(err)
+	err = os.MkdirAll(filepath.Join(dir, "sub"), 0o755)
+	is.NotErr(err)
+	{
+		file, err := os.Create(filepath.Join(dir, "sub", "fruits.xdxf.dz"))
+		is.NotErr(err)
+		writer := gzip.NewWriter(file)
+		_, err = writer.Write([]byte(logicalXDXF))
+		is.NotErr(err)
+		is.NotErr(writer.Close())
+		is.NotErr(file.Close())
+	}
+
+	dicList := Open([]string{dir}, map[string]int{"Fruits Dictionary": -1})
+	is.Equal(len(dicList), 2)
+
+	dic := dicList[0]
+	is.Equal(dic.DictName(), "Animals")
+	is.Equal(dic.Description(), "English & French")
+	is.Equal(dic.ResourceDir(), dir)
+	is.True(dic.Loaded())
+	count, err := dic.EntryCount()
+	is.NotErr(err)
+	is.Equal(count, 3)
+
+	results := dic.SearchStartWith("houn", 1, 0)
+	is.Equal(len(results), 1)
+	is.Equal(results[0].F_Terms, []string{"dog", "hound"})
+	items := results[0].Items()
+	is.Equal(len(items), 1)
+	is.Equal(items[0].Type, 'x')
+	is.Equal(string(items[0].Data), "<k>dog</k><k>hound</k>\n<dtrn>chien</dtrn>, see <kref>cat</kref>")
+	is.Equal(dic.EntryByIndex(2).F_Terms, []string{"works", "work"})
+
+	other := dicList[1]
+	is.Equal(other.DictName(), "Fruits Dictionary")
+	is.Equal(other.Description(), "Some fruits")
+	is.True(other.Disabled())
+	is.False(other.Loaded())
+	is.NotErr(other.Load())
+	entry := other.EntryByIndex(0)
+	is.Equal(entry.F_Terms, []string{"apple"})
+	is.Equal(ToHTML(string(entry.Items()[0].Data)), `<b>apple</b><span class="dtrn">pomme</span>`)
+	other.Close()
+	is.False(other.Loaded())
+}
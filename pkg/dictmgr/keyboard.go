@@ -0,0 +1,124 @@
+package dictmgr
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ilius/ayandict/v2/pkg/config"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/kblayout"
+	common "github.com/ilius/go-dict-commons"
+)
+
+// results with a lower best score than this are considered poor,
+// and query is retried with other keyboard layouts
+const poorResultScore = uint8(150)
+
+var (
+	kbLayoutMutex sync.Mutex
+	kbLayoutKey   string
+	kbLayouts     []*kblayout.Layout
+)
+
+// getKeyboardLayouts returns layouts of conf.KeyboardLayouts, which are
+// loaded from conf.KeyboardLayoutDir (once) or are built-in
+func getKeyboardLayouts(conf *config.Config) []*kblayout.Layout {
+	kbLayoutMutex.Lock()
+	defer kbLayoutMutex.Unlock()
+	key := conf.KeyboardLayoutDir + "\n" + strings.Join(conf.KeyboardLayouts, ",")
+	if kbLayouts != nil && key == kbLayoutKey {
+		return kbLayouts
+	}
+	dir := conf.KeyboardLayoutDir
+	if dir != "" && !filepath.IsAbs(dir) {
+		dir = filepath.Join(config.GetConfigDir(), dir)
+	}
+	list := []*kblayout.Layout{}
+	for _, name := range conf.KeyboardLayouts {
+		if dir != "" {
+			layout, err := kblayout.Load(filepath.Join(dir, name+".txt"))
+			if err == nil {
+				list = append(list, layout)
+				continue
+			}
+			if !os.IsNotExist(err) {
+				slog.Error("error loading keyboard layout", "err", err, "name", name)
+			}
+		}
+		layout := kblayout.Builtin(name)
+		if layout == nil {
+			slog.Error("unknown keyboard layout", "name", name)
+			continue
+		}
+		list = append(list, layout)
+	}
+	kbLayoutKey = key
+	kbLayouts = list
+	return list
+}
+
+func bestScore(results []common.SearchResultIface) uint8 {
+	best := uint8(0)
+	for _, res := range results {
+		best = max(best, res.Score())
+	}
+	return best
+}
+
+// lookupRemapped retries term of plan with each keyboard layout, and returns
+// results of the remapped query that has the best results, marked with
+// "keyboard: ..." as Via, along with the remapped query.
+// dictList is filtered by plan for each remapped query, since remapped
+// query is usually in another script.
+// ctx should have the deadline of the whole lookup
+func lookupRemapped(
+	ctx context.Context,
+	plan *QueryPlan,
+	dictList []common.Dictionary,
+	conf *config.Config,
+	resultFlags uint32,
+) ([]common.SearchResultIface, string) {
+	query := plan.Term
+	var bestResults []common.SearchResultIface
+	bestQuery := ""
+	for _, layout := range getKeyboardLayouts(conf) {
+		remapped, ok := layout.Remap(query)
+		if !ok {
+			continue
+		}
+		remappedPlan := *plan
+		remappedPlan.Term = remapped
+		perDict, _ := searchDicts(
+			ctx,
+			remappedPlan.filterDicts(dictList),
+			remapped,
+			conf,
+			plan.Mode,
+			resultFlags,
+			nil,
+		)
+		if ctx.Err() != nil {
+			break
+		}
+		results := []common.SearchResultIface{}
+		for _, dictResults := range perDict {
+			for _, res := range dictResults {
+				if sr, ok := res.(*SearchResult); ok {
+					sr.via = "keyboard: " + layout.Name
+				}
+				results = append(results, res)
+			}
+		}
+		if len(results) > 0 && bestScore(results) > bestScore(bestResults) {
+			bestResults = results
+			bestQuery = remapped
+		}
+	}
+	if bestQuery != "" {
+		slog.Debug("found results via keyboard layout", "query", query, "remapped", bestQuery)
+	}
+	return bestResults, bestQuery
+}
@@ -0,0 +1,156 @@
+package dictmgr
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ilius/ayandict/v2/pkg/config"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/lemma"
+	common "github.com/ilius/go-dict-commons"
+)
+
+// maximum number of lemma candidates that are looked up for a query
+const maxLemmaCandidates = 6
+
+var (
+	lemmaMutex       sync.Mutex
+	lemmaKey         string
+	lemmaLemmatizers []lemma.Lemmatizer
+)
+
+// getLemmatizers loads affix rules from conf.LemmaDir (once) and
+// returns them followed by built-in lemmatizers of conf.LemmaBuiltin
+func getLemmatizers(conf *config.Config) []lemma.Lemmatizer {
+	lemmaMutex.Lock()
+	defer lemmaMutex.Unlock()
+	key := conf.LemmaDir + "\n" + strings.Join(conf.LemmaBuiltin, ",")
+	if lemmaLemmatizers != nil && key == lemmaKey {
+		return lemmaLemmatizers
+	}
+	list := []lemma.Lemmatizer{}
+	if conf.LemmaDir != "" {
+		dir := conf.LemmaDir
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(config.GetConfigDir(), dir)
+		}
+		hunspellList, err := lemma.LoadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			slog.Error("error loading lemma rules", "err", err, "dir", dir)
+		}
+		for _, h := range hunspellList {
+			list = append(list, h)
+		}
+	}
+	for _, lang := range conf.LemmaBuiltin {
+		lem := lemma.Builtin(lang)
+		if lem == nil {
+			slog.Error("unknown built-in lemmatizer", "lang", lang)
+			continue
+		}
+		list = append(list, lem)
+	}
+	lemmaKey = key
+	lemmaLemmatizers = list
+	return list
+}
+
+// isWordQueryMode returns true for modes that query is a word (or words),
+// not a pattern, so it can be retried with other forms of query
+func isWordQueryMode(mode QueryMode) bool {
+	switch mode {
+	case QueryModeFuzzy, QueryModeStartWith, QueryModeWordMatch, QueryModeExact:
+		return true
+	}
+	return false
+}
+
+func hasTerm(res common.SearchResultIface, term string) bool {
+	for _, resTerm := range res.Terms() {
+		if strings.ToLower(resTerm) == term {
+			return true
+		}
+	}
+	return false
+}
+
+func hasExactMatch(results []common.SearchResultIface, query string) bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+	for _, res := range results {
+		if hasTerm(res, query) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupLemmas looks up candidate base forms of query in exact mode
+// (whatever the query mode is), and returns results whose headword is
+// one of them, marked with "lemma: ..." as Via.
+// ctx should have the deadline of the whole lookup
+func lookupLemmas(
+	ctx context.Context,
+	dictList []common.Dictionary,
+	query string,
+	conf *config.Config,
+	resultFlags uint32,
+) []common.SearchResultIface {
+	candidates := lemma.Candidates(query, getLemmatizers(conf))
+	if len(candidates) == 0 {
+		return nil
+	}
+	if len(candidates) > maxLemmaCandidates {
+		candidates = candidates[:maxLemmaCandidates]
+	}
+	results := []common.SearchResultIface{}
+	for _, candidate := range candidates {
+		perDict, _ := searchDicts(ctx, dictList, candidate, conf, QueryModeExact, resultFlags, nil)
+		if ctx.Err() != nil {
+			break
+		}
+		for _, dictResults := range perDict {
+			for _, res := range dictResults {
+				if sr, ok := res.(*SearchResult); ok {
+					sr.via = "lemma: " + candidate
+				}
+				results = append(results, res)
+			}
+		}
+	}
+	if len(results) > 0 {
+		slog.Debug("found results via lemma", "query", query, "count", len(results))
+	}
+	return results
+}
+
+// mergeResults adds extra results to results, and if an entry is in both,
+// keeps the one with higher score
+func mergeResults(
+	results []common.SearchResultIface,
+	extra []common.SearchResultIface,
+) []common.SearchResultIface {
+	resultKey := func(res common.SearchResultIface) string {
+		return fmt.Sprintf("%s\x00%d", res.DictName(), res.EntryIndex())
+	}
+	indexByKey := make(map[string]int, len(results))
+	for i, res := range results {
+		indexByKey[resultKey(res)] = i
+	}
+	for _, res := range extra {
+		key := resultKey(res)
+		i, ok := indexByKey[key]
+		if !ok {
+			indexByKey[key] = len(results)
+			results = append(results, res)
+			continue
+		}
+		if res.Score() > results[i].Score() {
+			results[i] = res
+		}
+	}
+	return results
+}
@@ -1,9 +1,12 @@
 package dictmgr
 
 import (
+	"context"
 	"log/slog"
-	"sort"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/ilius/ayandict/v2/pkg/config"
 	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dicts"
@@ -18,32 +21,106 @@ const (
 	QueryModeRegex
 	QueryModeGlob
 	QueryModeWordMatch
+	QueryModeDefinition
+	QueryModeExact
+	QueryModeEndsWith
+	QueryModeContains
 )
 
+// search runs the search on one dictionary in background, and returns
+// nil as soon as ctx is done.
+// Searches using our own indexes stop when ctx is done, but dictionary
+// implementations do not accept a context, so the search timeout is
+// reduced to the deadline of ctx (if any) to make sure an abandoned
+// search does not keep running in background for too long.
+// Per-dictionary overrides of timeout, worker count and max results
+// in DictionarySettings are applied here
 func search(
+	ctx context.Context,
 	dic common.Dictionary,
 	conf *config.Config,
 	mode QueryMode,
 	query string,
 ) []*common.SearchResultLow {
-	workerCount := conf.SearchWorkerCount
-	timeout := conf.SearchTimeout
-	dictName := dic.DictName()
-	ds := dicts.DictSettingsMap[dictName]
-	if ds == nil {
-		ds = &dicts.DictionarySettings{}
+	if ctx.Err() != nil {
+		return nil
+	}
+	ds := dictSettings(dic.DictName())
+	timeout := ds.EffectiveSearchTimeout(conf.SearchTimeout)
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil
+		}
+		if remaining < timeout {
+			timeout = remaining
+		}
+	}
+	workerCount := ds.EffectiveSearchWorkerCount(conf.SearchWorkerCount)
+	ch := make(chan []*common.SearchResultLow, 1)
+	go func() {
+		ch <- searchSync(ctx, dic, conf, ds, mode, query, workerCount, timeout)
+	}()
+	select {
+	case results := <-ch:
+		return limitResults(results, ds.MaxResults)
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// ctxCheckInterval is the number of entries a search loop goes through
+// between checks of its context
+const ctxCheckInterval = 256
+
+// ctxDone returns true if ctx is done, checking it only on every
+// ctxCheckInterval-th index of a search loop
+func ctxDone(ctx context.Context, index int) bool {
+	return index%ctxCheckInterval == 0 && ctx.Err() != nil
+}
+
+// limitResults keeps at most limit results with highest scores,
+// zero limit means no limit
+func limitResults(
+	results []*common.SearchResultLow,
+	limit int,
+) []*common.SearchResultLow {
+	if limit <= 0 || len(results) <= limit {
+		return results
 	}
+	slices.SortStableFunc(results, func(a, b *common.SearchResultLow) int {
+		return int(b.F_Score) - int(a.F_Score)
+	})
+	return results[:limit]
+}
+
+func searchSync(
+	ctx context.Context,
+	dic common.Dictionary,
+	conf *config.Config,
+	ds *dicts.DictionarySettings,
+	mode QueryMode,
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
 	switch mode {
 	case QueryModeStartWith:
 		if !ds.StartWith() {
 			return nil
 		}
+		if results, ok := searchNormalized(ctx, dic, conf, ds, mode, query, workerCount, timeout); ok {
+			return results
+		}
 		return dic.SearchStartWith(query, workerCount, timeout)
 	case QueryModeRegex:
 		if !ds.Regex() {
 			return nil
 		}
-		results, err := dic.SearchRegex(query, workerCount, timeout)
+		results, ok, err := searchRegexIndexed(ctx, dic, query, workerCount, timeout)
+		if !ok {
+			results, err = dic.SearchRegex(query, workerCount, timeout)
+		}
 		if err != nil {
 			slog.Error("error in SearchRegex: " + err.Error())
 			return nil
@@ -53,7 +130,10 @@ func search(
 		if !ds.Glob() {
 			return nil
 		}
-		results, err := dic.SearchGlob(query, workerCount, timeout)
+		results, ok, err := searchGlobIndexed(ctx, dic, query, workerCount, timeout)
+		if !ok {
+			results, err = dic.SearchGlob(query, workerCount, timeout)
+		}
 		if err != nil {
 			slog.Error("error in SearchGlob: " + err.Error())
 			return nil
@@ -63,60 +143,378 @@ func search(
 		if !ds.WordMatch() {
 			return nil
 		}
+		if results, ok := searchNormalized(ctx, dic, conf, ds, mode, query, workerCount, timeout); ok {
+			return results
+		}
 		return dic.SearchWordMatch(query, workerCount, timeout)
+	case QueryModeDefinition:
+		if !ds.Definition() {
+			return nil
+		}
+		return searchDefinition(ctx, dic, conf, query)
+	case QueryModeExact:
+		if !ds.Exact() {
+			return nil
+		}
+		return searchExact(ctx, dic, query, workerCount, timeout)
+	case QueryModeEndsWith:
+		if !ds.EndsWith() {
+			return nil
+		}
+		return searchEndsWith(ctx, dic, query, workerCount, timeout)
+	case QueryModeContains:
+		if !ds.Contains() {
+			return nil
+		}
+		return searchContains(ctx, dic, query, workerCount, timeout)
 	}
 	if !ds.Fuzzy() {
 		return nil
 	}
+	if results, ok := searchNormalized(ctx, dic, conf, ds, mode, query, workerCount, timeout); ok {
+		return results
+	}
+	if results, ok := searchFuzzyIndexed(ctx, dic, query, workerCount, timeout); ok {
+		return results
+	}
 	return dic.SearchFuzzy(query, workerCount, timeout)
 }
 
+// LookupResult is the output of LookupHTML
+type LookupResult struct {
+	Results []common.SearchResultIface
+
+	// TimedOut is the list of dictionaries that did not finish searching
+	// before conf.SearchTotalTimeout, in which case Results are partial
+	TimedOut []string
+
+	// RemappedQuery is set if query seemed to be typed with a wrong
+	// keyboard layout, and looking it up with another layout has results
+	// which are included in Results
+	RemappedQuery string
+
+	// Suggestions are headwords close to query (for "did you mean"),
+	// only set when there is no result
+	Suggestions []string
+
+	// Direction is the detected query direction, like "en → fa",
+	// empty if source or target language is not known
+	Direction string
+
+	// Notice explains why results may be missing, like definition
+	// index being disabled or not ready in Definition mode
+	Notice string
+
+	// DictOrder is the order of searched dictionaries (in the dictionary
+	// group, if any), which is used for sorting Results, see GroupResults
+	DictOrder map[string]int
+}
+
+type dictResults struct {
+	index   int
+	results []common.SearchResultIface
+}
+
+func activeDicts() []common.Dictionary {
+	dictList := dicts.List()
+	list := make([]common.Dictionary, 0, len(dictList))
+	for _, dic := range dictList {
+		if dic.Disabled() || !dic.Loaded() {
+			continue
+		}
+		list = append(list, dic)
+	}
+	return list
+}
+
+func withTotalTimeout(
+	ctx context.Context,
+	conf *config.Config,
+) (context.Context, context.CancelFunc) {
+	if conf.SearchTotalTimeout > 0 {
+		return context.WithTimeout(ctx, conf.SearchTotalTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// startSearch searches dictList in background using a pool of
+// conf.SearchDictWorkerCount workers, and sends results of each dictionary
+// to the returned channel as soon as it is finished.
+// The channel is closed when all dictionaries are searched, or ctx is done
+func startSearch(
+	ctx context.Context,
+	dictList []common.Dictionary,
+	query string,
+	conf *config.Config,
+	mode QueryMode,
+	resultFlags uint32,
+) <-chan *dictResults {
+	n := len(dictList)
+	// buffered, so workers never block if we stop reading
+	ch := make(chan *dictResults, n)
+	if n == 0 {
+		close(ch)
+		return ch
+	}
+
+	workerCount := conf.SearchDictWorkerCount
+	if workerCount < 1 {
+		workerCount = 1
+	}
+	if workerCount > n {
+		workerCount = n
+	}
+
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for index := range dictList {
+			select {
+			case jobs <- index:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	worker := func() {
+		defer wg.Done()
+		for index := range jobs {
+			dic := dictList[index]
+			lowResults := search(ctx, dic, conf, mode, query)
+			if ctx.Err() != nil {
+				return
+			}
+			results := make([]common.SearchResultIface, len(lowResults))
+			for i, res := range lowResults {
+				results[i] = NewSearchResult(res, dic, conf, resultFlags)
+			}
+			ch <- &dictResults{
+				index:   index,
+				results: results,
+			}
+		}
+	}
+	wg.Add(workerCount)
+	for range workerCount {
+		go worker()
+	}
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+	return ch
+}
+
+// searchDicts searches dictList in parallel, and returns results of
+// each dictionary (by index in dictList) and a boolean per dictionary
+// which is false if it did not finish searching before ctx is done
+// (ctx has the deadline of conf.SearchTotalTimeout, see withTotalTimeout).
+// onDict (if not nil) is called with results of each dictionary
+// as soon as it is finished
+func searchDicts(
+	ctx context.Context,
+	dictList []common.Dictionary,
+	query string,
+	conf *config.Config,
+	mode QueryMode,
+	resultFlags uint32,
+	onDict func(index int, results []common.SearchResultIface),
+) ([][]common.SearchResultIface, []bool) {
+	n := len(dictList)
+	perDict := make([][]common.SearchResultIface, n)
+	done := make([]bool, n)
+	for dr := range startSearch(ctx, dictList, query, conf, mode, resultFlags) {
+		perDict[dr.index] = dr.results
+		done[dr.index] = true
+		if onDict != nil {
+			onDict(dr.index, dr.results)
+		}
+	}
+	return perDict, done
+}
+
+// lessByHeadwordAndDict is used for sorting results with the same score
+func lessByHeadwordAndDict(
+	res1 common.SearchResultIface,
+	res2 common.SearchResultIface,
+	dictOrder map[string]int,
+) bool {
+	term1 := strings.ToLower(res1.Terms()[0])
+	term2 := strings.ToLower(res2.Terms()[0])
+	if term1 != term2 {
+		return term1 < term2
+	}
+	dictName1 := res1.DictName()
+	dictName2 := res2.DictName()
+	do1 := dictOrder[dictName1]
+	do2 := dictOrder[dictName2]
+	if do1 != do2 {
+		return do1 < do2
+	}
+	if dictName1 != dictName2 {
+		return dictName1 < dictName2
+	}
+	// if we do not use entryIndex, the resulting order can be random
+	// for entries with same headwords
+	// and no need to compare headwords for StarDict when we have entryIndex
+	// since they are already sorted in idx file.
+	// if we added other formats, maybe we can add a config for this
+	return res1.EntryIndex() < res2.EntryIndex()
+}
+
+// LookupHTML parses query with ParseQuery, and searches enabled
+// dictionaries of group (or all enabled dictionaries if group is empty)
+// that are allowed by query filters, in parallel, and returns sorted
+// results. If conf.SearchTotalTimeout is reached, results of dictionaries
+// that have finished so far are returned, and the rest are listed in TimedOut
 func LookupHTML(
 	query string,
 	conf *config.Config,
 	mode QueryMode,
+	group string,
 	resultFlags uint32,
 	limit int,
-) []common.SearchResultIface {
+) *LookupResult {
+	plan := ParseQuery(query, mode, conf.DetectQueryLang)
+	plan.CollapseDuplicates = conf.CollapseDuplicates
+	// error is only returned when ctx is done
+	lookupResult, _ := LookupHTMLContext(
+		context.Background(),
+		plan,
+		conf,
+		group,
+		resultFlags,
+		limit,
+	)
+	return lookupResult
+}
+
+// LookupHTMLContext is like LookupHTML, but takes a parsed query,
+// and stops searching as soon as ctx is done, and returns ctx.Err()
+// in that case
+func LookupHTMLContext(
+	ctx context.Context,
+	plan *QueryPlan,
+	conf *config.Config,
+	group string,
+	resultFlags uint32,
+	limit int,
+) (*LookupResult, error) {
+	return lookup(ctx, plan, conf, group, resultFlags, limit, nil)
+}
+
+// lookup implements LookupHTMLContext and LookupHTMLStream, onDict
+// (if not nil) is called with sorted and limited results of each
+// dictionary as soon as it is finished, unless the result is cached
+func lookup(
+	ctx context.Context,
+	plan *QueryPlan,
+	conf *config.Config,
+	group string,
+	resultFlags uint32,
+	limit int,
+	onDict func(*DictLookupResult),
+) (*LookupResult, error) {
+	if limit == 0 {
+		limit = conf.MaxResultsTotal
+	}
+	query := plan.Term
+	mode := plan.Mode
+	groupList := groupDicts(group)
+	cacheKey := ""
+	if conf.QueryCacheSize > 0 {
+		cacheKey = queryCacheKey(plan, groupList, resultFlags, limit)
+		if lookupResult := resultCache.get(cacheKey); lookupResult != nil {
+			return lookupResult, nil
+		}
+	}
+	// one deadline for the whole lookup, including fallbacks
+	searchCtx, cancel := withTotalTimeout(ctx, conf)
+	defer cancel()
+	dictList := plan.filterDicts(groupList)
+	dictOrder := dictListOrder(dictList)
+	var onResults func(int, []common.SearchResultIface)
+	if onDict != nil {
+		onResults = func(index int, results []common.SearchResultIface) {
+			if len(results) == 0 {
+				return
+			}
+			// results are modified later (like by collapseDuplicates)
+			// while caller may be still using these
+			results = cloneResults(results)
+			sortResults(results, conf, query, dictOrder)
+			if limit > 0 && len(results) > limit {
+				results = results[:limit]
+			}
+			onDict(&DictLookupResult{
+				DictName: dictList[index].DictName(),
+				Results:  results,
+			})
+		}
+	}
+	perDict, done := searchDicts(searchCtx, dictList, query, conf, mode, resultFlags, onResults)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	results := []common.SearchResultIface{}
-	for _, dic := range dicts.DictList {
-		if dic.Disabled() || !dic.Loaded() {
+	timedOut := []string{}
+	for index, dictResults := range perDict {
+		if !done[index] {
+			timedOut = append(timedOut, dictList[index].DictName())
 			continue
 		}
-		for _, res := range search(dic, conf, mode, query) {
-			results = append(results, NewSearchResult(res, dic, conf, resultFlags))
-		}
-	}
-	sort.Slice(results, func(i, j int) bool {
-		res1 := results[i]
-		res2 := results[j]
-		score1 := res1.Score()
-		score2 := res2.Score()
-		if score1 != score2 {
-			return score1 > score2
-		}
-		term1 := strings.ToLower(res1.Terms()[0])
-		term2 := strings.ToLower(res2.Terms()[0])
-		if term1 != term2 {
-			return term1 < term2
-		}
-		do1 := dicts.DictsOrder[res1.DictName()]
-		do2 := dicts.DictsOrder[res2.DictName()]
-		if do1 != do2 {
-			return do1 < do2
-		}
-		// if we do not use entryIndex, the resulting order can be random
-		// for entries with same headwords
-		// and no need to compare headwords for StarDict when we have entryIndex
-		// since they are already sorted in idx file.
-		// if we added other formats, maybe we can add a config for this
-		return res1.EntryIndex() < res2.EntryIndex()
-	})
-	if limit == 0 {
-		limit = conf.MaxResultsTotal
+		results = append(results, dictResults...)
+	}
+	if len(timedOut) > 0 {
+		slog.Warn("search timeout", "query", query, "timedOut", timedOut)
+	}
+	if conf.LemmaFallback && isWordQueryMode(mode) && !hasExactMatch(results, query) {
+		lemmaResults := lookupLemmas(searchCtx, dictList, query, conf, resultFlags)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		results = mergeResults(results, lemmaResults)
+	}
+	remappedQuery := ""
+	if len(conf.KeyboardLayouts) > 0 && isWordQueryMode(mode) && bestScore(results) < poorResultScore {
+		var remappedResults []common.SearchResultIface
+		remappedResults, remappedQuery = lookupRemapped(searchCtx, plan, groupList, conf, resultFlags)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		results = mergeResults(results, remappedResults)
+	}
+	sortResults(results, conf, query, dictOrder)
+	if plan.CollapseDuplicates {
+		results = collapseDuplicates(results, limit)
 	}
 	if limit > 0 && len(results) > limit {
 		results = results[:limit]
 	}
-	return results
+	var suggestions []string
+	if len(results) == 0 && isWordQueryMode(mode) {
+		suggestions = Suggest(query, conf)
+	}
+	notice := ""
+	if mode == QueryModeDefinition {
+		if err := definitionIndexError(conf, dictList); err != nil {
+			notice = err.Error()
+		}
+	}
+	lookupResult := &LookupResult{
+		Results:       results,
+		TimedOut:      timedOut,
+		RemappedQuery: remappedQuery,
+		Suggestions:   suggestions,
+		Direction:     queryDirection(plan, dictList),
+		Notice:        notice,
+		DictOrder:     dictOrder,
+	}
+	// partial results are not cached
+	if cacheKey != "" && len(timedOut) == 0 && notice == "" && searchCtx.Err() == nil {
+		resultCache.put(cacheKey, lookupResult, conf.QueryCacheSize)
+	}
+	return lookupResult, nil
 }
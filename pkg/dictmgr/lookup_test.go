@@ -0,0 +1,178 @@
+package dictmgr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/dictmgrtest"
+	common "github.com/ilius/go-dict-commons"
+	"github.com/ilius/is/v2"
+)
+
+func TestLimitResults(t *testing.T) {
+	is := is.New(t)
+	newResults := func() []*common.SearchResultLow {
+		return []*common.SearchResultLow{
+			{F_Terms: []string{"a"}, F_Score: 100},
+			{F_Terms: []string{"b"}, F_Score: 140},
+			{F_Terms: []string{"c"}, F_Score: 180},
+			{F_Terms: []string{"d"}, F_Score: 140},
+		}
+	}
+	terms := func(results []*common.SearchResultLow) []string {
+		list := make([]string, len(results))
+		for i, res := range results {
+			list[i] = res.F_Terms[0]
+		}
+		return list
+	}
+	is.Equal(terms(limitResults(newResults(), 0)), []string{"a", "b", "c", "d"})
+	is.Equal(terms(limitResults(newResults(), 4)), []string{"a", "b", "c", "d"})
+	is.Equal(terms(limitResults(newResults(), 3)), []string{"c", "b", "d"})
+	is.Equal(terms(limitResults(newResults(), 1)), []string{"c"})
+}
+
+func lookupTerms(results []common.SearchResultIface) []string {
+	list := make([]string, len(results))
+	for i, res := range results {
+		list[i] = res.DictName() + ": " + res.Terms()[0]
+	}
+	return list
+}
+
+func TestLookupHTML(t *testing.T) {
+	is := is.New(t)
+	conf := dictmgrtest.Config()
+	dictmgrtest.Install(
+		t,
+		dictmgrtest.New("d1", dictmgrtest.E("apples", "plural of apple")),
+		dictmgrtest.New(
+			"d2",
+			dictmgrtest.E("apple", "a fruit"),
+			dictmgrtest.E("applesauce", "a sauce"),
+		),
+	)
+	dictmgrtest.InstallGroup("g2", "d2")
+
+	result := LookupHTML("apple", conf, QueryModeStartWith, "", 0, 0)
+	is.Equal(lookupTerms(result.Results), []string{
+		"d2: apple",
+		"d1: apples",
+		"d2: applesauce",
+	})
+	is.Equal(len(result.TimedOut), 0)
+
+	result = LookupHTML("apple", conf, QueryModeStartWith, "g2", 0, 0)
+	is.Equal(lookupTerms(result.Results), []string{
+		"d2: apple",
+		"d2: applesauce",
+	})
+
+	result = LookupHTML("-dict:d2 apple", conf, QueryModeStartWith, "", 0, 0)
+	is.Equal(lookupTerms(result.Results), []string{"d1: apples"})
+
+	dictmgrtest.Settings("d2").SetStartWith(false)
+	result = LookupHTML("apple", conf, QueryModeStartWith, "", 0, 0)
+	is.Equal(lookupTerms(result.Results), []string{"d1: apples"})
+}
+
+func TestLookupHTMLTimeout(t *testing.T) {
+	is := is.New(t)
+	conf := dictmgrtest.Config()
+	conf.SearchTotalTimeout = 50 * time.Millisecond
+	slow := dictmgrtest.New("slow", dictmgrtest.E("apple", "a fruit"))
+	slow.Latency = time.Second
+	dictmgrtest.Install(
+		t,
+		slow,
+		dictmgrtest.New("fast", dictmgrtest.E("apple", "a fruit")),
+	)
+	result := LookupHTML("apple", conf, QueryModeFuzzy, "", 0, 0)
+	is.Equal(lookupTerms(result.Results), []string{"fast: apple"})
+	is.Equal(result.TimedOut, []string{"slow"})
+}
+
+func TestRandomEntry(t *testing.T) {
+	is := is.New(t)
+	conf := dictmgrtest.Config()
+	dictmgrtest.Install(
+		t,
+		dictmgrtest.New("d1", dictmgrtest.E("apple", "a fruit")),
+		dictmgrtest.New("d2", dictmgrtest.E("banana", "another fruit")),
+		dictmgrtest.New("empty"),
+	)
+	dictmgrtest.InstallGroup("g2", "d2")
+	dictmgrtest.InstallGroup("empty", "empty")
+	for range 5 {
+		entry := RandomEntry(conf, "g2", 0)
+		is.Equal(entry.DictName(), "d2")
+		is.Equal(entry.Terms(), []string{"banana"})
+		is.Equal(entry.DefinitionsHTML(), []string{"another fruit<br/>\n"})
+	}
+	is.True(RandomEntry(conf, "empty", 0) == nil)
+}
+
+func TestLookupHTMLLemmaFallback(t *testing.T) {
+	is := is.New(t)
+	conf := dictmgrtest.Config()
+	conf.LemmaFallback = true
+	conf.LemmaDir = ""
+	conf.LemmaBuiltin = []string{"en"}
+	dictmgrtest.Install(
+		t,
+		dictmgrtest.New(
+			"d1",
+			dictmgrtest.E("run", "to move fast"),
+			dictmgrtest.E("rune", "a letter"),
+			dictmgrtest.E("runway", "a strip"),
+		),
+	)
+	result := LookupHTML("running", conf, QueryModeFuzzy, "", 0, 0)
+	is.Equal(lookupTerms(result.Results), []string{"d1: run"})
+	is.Equal(ResultVia(result.Results[0]), "lemma: run")
+}
+
+func TestLookupHTMLSharedDeadline(t *testing.T) {
+	is := is.New(t)
+	conf := dictmgrtest.Config()
+	conf.DetectQueryLang = false
+	conf.KeyboardLayouts = []string{"fa"}
+	conf.KeyboardLayoutDir = ""
+	conf.SearchTotalTimeout = 100 * time.Millisecond
+	slow := dictmgrtest.New("slow", dictmgrtest.E("apple", "a fruit"))
+	slow.Latency = 80 * time.Millisecond
+	dictmgrtest.Install(t, slow)
+	// main search and keyboard layout fallback take 160ms together,
+	// so the fallback is stopped by deadline of the whole lookup
+	t1 := time.Now()
+	result := LookupHTML("xyz", conf, QueryModeFuzzy, "", 0, 0)
+	is.True(time.Since(t1) < 150*time.Millisecond)
+	is.Equal(len(result.Results), 0)
+	is.Equal(result.RemappedQuery, "")
+}
+
+func TestLookupHTMLDefinitionNotice(t *testing.T) {
+	is := is.New(t)
+	conf := dictmgrtest.Config()
+	dictmgrtest.Install(t, dictmgrtest.New("d1", dictmgrtest.E("apple", "a fruit")))
+
+	result := LookupHTML("fruit", conf, QueryModeDefinition, "", 0, 0)
+	is.Equal(len(result.Results), 0)
+	is.Equal(result.Notice, ErrDefinitionIndexDisabled.Error())
+
+	conf.DefinitionIndex = true
+	indexMutex.Lock()
+	defIndexPending["d1"] = true
+	indexMutex.Unlock()
+	t.Cleanup(func() {
+		indexMutex.Lock()
+		delete(defIndexPending, "d1")
+		indexMutex.Unlock()
+	})
+	result = LookupHTML("fruit", conf, QueryModeDefinition, "", 0, 0)
+	is.Equal(result.Notice, ErrDefinitionIndexNotReady.Error())
+
+	// no notice in other modes
+	result = LookupHTML("apple", conf, QueryModeFuzzy, "", 0, 0)
+	is.Equal(result.Notice, "")
+}
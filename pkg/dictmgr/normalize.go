@@ -0,0 +1,117 @@
+package dictmgr
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ilius/ayandict/v2/pkg/config"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dicts"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/textnorm"
+	common "github.com/ilius/go-dict-commons"
+	su "github.com/ilius/go-dict-commons/search_utils"
+)
+
+// same minimum score that is used by stardict package
+// for StartWith and WordMatch
+const wordMinScore = uint8(140)
+
+// normProfile returns normalization profile of dictionary, which is
+// ds.Normalization if set, or conf.Normalization otherwise.
+// returns nil if normalization is disabled or profile is invalid
+func normProfile(conf *config.Config, ds *dicts.DictionarySettings) *textnorm.Profile {
+	steps := conf.Normalization
+	if len(ds.Normalization) > 0 {
+		steps = ds.Normalization
+	}
+	profile, err := textnorm.NewProfile(steps)
+	if err != nil {
+		slog.Error("bad normalization config", "err", err, "steps", steps)
+		return nil
+	}
+	return profile
+}
+
+// searchNormalized searches normalized headwords (using index built in
+// background) with normalized query, and scores them like stardict package.
+// returns ok=false if normalization is disabled for dictionary, or index
+// is not ready yet
+func searchNormalized(
+	ctx context.Context,
+	dic common.Dictionary,
+	conf *config.Config,
+	ds *dicts.DictionarySettings,
+	mode QueryMode,
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) ([]*common.SearchResultLow, bool) {
+	profile := normProfile(conf, ds)
+	if profile == nil {
+		return nil, false
+	}
+	idx := getNormIndex(dic.DictName(), profile)
+	if idx == nil {
+		return nil, false
+	}
+	query = strings.ToLower(strings.TrimSpace(profile.Normalize(query)))
+	if query == "" {
+		return nil, true
+	}
+	var score func(terms []string, buff []uint16) uint8
+	switch mode {
+	case QueryModeFuzzy:
+		args := fuzzyArgs(query)
+		score = func(terms []string, buff []uint16) uint8 {
+			s := su.ScoreFuzzy(terms, args, buff)
+			if s < fuzzyMinScore {
+				return 0
+			}
+			return s
+		}
+	case QueryModeStartWith:
+		score = func(terms []string, _ []uint16) uint8 {
+			s := su.ScoreStartsWith(terms, query)
+			if s < wordMinScore {
+				return 0
+			}
+			return s
+		}
+	case QueryModeWordMatch:
+		score = func(terms []string, _ []uint16) uint8 {
+			s := su.ScoreWordMatch(terms, query)
+			if s < wordMinScore {
+				return 0
+			}
+			return s
+		}
+	default:
+		return nil, false
+	}
+	return su.RunWorkers(
+		len(idx.Terms),
+		workerCount,
+		timeout,
+		func(start int, end int) []*common.SearchResultLow {
+			var results []*common.SearchResultLow
+			buff := make([]uint16, 500)
+			for entryIndex := start; entryIndex < end; entryIndex++ {
+				if ctxDone(ctx, entryIndex-start) {
+					break
+				}
+				entryScore := score(idx.Terms[entryIndex], buff)
+				if entryScore == 0 {
+					continue
+				}
+				res := dic.EntryByIndex(entryIndex)
+				if res == nil {
+					continue
+				}
+				res.F_Score = entryScore
+				results = append(results, res)
+			}
+			return results
+		},
+	), true
+}
@@ -24,6 +24,10 @@ func NewDictFlagsCheckboxes(hide func()) *DictFlagsCheckboxes {
 	w.addCheckBox("Regex", dicts.FlagNoRegex)
 	w.addCheckBox("Glob", dicts.FlagNoGlob)
 	w.addCheckBox("Word Match", dicts.FlagNoWordMatch)
+	w.addCheckBox("Definition", dicts.FlagNoDefinition)
+	w.addCheckBox("Exact", dicts.FlagNoExact)
+	w.addCheckBox("Ends with", dicts.FlagNoEndsWith)
+	w.addCheckBox("Contains", dicts.FlagNoContains)
 
 	hbox.AddSpacing(30) // TODO: parameterize
 	hideButton := widgets.NewQPushButton2("Hide", nil)
@@ -53,6 +57,11 @@ func (w *DictFlagsCheckboxes) SetActiveDictSetting(ds *dicts.DictionarySettings)
 	w.checkList[1].SetChecked(ds.StartWith())
 	w.checkList[2].SetChecked(ds.Regex())
 	w.checkList[3].SetChecked(ds.Glob())
+	w.checkList[4].SetChecked(ds.WordMatch())
+	w.checkList[5].SetChecked(ds.Definition())
+	w.checkList[6].SetChecked(ds.Exact())
+	w.checkList[7].SetChecked(ds.EndsWith())
+	w.checkList[8].SetChecked(ds.Contains())
 }
 
 func (w *DictFlagsCheckboxes) addCheckBox(label string, flag uint16) {
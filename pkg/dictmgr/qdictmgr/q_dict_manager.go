@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/ilius/ayandict/v2/pkg/config"
 	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dicts"
@@ -19,18 +20,21 @@ import (
 const (
 	QS_dictManager = "dict_manager"
 
-	dm_col_enable   = 0
-	dm_col_header   = 1
-	dm_col_symbol   = 2
-	dm_col_entries  = 3
-	dm_col_dictName = 4
+	dm_col_enable     = 0
+	dm_col_header     = 1
+	dm_col_symbol     = 2
+	dm_col_weight     = 3
+	dm_col_sourceLang = 4
+	dm_col_targetLang = 5
+	dm_col_entries    = 6
+	dm_col_dictName   = 7
 
 	dictManager_up       = "Up"
 	dictManager_down     = "Down"
 	dictManager_openInfo = "Open Info File"
 	dictManager_openDirs = "Open Directories"
 
-	columns = 5
+	columns = 8
 )
 
 type DictManager struct {
@@ -41,6 +45,10 @@ type DictManager struct {
 
 	infoMap map[string]common.Dictionary
 
+	// edited has copies of settings that are modified in dialog,
+	// which are set by Run if OK is clicked
+	edited map[string]*dicts.DictionarySettings
+
 	app *widgets.QApplication
 
 	toolbar   *widgets.QToolBar
@@ -67,7 +75,7 @@ func NewDictManager(
 	window.SetWindowTitle("Dictionaries")
 	window.Resize2(900, 800)
 
-	infoMap := makeDictInfoMap(dicts.DictList)
+	infoMap := makeDictInfoMap(dicts.List())
 
 	qs := qsettings.GetQSettings(window)
 	qsettings.RestoreWinGeometry(app, qs, &window.QWidget, QS_dictManager)
@@ -98,6 +106,7 @@ func NewDictManager(
 			cancelButton,
 		},
 		infoMap:   infoMap,
+		edited:    map[string]*dicts.DictionarySettings{},
 		app:       app,
 		toolbar:   toolbar,
 		buttonBox: buttonBox,
@@ -146,6 +155,27 @@ func (dm *DictManager) setItem(
 		core.Qt__ItemIsEditable)
 	table.SetItem(index, dm_col_symbol, symbolItem)
 
+	weightItem := dm.newItem(strconv.FormatFloat(ds.EffectiveWeight(), 'f', -1, 64))
+	weightItem.SetFlags(core.Qt__ItemIsEnabled |
+		core.Qt__ItemIsSelectable |
+		core.Qt__ItemIsEditable)
+	weightItem.SetToolTip("Weight of dictionary in weighted scoring model")
+	table.SetItem(index, dm_col_weight, weightItem)
+
+	sourceLangItem := dm.newItem(ds.SourceLang)
+	sourceLangItem.SetFlags(core.Qt__ItemIsEnabled |
+		core.Qt__ItemIsSelectable |
+		core.Qt__ItemIsEditable)
+	sourceLangItem.SetToolTip("Language code of headwords, like en or fa")
+	table.SetItem(index, dm_col_sourceLang, sourceLangItem)
+
+	targetLangItem := dm.newItem(ds.TargetLang)
+	targetLangItem.SetFlags(core.Qt__ItemIsEnabled |
+		core.Qt__ItemIsSelectable |
+		core.Qt__ItemIsEditable)
+	targetLangItem.SetToolTip("Language code of definitions, like en or fa")
+	table.SetItem(index, dm_col_targetLang, targetLangItem)
+
 	entries, err := info.EntryCount()
 	if err != nil {
 		slog.Error("error from info.EntryCount: " + err.Error())
@@ -158,6 +188,36 @@ func (dm *DictManager) setItem(
 	table.SetItem(index, dm_col_dictName, dm.newItem(dictName))
 }
 
+// newOverrideSpinBox creates a spin box for a per-dictionary override
+// of a config value, where zero means using config
+func newOverrideSpinBox(maximum int, suffix string) *widgets.QSpinBox {
+	input := widgets.NewQSpinBox(nil)
+	input.SetMinimum(0)
+	input.SetMaximum(maximum)
+	input.SetSpecialValueText("Default")
+	if suffix != "" {
+		input.SetSuffix(suffix)
+	}
+	return input
+}
+
+// editSettings returns a copy of settings of dictionary to be modified
+// in dialog, since lookups may be reading the current settings.
+// returns nil if dictionary has no settings
+func (dm *DictManager) editSettings(dictName string) *dicts.DictionarySettings {
+	ds := dm.edited[dictName]
+	if ds != nil {
+		return ds
+	}
+	current := dicts.Settings(dictName)
+	if current == nil {
+		return nil
+	}
+	ds = current.Clone()
+	dm.edited[dictName] = ds
+	return ds
+}
+
 // table.SelectedIndexes() panics/crashes
 // so do methods in table.SelectionModel()
 // you have to use table.CurrentRow(), table.CurrentIndex()
@@ -199,7 +259,7 @@ func (dm *DictManager) openInfoFile() {
 		return
 	}
 	dictName := table.Item(row, dm_col_dictName).Text()
-	dic := dicts.DictByName[dictName]
+	dic := dicts.ByName(dictName)
 	if dic == nil {
 		slog.Error("no dictionary was found with this name: " + dictName)
 		return
@@ -242,6 +302,9 @@ func (dm *DictManager) prepareWidgets(conf *config.Config) {
 	header.ResizeSection(dm_col_enable, 10)
 	header.ResizeSection(dm_col_header, 10)
 	header.ResizeSection(dm_col_symbol, 20)
+	header.ResizeSection(dm_col_weight, 50)
+	header.ResizeSection(dm_col_sourceLang, 50)
+	header.ResizeSection(dm_col_targetLang, 50)
 	header.ResizeSection(dm_col_entries, 80)
 	header.ResizeSection(dm_col_dictName, 500)
 
@@ -257,6 +320,18 @@ func (dm *DictManager) prepareWidgets(conf *config.Config) {
 		dm_col_symbol,
 		widgets.NewQTableWidgetItem2("Sym", 0),
 	)
+	table.SetHorizontalHeaderItem(
+		dm_col_weight,
+		widgets.NewQTableWidgetItem2("Weight", 0),
+	)
+	table.SetHorizontalHeaderItem(
+		dm_col_sourceLang,
+		widgets.NewQTableWidgetItem2("Source", 0),
+	)
+	table.SetHorizontalHeaderItem(
+		dm_col_targetLang,
+		widgets.NewQTableWidgetItem2("Target", 0),
+	)
 	table.SetHorizontalHeaderItem(
 		dm_col_entries,
 		widgets.NewQTableWidgetItem2("Entries", 0),
@@ -291,6 +366,38 @@ func (dm *DictManager) prepareWidgets(conf *config.Config) {
 		selectedDictSettings.AudioVolume = value
 	})
 
+	maxResultsInput := newOverrideSpinBox(9999, "")
+	timeoutInput := newOverrideSpinBox(600000, " ms")
+	timeoutInput.SetSingleStep(500)
+	workerCountInput := newOverrideSpinBox(64, "")
+	searchHBox := widgets.NewQHBoxLayout2(nil)
+	searchHBox.AddWidget(widgets.NewQLabel2("Max Results:", nil, 0), 0, 0)
+	searchHBox.AddWidget(maxResultsInput, 0, 0)
+	searchHBox.AddWidget(widgets.NewQLabel2("Search Timeout:", nil, 0), 0, 0)
+	searchHBox.AddWidget(timeoutInput, 0, 0)
+	searchHBox.AddWidget(widgets.NewQLabel2("Search Workers:", nil, 0), 0, 0)
+	searchHBox.AddWidget(workerCountInput, 0, 0)
+	searchHBox.AddWidget(widgets.NewQLabel2("", nil, 0), 1, 0)
+	extraOptionsVBox.AddLayout(searchHBox, 0)
+	maxResultsInput.ConnectValueChanged(func(value int) {
+		if selectedDictSettings == nil {
+			return
+		}
+		selectedDictSettings.MaxResults = value
+	})
+	timeoutInput.ConnectValueChanged(func(value int) {
+		if selectedDictSettings == nil {
+			return
+		}
+		selectedDictSettings.SearchTimeoutMs = value
+	})
+	workerCountInput.ConnectValueChanged(func(value int) {
+		if selectedDictSettings == nil {
+			return
+		}
+		selectedDictSettings.SearchWorkerCount = value
+	})
+
 	mainVBox := widgets.NewQVBoxLayout2(nil)
 	mainVBox.AddWidget(table, 3, 0)
 	mainVBox.AddWidget(extraOptionsWidget, 1, 0)
@@ -344,12 +451,12 @@ func (dm *DictManager) prepareWidgets(conf *config.Config) {
 	})
 
 	table.ConnectCellClicked(func(row int, column int) {
-		if column < 3 {
+		if column < dm_col_entries {
 			extraOptionsWidget.Hide()
 			return
 		}
 		dictName := table.Item(row, dm_col_dictName).Text()
-		ds := dicts.DictSettingsMap[dictName]
+		ds := dm.editSettings(dictName)
 		if ds == nil {
 			extraOptionsWidget.Hide()
 			return
@@ -357,6 +464,9 @@ func (dm *DictManager) prepareWidgets(conf *config.Config) {
 		selectedDictSettings = ds
 		flagsCBWidget.SetActiveDictSetting(ds)
 		volumeInput.SetValue(ds.AudioVolume)
+		maxResultsInput.SetValue(ds.MaxResults)
+		timeoutInput.SetValue(ds.SearchTimeoutMs)
+		workerCountInput.SetValue(ds.SearchWorkerCount)
 		extraOptionsWidget.Show()
 	})
 
@@ -364,15 +474,16 @@ func (dm *DictManager) prepareWidgets(conf *config.Config) {
 	mainBox.AddLayout(mainHBox, 1)
 	mainBox.AddWidget(dm.buttonBox, 0, 0)
 
-	table.SetRowCount(len(dicts.DictList))
-	for index, dic := range dicts.DictList {
+	dictList := dicts.List()
+	table.SetRowCount(len(dictList))
+	for index, dic := range dictList {
 		dictName := dic.DictName()
-		ds := dicts.DictSettingsMap[dictName]
+		ds := dicts.Settings(dictName)
 		if ds == nil {
 			slog.Info("dict manager: found new dict", "dictName", dictName)
 			ds = dicts.NewDictSettings(dic, index)
 			ds.Hash = dicts.Hash(dic)
-			dicts.DictSettingsMap[dictName] = ds
+			dicts.SetSettings(dictName, ds)
 		}
 		dm.setItem(index, dictName, ds)
 	}
@@ -390,7 +501,7 @@ func (dm *DictManager) prepareWidgets(conf *config.Config) {
 	qsettings.SetupWinGeometrySave(qs, &dm.Dialog.QWidget, QS_dictManager)
 }
 
-// updates global var dictSettingsMap
+// updates settings of dictionaries
 // and returns dicts order
 func (dm *DictManager) updateMap() map[string]int {
 	table := dm.TableWidget
@@ -400,20 +511,35 @@ func (dm *DictManager) updateMap() map[string]int {
 		disable := table.Item(index, dm_col_enable).CheckState() != core.Qt__Checked
 		hideHeader := table.Item(index, dm_col_header).CheckState() != core.Qt__Checked
 		symbol := table.Item(index, dm_col_symbol).Text()
+		weightStr := table.Item(index, dm_col_weight).Text()
+		sourceLang := strings.TrimSpace(table.Item(index, dm_col_sourceLang).Text())
+		targetLang := strings.TrimSpace(table.Item(index, dm_col_targetLang).Text())
 		dictName := table.Item(index, dm_col_dictName).Text()
 		value := index + 1
 		if disable {
 			value = -value
 		}
 		order[dictName] = value
-		ds := dicts.DictSettingsMap[dictName]
+		ds := dm.editSettings(dictName)
 		if ds == nil {
 			ds = &dicts.DictionarySettings{}
-			dicts.DictSettingsMap[dictName] = ds
+			dm.edited[dictName] = ds
 		}
 		ds.HideTermsHeader = hideHeader
 		ds.Symbol = symbol
 		ds.Order = value
+		ds.SourceLang = sourceLang
+		ds.TargetLang = targetLang
+		weight, err := strconv.ParseFloat(weightStr, 64)
+		if err != nil || weight <= 0 {
+			slog.Error("invalid weight", "weight", weightStr, "dictName", dictName)
+			continue
+		}
+		if weight == 1 {
+			// default, keep it out of settings file
+			weight = 0
+		}
+		ds.Weight = weight
 	}
 	return order
 }
@@ -422,16 +548,20 @@ func (dm *DictManager) updateMap() map[string]int {
 // if OK was clicked, then applies and saves changes
 // and returs true
 func (dm *DictManager) Run() bool {
+	dm.edited = map[string]*dicts.DictionarySettings{}
 	if dm.Dialog.Exec() != int(widgets.QDialog__Accepted) {
 		return false
 	}
-	dicts.DictsOrder = dm.updateMap()
+	order := dm.updateMap()
+	for dictName, ds := range dm.edited {
+		dicts.SetSettings(dictName, ds)
+	}
 
-	dicts.Reorder(dicts.DictsOrder)
+	dicts.Reorder(order)
 
-	for _, dic := range dicts.DictList {
+	for _, dic := range dicts.List() {
 		disabled := dic.Disabled()
-		dic.SetDisabled(dicts.DictsOrder[dic.DictName()] < 0)
+		dic.SetDisabled(order[dic.DictName()] < 0)
 		if disabled && !dic.Disabled() {
 			err := dic.Load()
 			if err != nil {
@@ -440,7 +570,7 @@ func (dm *DictManager) Run() bool {
 		}
 	}
 
-	err := dicts.SaveDictsSettings(dicts.DictSettingsMap)
+	err := dicts.SaveDictsSettings(dicts.SettingsMap())
 	if err != nil {
 		slog.Error("error in saving dicts settings: " + err.Error())
 	}
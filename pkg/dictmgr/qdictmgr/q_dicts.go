@@ -2,7 +2,7 @@ package qdictmgr
 
 import (
 	"github.com/ilius/ayandict/v2/pkg/config"
-	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dicts"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr"
 	"github.com/ilius/qt/core"
 	"github.com/ilius/qt/widgets"
 )
@@ -29,5 +29,5 @@ func InitDicts(conf *config.Config, popup bool) {
 		popupLabel := loadingDictsPopup(conf)
 		defer popupLabel.Destroy(true, true)
 	}
-	dicts.InitDicts(conf)
+	dictmgr.InitDicts(conf)
 }
@@ -0,0 +1,212 @@
+package dictmgr
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/script"
+	common "github.com/ilius/go-dict-commons"
+)
+
+// QueryPlan is a parsed query, see ParseQuery
+type QueryPlan struct {
+	// Term is the query without filters
+	Term string
+
+	Mode QueryMode
+
+	// IncludeDicts and ExcludeDicts are parts of dictionary names
+	// (case-insensitive). If IncludeDicts is not empty, only dictionaries
+	// that match one of them are searched
+	IncludeDicts []string
+	ExcludeDicts []string
+
+	// Lang is the language of term (like "en" or "fa"), if set, only
+	// dictionaries with this source language are searched (or with
+	// headwords in the script of this language, if source language
+	// of dictionary is not known)
+	Lang string
+
+	// DetectLang enables detecting script of term, to skip dictionaries
+	// whose source language is written in another script
+	DetectLang bool
+
+	// CollapseDuplicates enables collapsing results with the same headword
+	// and definition from different dictionaries into one result
+	CollapseDuplicates bool
+}
+
+// PlainQuery returns a QueryPlan that searches query as is
+func PlainQuery(query string, mode QueryMode) *QueryPlan {
+	return &QueryPlan{
+		Term: query,
+		Mode: mode,
+	}
+}
+
+var queryModeByName = map[string]QueryMode{
+	"fuzzy":      QueryModeFuzzy,
+	"startwith":  QueryModeStartWith,
+	"prefix":     QueryModeStartWith,
+	"regex":      QueryModeRegex,
+	"glob":       QueryModeGlob,
+	"wordmatch":  QueryModeWordMatch,
+	"definition": QueryModeDefinition,
+	"exact":      QueryModeExact,
+	"endswith":   QueryModeEndsWith,
+	"suffix":     QueryModeEndsWith,
+	"contains":   QueryModeContains,
+}
+
+// ParseQueryMode returns query mode by name (case-insensitive),
+// for example "fuzzy", "startWith" or "regex"
+func ParseQueryMode(name string) (QueryMode, bool) {
+	mode, ok := queryModeByName[strings.ToLower(name)]
+	return mode, ok
+}
+
+// nextToken returns the first whitespace-separated token of s and the rest
+// of s after it. If token has a quoted value like dict:"two words",
+// the quoted part can contain whitespace, and quotes are removed
+func nextToken(s string) (string, string) {
+	s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	colon := strings.IndexByte(s, ':')
+	if colon > 0 && colon+1 < len(s) && s[colon+1] == '"' {
+		end := strings.IndexByte(s[colon+2:], '"')
+		if end >= 0 {
+			end += colon + 2
+			return s[:colon+1] + s[colon+2:end], s[end+1:]
+		}
+	}
+	end := strings.IndexFunc(s, unicode.IsSpace)
+	if end < 0 {
+		return s, ""
+	}
+	return s[:end], s[end:]
+}
+
+// applyFilter applies one key:value token to plan,
+// and returns false if token is not a valid filter
+func (plan *QueryPlan) applyFilter(token string) bool {
+	key, value, ok := strings.Cut(token, ":")
+	if !ok || value == "" {
+		return false
+	}
+	switch strings.ToLower(key) {
+	case "dict":
+		plan.IncludeDicts = append(plan.IncludeDicts, strings.ToLower(value))
+		return true
+	case "-dict":
+		plan.ExcludeDicts = append(plan.ExcludeDicts, strings.ToLower(value))
+		return true
+	case "mode":
+		mode, ok := ParseQueryMode(value)
+		if !ok {
+			return false
+		}
+		plan.Mode = mode
+		return true
+	case "lang":
+		switch strings.ToLower(value) {
+		case "auto":
+			plan.DetectLang = true
+			return true
+		case "any":
+			plan.Lang = ""
+			plan.DetectLang = false
+			return true
+		}
+		if _, ok := script.OfLang(value); !ok {
+			return false
+		}
+		plan.Lang = strings.ToLower(value)
+		return true
+	}
+	return false
+}
+
+// ParseQuery parses filters at the beginning of query, like
+//
+//	dict:oxford set
+//	-dict:wiki term
+//	mode:regex ^un.*able$
+//	lang:fa کتاب
+//
+// and returns the query plan. mode is used unless query has a mode: filter,
+// and detectLang is used unless query has lang:auto or lang:any.
+// Parsing stops at the first token that is not a valid filter, and
+// the rest is the term. If there is no term left, the whole query is
+// searched as a plain term
+func ParseQuery(query string, mode QueryMode, detectLang bool) *QueryPlan {
+	plan := PlainQuery(query, mode)
+	plan.DetectLang = detectLang
+	rest := query
+	for {
+		token, after := nextToken(rest)
+		if token == "" || !plan.applyFilter(token) {
+			break
+		}
+		rest = after
+	}
+	term := strings.TrimSpace(rest)
+	if term == "" {
+		plan = PlainQuery(query, mode)
+		plan.DetectLang = detectLang
+		return plan
+	}
+	plan.Term = term
+	return plan
+}
+
+// termScript returns script of term if plan.DetectLang is set, and
+// the term is a headword (not in Definition mode), or Unknown otherwise
+func (plan *QueryPlan) termScript() script.Script {
+	if plan.Lang != "" || !plan.DetectLang || plan.Mode == QueryModeDefinition {
+		return script.Unknown
+	}
+	return script.Detect(plan.Term)
+}
+
+// HasFilters returns true if plan limits the dictionaries to search
+func (plan *QueryPlan) HasFilters() bool {
+	return len(plan.IncludeDicts) > 0 ||
+		len(plan.ExcludeDicts) > 0 ||
+		plan.Lang != "" ||
+		plan.DetectLang
+}
+
+func matchDictName(dictName string, patterns []string) bool {
+	dictName = strings.ToLower(dictName)
+	for _, pattern := range patterns {
+		if strings.Contains(dictName, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterDicts returns dictionaries of dictList that plan allows
+func (plan *QueryPlan) filterDicts(dictList []common.Dictionary) []common.Dictionary {
+	if !plan.HasFilters() {
+		return dictList
+	}
+	termScript := plan.termScript()
+	list := make([]common.Dictionary, 0, len(dictList))
+	for _, dic := range dictList {
+		dictName := dic.DictName()
+		if len(plan.IncludeDicts) > 0 && !matchDictName(dictName, plan.IncludeDicts) {
+			continue
+		}
+		if matchDictName(dictName, plan.ExcludeDicts) {
+			continue
+		}
+		if plan.Lang != "" && !dictMatchesLang(dic, plan.Lang) {
+			continue
+		}
+		if termScript != script.Unknown && !dictMatchesScript(dic, termScript) {
+			continue
+		}
+		list = append(list, dic)
+	}
+	return list
+}
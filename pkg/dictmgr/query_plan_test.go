@@ -0,0 +1,86 @@
+package dictmgr
+
+import (
+	"testing"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/dictmgrtest"
+	common "github.com/ilius/go-dict-commons"
+	"github.com/ilius/is/v2"
+)
+
+func TestParseQuery(t *testing.T) {
+	is := is.New(t)
+	{
+		plan := ParseQuery("dict:oxford set", QueryModeFuzzy, false)
+		is.Equal(plan.Term, "set")
+		is.Equal(plan.Mode, QueryModeFuzzy)
+		is.Equal(plan.IncludeDicts, []string{"oxford"})
+		is.Equal(len(plan.ExcludeDicts), 0)
+	}
+	{
+		plan := ParseQuery("mode:regex ^un.*able$", QueryModeFuzzy, false)
+		is.Equal(plan.Term, "^un.*able$")
+		is.Equal(plan.Mode, QueryModeRegex)
+		is.False(plan.HasFilters())
+	}
+	{
+		plan := ParseQuery("lang:fa کتاب", QueryModeStartWith, false)
+		is.Equal(plan.Term, "کتاب")
+		is.Equal(plan.Mode, QueryModeStartWith)
+		is.Equal(plan.Lang, "fa")
+	}
+	{
+		plan := ParseQuery(`-dict:wiki dict:"Oxford Advanced" mode:wordMatch gold leaf`, QueryModeFuzzy, false)
+		is.Equal(plan.Term, "gold leaf")
+		is.Equal(plan.Mode, QueryModeWordMatch)
+		is.Equal(plan.IncludeDicts, []string{"oxford advanced"})
+		is.Equal(plan.ExcludeDicts, []string{"wiki"})
+	}
+	{
+		// unknown prefix or invalid value: plain term
+		plan := ParseQuery("foo:bar baz", QueryModeFuzzy, false)
+		is.Equal(plan.Term, "foo:bar baz")
+		plan = ParseQuery("mode:xyz term", QueryModeFuzzy, false)
+		is.Equal(plan.Term, "mode:xyz term")
+		is.Equal(plan.Mode, QueryModeFuzzy)
+	}
+	{
+		// filters only, no term
+		plan := ParseQuery("dict:oxford", QueryModeFuzzy, false)
+		is.Equal(plan.Term, "dict:oxford")
+		is.False(plan.HasFilters())
+	}
+	{
+		plan := ParseQuery("lang:any کتاب", QueryModeFuzzy, true)
+		is.Equal(plan.Term, "کتاب")
+		is.False(plan.DetectLang)
+		plan = ParseQuery("lang:auto book", QueryModeFuzzy, false)
+		is.Equal(plan.Term, "book")
+		is.True(plan.DetectLang)
+	}
+}
+
+func TestQueryDirection(t *testing.T) {
+	is := is.New(t)
+	enFa := dictmgrtest.New("en-fa", dictmgrtest.E("book", "کتاب"))
+	enDe := dictmgrtest.New("en-de", dictmgrtest.E("book", "Buch"))
+	noLang := dictmgrtest.New("other", dictmgrtest.E("book", "livre"))
+	dictmgrtest.Install(t, enFa, enDe, noLang)
+	dictmgrtest.Settings("en-fa").SourceLang = "en"
+	dictmgrtest.Settings("en-fa").TargetLang = "fa"
+	dictmgrtest.Settings("en-de").SourceLang = "en-US"
+	dictmgrtest.Settings("en-de").TargetLang = "de"
+
+	direction := func(query string, detectLang bool, dictList ...common.Dictionary) string {
+		return queryDirection(ParseQuery(query, QueryModeFuzzy, detectLang), dictList)
+	}
+	is.Equal(direction("book", true, enFa, enDe), "en → de, fa")
+	is.Equal(direction("book", true, enFa), "en → fa")
+	is.Equal(direction("lang:en-GB book", false, enFa, noLang), "en → fa")
+	// dictionaries with unknown languages are ignored
+	is.Equal(direction("book", true, enFa, noLang), "en → fa")
+	// script of query is never shown as source language
+	is.Equal(direction("book", true, noLang), "")
+	is.Equal(direction("book", false, enFa), "")
+	is.Equal(direction("lang:en book", false, noLang), "")
+}
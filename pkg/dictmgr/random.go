@@ -21,10 +21,16 @@ func entryCount(dic common.Dictionary) int {
 	return n
 }
 
-func RandomEntry(conf *config.Config, resultFlags uint32) *SearchResult {
-	dn := len(dicts.DictList)
+// RandomEntry returns a random entry from dictionaries of group
+// (or all dictionaries if group is empty)
+func RandomEntry(conf *config.Config, group string, resultFlags uint32) *SearchResult {
+	dictList := dicts.List()
+	if group != "" {
+		dictList = groupDicts(group)
+	}
+	dn := len(dictList)
 	sums := make([]int, dn+1)
-	for i, dic := range dicts.DictList {
+	for i, dic := range dictList {
 		sums[i+1] = sums[i] + entryCount(dic)
 	}
 	totalEntryN := sums[dn]
@@ -35,7 +41,7 @@ func RandomEntry(conf *config.Config, resultFlags uint32) *SearchResult {
 	dicIndex := sort.Search(dn, func(i int) bool {
 		return totalEntryI < sums[i+1]
 	})
-	dic := dicts.DictList[dicIndex]
+	dic := dictList[dicIndex]
 	relEntryI := totalEntryI - sums[dicIndex]
 	slog.Debug("RandomEntry", "index", relEntryI, "dictName", dic.DictName())
 	entry := dic.EntryByIndex(relEntryI)
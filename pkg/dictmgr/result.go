@@ -3,8 +3,10 @@ package dictmgr
 import (
 	"fmt"
 	std_html "html"
+	"slices"
 
 	"github.com/ilius/ayandict/v2/pkg/config"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/xdxf"
 	common "github.com/ilius/go-dict-commons"
 )
 
@@ -25,6 +27,55 @@ type SearchResult struct {
 	*common.SearchResultLow
 	proc   *DictProcessor
 	hDefis []string
+	via    string
+	alsoIn []string
+}
+
+// cloneResults returns copies of results, so that setting fields
+// like via and alsoIn of one copy does not change the other
+func cloneResults(results []common.SearchResultIface) []common.SearchResultIface {
+	clones := make([]common.SearchResultIface, len(results))
+	for i, res := range results {
+		sr, ok := res.(*SearchResult)
+		if !ok {
+			clones[i] = res
+			continue
+		}
+		clone := *sr
+		clone.alsoIn = slices.Clone(sr.alsoIn)
+		clones[i] = &clone
+	}
+	return clones
+}
+
+// Via returns how this result was found, if not by the query itself
+// for example "lemma: run", or empty string
+func (r *SearchResult) Via() string {
+	return r.via
+}
+
+// ResultVia returns Via of result if it has one, or empty string
+func ResultVia(res common.SearchResultIface) string {
+	viaRes, ok := res.(interface{ Via() string })
+	if !ok {
+		return ""
+	}
+	return viaRes.Via()
+}
+
+// AlsoIn returns names of other dictionaries that have the same
+// definition for this headword (see conf.CollapseDuplicates)
+func (r *SearchResult) AlsoIn() []string {
+	return r.alsoIn
+}
+
+// ResultAlsoIn returns AlsoIn of result if it has one, or nil
+func ResultAlsoIn(res common.SearchResultIface) []string {
+	alsoInRes, ok := res.(interface{ AlsoIn() []string })
+	if !ok {
+		return nil
+	}
+	return alsoInRes.AlsoIn()
 }
 
 func (r *SearchResult) DictName() string {
@@ -41,16 +92,21 @@ func (r *SearchResult) DefinitionsHTML() []string {
 	}
 	definitions := []string{}
 	for _, item := range r.Items() {
-		if item.Type == 'h' {
-			itemDefi := string(item.Data)
-			itemDefi = r.proc.FixDefiHTML(itemDefi)
-			definitions = append(definitions, itemDefi+"<br/>\n")
+		var itemDefi string
+		switch item.Type {
+		case 'h':
+			itemDefi = string(item.Data)
+		case 'x':
+			itemDefi = xdxf.ToHTML(string(item.Data))
+		default:
+			definitions = append(definitions, fmt.Sprintf(
+				"<pre>%s</pre>\n<br/>\n",
+				std_html.EscapeString(string(item.Data)),
+			))
 			continue
 		}
-		definitions = append(definitions, fmt.Sprintf(
-			"<pre>%s</pre>\n<br/>\n",
-			std_html.EscapeString(string(item.Data)),
-		))
+		itemDefi = r.proc.FixDefiHTML(itemDefi)
+		definitions = append(definitions, itemDefi+"<br/>\n")
 	}
 	r.hDefis = definitions
 	return definitions
@@ -0,0 +1,135 @@
+package dictmgr
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ilius/ayandict/v2/pkg/config"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dicts"
+	common "github.com/ilius/go-dict-commons"
+)
+
+const (
+	ScoringLegacy   = "legacy"
+	ScoringWeighted = "weighted"
+)
+
+// ScoreFunc returns ranking score of a result for query,
+// results with higher ranking score come first
+type ScoreFunc func(res common.SearchResultIface, query string) float64
+
+var scoreFuncs = map[string]ScoreFunc{
+	ScoringLegacy:   legacyScore,
+	ScoringWeighted: weightedScore,
+}
+
+// RegisterScoringModel adds a scoring model that can be selected with
+// scoring_model config. Must be called before any lookup
+func RegisterScoringModel(name string, scoreFunc ScoreFunc) {
+	scoreFuncs[name] = scoreFunc
+}
+
+func getScoreFunc(conf *config.Config) ScoreFunc {
+	scoreFunc := scoreFuncs[conf.ScoringModel]
+	if scoreFunc == nil {
+		return legacyScore
+	}
+	return scoreFunc
+}
+
+// number of times each query is looked up (by user), used in weighted
+// scoring. Keys are lowercase
+var (
+	frequencyMutex  sync.RWMutex
+	lookupFrequency = map[string]int{}
+)
+
+// SetLookupFrequency replaces lookup frequency of queries
+// (for example after loading Most Frequent queries)
+func SetLookupFrequency(counts map[string]int) {
+	frequencyMutex.Lock()
+	defer frequencyMutex.Unlock()
+	lookupFrequency = make(map[string]int, len(counts))
+	for term, count := range counts {
+		lookupFrequency[strings.ToLower(term)] += count
+	}
+	// cached results were sorted using previous frequencies
+	ClearQueryCache()
+}
+
+// AddLookupFrequency increases lookup frequency of query
+func AddLookupFrequency(query string, plus int) {
+	frequencyMutex.Lock()
+	defer frequencyMutex.Unlock()
+	lookupFrequency[strings.ToLower(query)] += plus
+}
+
+func getLookupFrequency(term string) int {
+	frequencyMutex.RLock()
+	defer frequencyMutex.RUnlock()
+	return lookupFrequency[strings.ToLower(term)]
+}
+
+// DictWeight returns ranking weight of dictionary, 1 by default
+func DictWeight(dictName string) float64 {
+	ds := dicts.Settings(dictName)
+	if ds == nil {
+		return 1
+	}
+	return ds.EffectiveWeight()
+}
+
+// legacyScore only uses match score, and dictionary order is only used
+// for sorting results with the same score and headword
+func legacyScore(res common.SearchResultIface, _ string) float64 {
+	return float64(res.Score())
+}
+
+// weightedScore multiplies match score by weight of dictionary, and gives
+// a small bonus to headwords that are closer in length to query, and
+// to headwords that user has looked up more often
+func weightedScore(res common.SearchResultIface, query string) float64 {
+	score := float64(res.Score()) * DictWeight(res.DictName())
+	terms := res.Terms()
+	if len(terms) == 0 {
+		return score
+	}
+	term := terms[0]
+	lengthDiff := len([]rune(term)) - len([]rune(query))
+	if lengthDiff < 0 {
+		lengthDiff = -lengthDiff
+	}
+	score -= 0.5 * float64(min(lengthDiff, 20))
+	score += 5 * math.Log2(1+float64(getLookupFrequency(term)))
+	return score
+}
+
+// sortResults sorts results by ranking score of conf.ScoringModel,
+// and then by headword, dictOrder and entry index
+func sortResults(
+	results []common.SearchResultIface,
+	conf *config.Config,
+	query string,
+	dictOrder map[string]int,
+) {
+	scoreFunc := getScoreFunc(conf)
+	type rankedResult struct {
+		res  common.SearchResultIface
+		rank float64
+	}
+	ranked := make([]rankedResult, len(results))
+	for i, res := range results {
+		ranked[i] = rankedResult{res: res, rank: scoreFunc(res, query)}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].rank != ranked[j].rank {
+			return ranked[i].rank > ranked[j].rank
+		}
+		return lessByHeadwordAndDict(ranked[i].res, ranked[j].res, dictOrder)
+	})
+	for i, item := range ranked {
+		results[i] = item.res
+	}
+}
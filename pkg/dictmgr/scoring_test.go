@@ -0,0 +1,56 @@
+package dictmgr
+
+import (
+	"testing"
+
+	"github.com/ilius/ayandict/v2/pkg/config"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/dicts"
+	common "github.com/ilius/go-dict-commons"
+	"github.com/ilius/is/v2"
+)
+
+func resultNames(results []common.SearchResultIface) []string {
+	names := make([]string, len(results))
+	for i, res := range results {
+		names[i] = res.DictName() + ":" + res.Terms()[0]
+	}
+	return names
+}
+
+func TestSortResultsScoring(t *testing.T) {
+	is := is.New(t)
+	prev := dicts.Current()
+	dicts.Replace(dicts.State{
+		DictSettingsMap: map[string]*dicts.DictionarySettings{
+			"Main": {Weight: 1.5},
+			"Junk": {Weight: 0.5},
+		},
+	})
+	defer dicts.Replace(prev)
+	newResults := func() []common.SearchResultIface {
+		return []common.SearchResultIface{
+			&testResult{terms: []string{"sets"}, dictName: "Main", score: 150},
+			&testResult{terms: []string{"set"}, dictName: "Junk", score: 180},
+		}
+	}
+	conf := config.Default()
+
+	results := newResults()
+	conf.ScoringModel = ScoringLegacy
+	sortResults(results, conf, "set", nil)
+	is.Equal(resultNames(results), []string{"Junk:set", "Main:sets"})
+
+	results = newResults()
+	conf.ScoringModel = ScoringWeighted
+	sortResults(results, conf, "set", nil)
+	is.Equal(resultNames(results), []string{"Main:sets", "Junk:set"})
+}
+
+func TestLookupFrequency(t *testing.T) {
+	is := is.New(t)
+	defer SetLookupFrequency(nil)
+	SetLookupFrequency(map[string]int{"Set": 2, "set": 1})
+	AddLookupFrequency("SET", 1)
+	is.Equal(getLookupFrequency("set"), 4)
+	is.Equal(getLookupFrequency("get"), 0)
+}
@@ -0,0 +1,64 @@
+package dictmgr
+
+import (
+	"context"
+
+	"github.com/ilius/ayandict/v2/pkg/config"
+	common "github.com/ilius/go-dict-commons"
+)
+
+// DictLookupResult is the results of one dictionary, sent by
+// LookupHTMLStream as soon as that dictionary is finished
+type DictLookupResult struct {
+	DictName string
+
+	// Results are sorted, and limited just like in LookupHTML
+	Results []common.SearchResultIface
+}
+
+// StreamEvent is sent by LookupHTMLStream, only one of Dict and Final is set
+type StreamEvent struct {
+	Dict *DictLookupResult
+
+	// Final is sent last, it's the same as the result of LookupHTMLContext,
+	// which has results of all dictionaries sorted together, and includes
+	// lemma and keyboard layout fallbacks and collapsed duplicates
+	Final *LookupResult
+}
+
+// LookupHTMLStream is like LookupHTMLContext, but instead of only returning
+// the final result, it sends results of each dictionary to the returned
+// channel as soon as it is finished (dictionaries with no results are
+// skipped), and then sends the final result.
+// The channel is closed at the end, or when ctx is done.
+// Caller must either read until channel is closed, or cancel ctx.
+func LookupHTMLStream(
+	ctx context.Context,
+	plan *QueryPlan,
+	conf *config.Config,
+	group string,
+	resultFlags uint32,
+	limit int,
+) <-chan *StreamEvent {
+	out := make(chan *StreamEvent)
+	send := func(event *StreamEvent) {
+		select {
+		case out <- event:
+		case <-ctx.Done():
+		}
+	}
+	go func() {
+		defer close(out)
+		lookupResult, err := lookup(
+			ctx, plan, conf, group, resultFlags, limit,
+			func(dlr *DictLookupResult) {
+				send(&StreamEvent{Dict: dlr})
+			},
+		)
+		if err != nil {
+			return
+		}
+		send(&StreamEvent{Final: lookupResult})
+	}()
+	return out
+}
@@ -0,0 +1,103 @@
+package dictmgr
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ilius/ayandict/v2/pkg/config"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/bktree"
+	common "github.com/ilius/go-dict-commons"
+)
+
+// headwords longer than this (in runes) are not suggested
+const maxSuggestionLength = 50
+
+// BK-tree of lowercase headwords of all enabled dictionaries,
+// used for "did you mean" suggestions
+var (
+	suggestMutex  sync.RWMutex
+	suggestTree   *bktree.Tree
+	suggestCancel context.CancelFunc
+)
+
+// startSuggestIndexing builds the BK-tree of headwords in background
+func startSuggestIndexing(conf *config.Config) {
+	suggestMutex.Lock()
+	defer suggestMutex.Unlock()
+	if suggestCancel != nil {
+		suggestCancel()
+		suggestCancel = nil
+	}
+	suggestTree = nil
+	if conf.Suggestions < 1 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	suggestCancel = cancel
+	go buildSuggestTree(ctx, activeDicts())
+}
+
+func buildSuggestTree(ctx context.Context, dictList []common.Dictionary) {
+	t := time.Now()
+	tree := &bktree.Tree{}
+	for _, dic := range dictList {
+		count, err := dic.EntryCount()
+		if err != nil {
+			slog.Error("error in EntryCount", "err", err, "dictName", dic.DictName())
+			continue
+		}
+		for entryIndex := range count {
+			if entryIndex%1000 == 0 && ctx.Err() != nil {
+				return
+			}
+			entry := dic.EntryByIndex(entryIndex)
+			if entry == nil {
+				continue
+			}
+			for _, term := range entry.F_Terms {
+				if len([]rune(term)) > maxSuggestionLength {
+					continue
+				}
+				tree.Add(strings.ToLower(term))
+			}
+		}
+	}
+	suggestMutex.Lock()
+	defer suggestMutex.Unlock()
+	if ctx.Err() != nil {
+		return
+	}
+	suggestTree = tree
+	slog.Info("Built suggestion index", "words", tree.Len(), "dt", time.Since(t))
+}
+
+// Suggest returns up to conf.Suggestions headwords that are closest
+// to query (by edit distance), for "did you mean" links
+// returns nil if suggestion index is not ready yet
+func Suggest(query string, conf *config.Config) []string {
+	suggestMutex.RLock()
+	tree := suggestTree
+	suggestMutex.RUnlock()
+	if tree == nil || conf.Suggestions < 1 {
+		return nil
+	}
+	query = strings.ToLower(strings.TrimSpace(query))
+	// allow less typos in short words
+	maxDistance := min(conf.SuggestionMaxDistance, max(1, len([]rune(query))/3))
+	// +1 for query itself, which may be in tree
+	matches := tree.Search(query, maxDistance, conf.Suggestions+1)
+	suggestions := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if match.Distance == 0 {
+			continue
+		}
+		suggestions = append(suggestions, match.Word)
+	}
+	if len(suggestions) > conf.Suggestions {
+		suggestions = suggestions[:conf.Suggestions]
+	}
+	return suggestions
+}
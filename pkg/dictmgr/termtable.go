@@ -0,0 +1,255 @@
+package dictmgr
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/termtable"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/trigram"
+	common "github.com/ilius/go-dict-commons"
+	su "github.com/ilius/go-dict-commons/search_utils"
+)
+
+// term table of a dictionary is built in background on first Exact,
+// EndsWith or Contains query of it, and kept in memory. until it's ready,
+// entries of dictionary are scanned linearly
+type dictTermTable struct {
+	dic   common.Dictionary
+	table *termtable.Table // nil until built
+}
+
+var (
+	termTableMutex  sync.RWMutex
+	termTableCtx    = context.Background()
+	termTableCancel context.CancelFunc
+	termTableByName = map[string]*dictTermTable{}
+)
+
+// resetTermTables drops term tables, and stops building them
+func resetTermTables() {
+	termTableMutex.Lock()
+	defer termTableMutex.Unlock()
+	if termTableCancel != nil {
+		termTableCancel()
+	}
+	termTableCtx, termTableCancel = context.WithCancel(context.Background())
+	termTableByName = map[string]*dictTermTable{}
+}
+
+func buildTermTable(ctx context.Context, item *dictTermTable) {
+	dictName := item.dic.DictName()
+	t := time.Now()
+	table, err := termtable.Build(ctx, item.dic)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		slog.Error("error building term table", "err", err, "dictName", dictName)
+		return
+	}
+	termTableMutex.Lock()
+	if ctx.Err() != nil || termTableByName[dictName] != item {
+		termTableMutex.Unlock()
+		return
+	}
+	item.table = table
+	termTableMutex.Unlock()
+	slog.Info("Built term table", "dictName", dictName, "dt", time.Since(t))
+}
+
+// getTermTable returns term table of dictionary, or nil if it's not ready.
+// starts building it in background if it's not started yet
+func getTermTable(dic common.Dictionary) *termtable.Table {
+	dictName := dic.DictName()
+	termTableMutex.RLock()
+	item := termTableByName[dictName]
+	// another dictionary may have been loaded with the same name
+	if item != nil && item.dic == dic {
+		table := item.table
+		termTableMutex.RUnlock()
+		return table
+	}
+	termTableMutex.RUnlock()
+	termTableMutex.Lock()
+	defer termTableMutex.Unlock()
+	item = termTableByName[dictName]
+	if item != nil && item.dic == dic {
+		return item.table
+	}
+	item = &dictTermTable{dic: dic}
+	termTableByName[dictName] = item
+	go buildTermTable(termTableCtx, item)
+	return nil
+}
+
+// termMatchScore returns 200 for a headword equal to query, and lower
+// scores for longer matching headwords, and for matching terms
+// that are not the first term of entry
+func termMatchScore(terms []string, query string, match func(string) bool) uint8 {
+	queryLen := utf8.RuneCountInString(query)
+	best := uint8(0)
+	for termIndex, term := range terms {
+		if !match(term) {
+			continue
+		}
+		extra := max(utf8.RuneCountInString(term)-queryLen, 0)
+		score := 200 - uint8(min(extra, 60)) - 2*uint8(min(termIndex, 3))
+		best = max(best, score)
+	}
+	return best
+}
+
+// searchTermTable scores the given entries using lowercase headwords
+// of table, and returns the ones that match
+func searchTermTable(
+	ctx context.Context,
+	dic common.Dictionary,
+	table *termtable.Table,
+	entryIndexes []uint32,
+	query string,
+	workerCount int,
+	timeout time.Duration,
+	match func(term string) bool,
+) []*common.SearchResultLow {
+	return su.RunWorkers(
+		len(entryIndexes),
+		workerCount,
+		timeout,
+		func(start int, end int) []*common.SearchResultLow {
+			var results []*common.SearchResultLow
+			for i, entryIndex := range entryIndexes[start:end] {
+				if ctxDone(ctx, i) {
+					break
+				}
+				score := termMatchScore(table.Terms[entryIndex], query, match)
+				if score == 0 {
+					continue
+				}
+				res := dic.EntryByIndex(int(entryIndex))
+				if res == nil {
+					continue
+				}
+				res.F_Score = score
+				results = append(results, res)
+			}
+			return results
+		},
+	)
+}
+
+// scanTerms scores all entries of dictionary using their lowercase
+// headwords, used while term table is not ready
+func scanTerms(
+	ctx context.Context,
+	dic common.Dictionary,
+	query string,
+	workerCount int,
+	timeout time.Duration,
+	match func(term string) bool,
+) []*common.SearchResultLow {
+	count, err := dic.EntryCount()
+	if err != nil {
+		slog.Error("error in EntryCount", "err", err, "dictName", dic.DictName())
+		return nil
+	}
+	return su.RunWorkers(
+		count,
+		workerCount,
+		timeout,
+		func(start int, end int) []*common.SearchResultLow {
+			var results []*common.SearchResultLow
+			for entryIndex := start; entryIndex < end; entryIndex++ {
+				if ctxDone(ctx, entryIndex-start) {
+					break
+				}
+				res := dic.EntryByIndex(entryIndex)
+				if res == nil {
+					continue
+				}
+				terms := termtable.NormalizeTerms(res.Terms())
+				score := termMatchScore(terms, query, match)
+				if score == 0 {
+					continue
+				}
+				res.F_Score = score
+				results = append(results, res)
+			}
+			return results
+		},
+	)
+}
+
+func searchExact(
+	ctx context.Context,
+	dic common.Dictionary,
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	query = termtable.Normalize(query)
+	match := func(term string) bool {
+		return term == query
+	}
+	table := getTermTable(dic)
+	if table == nil {
+		return scanTerms(ctx, dic, query, workerCount, timeout, match)
+	}
+	return searchTermTable(
+		ctx, dic, table, table.Exact(query), query, workerCount, timeout, match,
+	)
+}
+
+func searchEndsWith(
+	ctx context.Context,
+	dic common.Dictionary,
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	query = termtable.Normalize(query)
+	match := func(term string) bool {
+		return strings.HasSuffix(term, query)
+	}
+	table := getTermTable(dic)
+	if table == nil {
+		return scanTerms(ctx, dic, query, workerCount, timeout, match)
+	}
+	return searchTermTable(
+		ctx, dic, table, table.EndsWith(query), query, workerCount, timeout, match,
+	)
+}
+
+// searchContains uses trigram index of headwords (if ready) to find
+// candidates, and checks headwords of table (if ready)
+func searchContains(
+	ctx context.Context,
+	dic common.Dictionary,
+	query string,
+	workerCount int,
+	timeout time.Duration,
+) []*common.SearchResultLow {
+	query = termtable.Normalize(query)
+	match := func(term string) bool {
+		return strings.Contains(term, query)
+	}
+	table := getTermTable(dic)
+	if table == nil {
+		return scanTerms(ctx, dic, query, workerCount, timeout, match)
+	}
+	var candidates []uint32
+	if idx := getTrigramIndex(dic.DictName()); idx != nil {
+		if grams := trigram.InnerTrigrams(query); len(grams) > 0 {
+			candidates = idx.All(grams)
+			if candidates == nil {
+				candidates = []uint32{}
+			}
+		}
+	}
+	return searchTermTable(
+		ctx, dic, table, table.Contains(query, candidates), query, workerCount, timeout, match,
+	)
+}
@@ -0,0 +1,95 @@
+package dictmgr
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/dictmgrtest"
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/internal/termtable"
+	common "github.com/ilius/go-dict-commons"
+	"github.com/ilius/is/v2"
+)
+
+func termResultKeys(results []*common.SearchResultLow) []string {
+	keys := make([]string, len(results))
+	for i, res := range results {
+		keys[i] = fmt.Sprintf("%s:%d", res.F_Terms[0], res.F_Score)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func TestSearchTermsScanFallback(t *testing.T) {
+	is := is.New(t)
+	dic := dictmgrtest.New(
+		"test",
+		dictmgrtest.E("Apple", ""),
+		dictmgrtest.E(" apple ", ""),
+		dictmgrtest.E("pineapple", ""),
+		&dictmgrtest.Entry{Terms: []string{"fruit", "APPLE", "apple"}},
+		dictmgrtest.E("applesauce", ""),
+		dictmgrtest.E("maple", ""),
+	)
+	ctx := context.Background()
+	type searchFunc func(context.Context, common.Dictionary, string, int, time.Duration) []*common.SearchResultLow
+	searches := map[string]searchFunc{
+		"exact":    searchExact,
+		"endsWith": searchEndsWith,
+		"contains": searchContains,
+	}
+	queries := []string{"apple", "APPLE ", "ple", "sauce", "xyz"}
+
+	// table is being built, so entries are scanned
+	termTableMutex.Lock()
+	termTableByName[dic.DictName()] = &dictTermTable{dic: dic}
+	termTableMutex.Unlock()
+	t.Cleanup(func() {
+		termTableMutex.Lock()
+		delete(termTableByName, dic.DictName())
+		termTableMutex.Unlock()
+	})
+	scanned := map[string][]string{}
+	for name, search := range searches {
+		for _, query := range queries {
+			scanned[name+"/"+query] = termResultKeys(search(ctx, dic, query, 1, 0))
+		}
+	}
+	is.Equal(scanned["exact/apple"], []string{" apple :200", "Apple:200", "fruit:198"})
+
+	table, err := termtable.Build(ctx, dic)
+	is.NotErr(err)
+	termTableMutex.Lock()
+	termTableByName[dic.DictName()].table = table
+	termTableMutex.Unlock()
+	is.True(getTermTable(dic) != nil)
+
+	for name, search := range searches {
+		for _, query := range queries {
+			key := name + "/" + query
+			is.Msg(key).Equal(termResultKeys(search(ctx, dic, query, 1, 0)), scanned[key])
+		}
+	}
+}
+
+func TestGetTermTableLazy(t *testing.T) {
+	is := is.New(t)
+	dic := dictmgrtest.New("lazy", dictmgrtest.E("apple", ""))
+	resetTermTables()
+	t.Cleanup(resetTermTables)
+
+	// first query starts building table in background
+	is.True(getTermTable(dic) == nil)
+	deadline := time.Now().Add(5 * time.Second)
+	for getTermTable(dic) == nil {
+		is.True(time.Now().Before(deadline))
+		time.Sleep(time.Millisecond)
+	}
+	is.Equal(getTermTable(dic).Exact("apple"), []uint32{0})
+
+	// another dictionary with the same name gets its own table
+	other := dictmgrtest.New("lazy", dictmgrtest.E("banana", ""))
+	is.True(getTermTable(other) == nil)
+}
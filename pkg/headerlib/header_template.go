@@ -18,6 +18,10 @@ type HeaderTemplateInput struct {
 	DictName  string
 	Score     uint8
 	ShowTerms bool
+	Via       string
+
+	// AlsoIn are other dictionaries with the same definition
+	AlsoIn []string
 }
 
 func LoadHeaderTemplate(conf *config.Config) (*template.Template, error) {
@@ -26,6 +30,7 @@ func LoadHeaderTemplate(conf *config.Config) (*template.Template, error) {
 		"wrapterms": func(terms []string, limit int) [][]string {
 			return wordwrap.WordWrapByWords(terms, limit, " ", " ")
 		},
+		"join": strings.Join,
 	})
 	tpl, err := tpl.Parse(conf.HeaderTemplate)
 	if err != nil {
@@ -48,6 +53,8 @@ func GetHeader(
 		DictName:  dictName,
 		Score:     res.Score() >> 1,
 		ShowTerms: dictmgr.DictShowTerms(dictName),
+		Via:       dictmgr.ResultVia(res),
+		AlsoIn:    dictmgr.ResultAlsoIn(res),
 	})
 	if err != nil {
 		return "", err
@@ -0,0 +1,140 @@
+package headerlib
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/dictmgrtest"
+	"github.com/ilius/is/v2"
+)
+
+type testResult struct {
+	terms    []string
+	dictName string
+	score    uint8
+	via      string
+	alsoIn   []string
+}
+
+func (r *testResult) Terms() []string           { return r.terms }
+func (r *testResult) Score() uint8              { return r.score }
+func (r *testResult) DictName() string          { return r.dictName }
+func (r *testResult) DefinitionsHTML() []string { return nil }
+func (r *testResult) ResourceDir() string       { return "" }
+func (r *testResult) EntryIndex() uint64        { return 0 }
+func (r *testResult) Via() string               { return r.via }
+func (r *testResult) AlsoIn() []string          { return r.alsoIn }
+
+func TestGetHeader(t *testing.T) {
+	dictmgrtest.Install(t, dictmgrtest.New("d1"), dictmgrtest.New("d2"))
+	dictmgrtest.Settings("d2").HideTermsHeader = true
+	res := &testResult{
+		terms:    []string{"cat", "<cats>"},
+		dictName: "d1",
+		score:    200,
+	}
+	type testCase struct {
+		template string
+		res      *testResult
+		header   string
+	}
+	for _, tc := range []testCase{
+		{
+			template: `{{.DictName}} {{.Score}}`,
+			res:      res,
+			header:   "d1 100",
+		},
+		{
+			template: `{{.Term}}`,
+			res:      &testResult{terms: []string{"cat", "cats"}, dictName: "d1"},
+			header:   "cat | cats",
+		},
+		{
+			template: `{{range .Terms}}[{{.}}]{{end}}`,
+			res:      res,
+			header:   "[cat][&lt;cats&gt;]",
+		},
+		{
+			template: `{{range wrapterms .Terms 5}}{{join . ","}};{{end}}`,
+			res:      res,
+			header:   "cat;&lt;cats&gt;;",
+		},
+		{
+			template: `{{.ShowTerms}}`,
+			res:      res,
+			header:   "true",
+		},
+		{
+			template: `{{.ShowTerms}}`,
+			res:      &testResult{terms: []string{"cat"}, dictName: "d2"},
+			header:   "false",
+		},
+		{
+			template: `{{.ShowTerms}}`,
+			res:      &testResult{terms: []string{"cat"}, dictName: "missing"},
+			header:   "true",
+		},
+		{
+			template: `{{if .Via}}via {{.Via}}{{else}}-{{end}}`,
+			res:      res,
+			header:   "-",
+		},
+		{
+			template: `{{if .Via}}via {{.Via}}{{else}}-{{end}}`,
+			res:      &testResult{terms: []string{"cats"}, dictName: "d1", via: "cat"},
+			header:   "via cat",
+		},
+		{
+			template: `{{if .AlsoIn}}also in: {{join .AlsoIn ", "}}{{else}}-{{end}}`,
+			res:      res,
+			header:   "-",
+		},
+		{
+			template: `{{if .AlsoIn}}also in: {{join .AlsoIn ", "}}{{else}}-{{end}}`,
+			res:      &testResult{terms: []string{"cat"}, dictName: "d1", alsoIn: []string{"d2", "d3"}},
+			header:   "also in: d2, d3",
+		},
+	} {
+		is := is.New(t).Msg("template=%q", tc.template)
+		conf := dictmgrtest.Config()
+		conf.HeaderTemplate = tc.template
+		tpl, err := LoadHeaderTemplate(conf)
+		is.NotErr(err)
+		header, err := GetHeader(tpl, tc.res)
+		is.NotErr(err)
+		is.Equal(header, tc.header)
+	}
+}
+
+func TestGetHeaderDefaultTemplate(t *testing.T) {
+	is := is.New(t)
+	dictmgrtest.Install(t, dictmgrtest.New("d1"))
+	tpl, err := LoadHeaderTemplate(dictmgrtest.Config())
+	is.NotErr(err)
+	header, err := GetHeader(tpl, &testResult{
+		terms:    []string{"cats", "felines"},
+		dictName: "d1",
+		score:    180,
+		via:      "cat",
+		alsoIn:   []string{"d2", "d3"},
+	})
+	is.NotErr(err)
+	for _, part := range []string{
+		"d1",
+		"[Score: %90]",
+		"[via cat]",
+		"[also in: d2, d3]",
+		"cats",
+		"felines",
+	} {
+		is.Msg("part=%q", part).True(strings.Contains(header, part))
+	}
+}
+
+func TestLoadHeaderTemplateError(t *testing.T) {
+	is := is.New(t)
+	conf := dictmgrtest.Config()
+	conf.HeaderTemplate = `{{.DictName`
+	_, err := LoadHeaderTemplate(conf)
+	is.Err(err)
+}
@@ -24,9 +24,11 @@ const (
 
 	QS_sizes = "sizes"
 
-	QS_search   = "search"
-	QS_activity = "activity"
-	QS_mode     = "mode"
+	QS_search    = "search"
+	QS_activity  = "activity"
+	QS_mode      = "mode"
+	QS_grouped   = "grouped"
+	QS_dictGroup = "dictGroup"
 )
 
 func joinIntList(nums []int) string {
@@ -352,6 +354,36 @@ func RestoreSearchSettings(qs *core.QSettings, combo *widgets.QComboBox) {
 	restoreIntSetting(qs, QS_mode, combo.SetCurrentIndex)
 }
 
+func SaveSearchGrouped(qs *core.QSettings, checkBox *widgets.QCheckBox) {
+	qs.BeginGroup(QS_search)
+	defer qs.EndGroup()
+
+	qs.SetValue(QS_grouped, core.NewQVariant9(checkBox.IsChecked()))
+}
+
+func RestoreSearchGrouped(qs *core.QSettings, checkBox *widgets.QCheckBox) {
+	qs.BeginGroup(QS_search)
+	defer qs.EndGroup()
+
+	restoreBoolSetting(qs, QS_grouped, false, checkBox.SetChecked)
+}
+
+func SaveSearchDictGroup(qs *core.QSettings, group string) {
+	qs.BeginGroup(QS_search)
+	defer qs.EndGroup()
+
+	qs.SetValue(QS_dictGroup, core.NewQVariant1(group))
+}
+
+func RestoreSearchDictGroup(qs *core.QSettings, apply func(string)) {
+	qs.BeginGroup(QS_search)
+	defer qs.EndGroup()
+
+	restoreSetting(qs, QS_dictGroup, func(value *core.QVariant) {
+		apply(value.ToString())
+	})
+}
+
 func SaveActivityMode(qs *core.QSettings, combo *widgets.QComboBox) {
 	qs.BeginGroup(QS_activity)
 	defer qs.EndGroup()
@@ -1,13 +1,16 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
 	html_template "html/template"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	text_template "text/template"
 	"time"
 
@@ -25,6 +28,27 @@ const (
 	path_appName    = "app-name"
 	path_api_query  = "api/query"
 	path_api_random = "api/random"
+
+	path_api_query_stream = "api/query/stream"
+
+	// comma-separated list of (url-escaped) names of dictionaries
+	// that did not finish searching before search_total_timeout
+	header_timedOut = "X-Timed-Out-Dicts"
+
+	// (url-escaped) query remapped to another keyboard layout, if
+	// results of that are included (see keyboard_layouts config)
+	header_remappedQuery = "X-Remapped-Query"
+
+	// comma-separated list of (url-escaped) headwords that are close
+	// to query, only if there is no result (see suggestions config)
+	header_suggestions = "X-Suggestions"
+
+	// (url-escaped) detected query direction, like "en → fa"
+	header_direction = "X-Query-Direction"
+
+	// (url-escaped) reason that results may be missing, like
+	// definition index not being ready
+	header_notice = "X-Notice"
 )
 
 var (
@@ -56,9 +80,32 @@ type Result struct {
 	EntryIndex      uint64   `json:"entryIndex"`
 	Score           uint8    `json:"score"`
 	HeaderHTML      string   `json:"header_html"`
+	Via             string   `json:"via,omitempty"`
+	AlsoIn          []string `json:"alsoIn,omitempty"`
 	// ResourceDir string
 }
 
+// ResultGroup is a list of results with the same headword,
+// returned by api/query if grouped=1 is given
+type ResultGroup struct {
+	Term    string   `json:"term"`
+	Score   uint8    `json:"score"`
+	Results []Result `json:"results"`
+}
+
+// QueryResponse is the response of api/query if envelope=1 is given,
+// otherwise only Results (or Groups if grouped=1) is returned
+// as a JSON array, and the rest are sent as X-* headers
+type QueryResponse struct {
+	Results       []Result      `json:"results,omitempty"`
+	Groups        []ResultGroup `json:"groups,omitempty"`
+	Suggestions   []string      `json:"suggestions,omitempty"`
+	RemappedQuery string        `json:"remappedQuery,omitempty"`
+	TimedOut      []string      `json:"timedOut,omitempty"`
+	Direction     string        `json:"direction,omitempty"`
+	Notice        string        `json:"notice,omitempty"`
+}
+
 func writeMsg(w http.ResponseWriter, msg string) {
 	_, err := w.Write([]byte(msg))
 	if err != nil {
@@ -66,50 +113,81 @@ func writeMsg(w http.ResponseWriter, msg string) {
 	}
 }
 
+func joinEscaped(values []string) string {
+	escaped := make([]string, len(values))
+	for i, value := range values {
+		escaped[i] = url.QueryEscape(value)
+	}
+	return strings.Join(escaped, ",")
+}
+
 func getAppName(w http.ResponseWriter, _ *http.Request) {
 	writeMsg(w, appinfo.APP_NAME)
 }
 
 func queryModeParam(r *http.Request) (dictmgr.QueryMode, bool) {
-	switch r.FormValue("mode") {
-	case "", "fuzzy":
+	name := r.FormValue("mode")
+	if name == "" {
 		return dictmgr.QueryModeFuzzy, true
-	case "startWith":
-		return dictmgr.QueryModeStartWith, true
-	case "regex":
-		return dictmgr.QueryModeRegex, true
-	case "glob":
-		return dictmgr.QueryModeGlob, true
-	case "wordMatch":
-		return dictmgr.QueryModeWordMatch, true
-	}
-	return dictmgr.QueryMode(0), false
+	}
+	return dictmgr.ParseQueryMode(name)
 }
 
-func api_query(w http.ResponseWriter, r *http.Request) {
-	t := time.Now()
+type queryParams struct {
+	query    string
+	plan     *dictmgr.QueryPlan
+	group    string
+	flags    uint32
+	limit    int
+	envelope bool
+	grouped  bool
+}
 
-	jsonEncoder := json.NewEncoder(w)
-	w.Header().Set("Content-Type", "application/json")
+// boolParam returns false as second value if parameter is invalid
+func boolParam(r *http.Request, name string) (bool, bool) {
+	str := r.FormValue(name)
+	if str == "" {
+		return false, true
+	}
+	value, err := strconv.ParseBool(str)
+	if err != nil {
+		return false, false
+	}
+	return value, true
+}
+
+func writeBadRequest(w http.ResponseWriter, msg string) {
+	w.WriteHeader(http.StatusBadRequest)
+	err := json.NewEncoder(w).Encode(ErrorResponse{Error: msg})
+	if err != nil {
+		logger.Error("error in jsonEncoder.Encode", "err", err)
+	}
+}
 
+// groupParam returns false as second value if group does not exist
+func groupParam(r *http.Request) (string, bool) {
+	group := r.FormValue("group")
+	return group, dictmgr.HasDictGroup(group)
+}
+
+// parseQueryParams returns an error message if a parameter is missing/invalid
+func parseQueryParams(r *http.Request) (*queryParams, string) {
 	query := r.FormValue("query")
 	if query == "" {
-		err := jsonEncoder.Encode(ErrorResponse{Error: "missing query"})
-		if err != nil {
-			logger.Error("error in jsonEncoder.Encode", "err", err)
-		}
-		w.WriteHeader(http.StatusBadRequest)
-		return
+		return nil, "missing query"
 	}
 
 	mode, ok := queryModeParam(r)
 	if !ok {
-		err := jsonEncoder.Encode(ErrorResponse{Error: "invalid mode"})
-		if err != nil {
-			logger.Error("error in jsonEncoder.Encode", "err", err)
-		}
-		w.WriteHeader(http.StatusBadRequest)
-		return
+		return nil, "invalid mode"
+	}
+	if mode == dictmgr.QueryModeDefinition && !conf.DefinitionIndex {
+		return nil, dictmgr.ErrDefinitionIndexDisabled.Error()
+	}
+
+	group, ok := groupParam(r)
+	if !ok {
+		return nil, "invalid group"
 	}
 
 	flags := resultFlags
@@ -118,12 +196,7 @@ func api_query(w http.ResponseWriter, r *http.Request) {
 	case "5", "6":
 		flags = flags | common.ResultFlag_FixWordLink | common.ResultFlag_ColorMapping
 	default:
-		err := jsonEncoder.Encode(ErrorResponse{Error: "invalid qt version, must be 5 or 6"})
-		if err != nil {
-			logger.Error("error in jsonEncoder.Encode", "err", err)
-		}
-		w.WriteHeader(http.StatusBadRequest)
-		return
+		return nil, "invalid qt version, must be 5 or 6"
 	}
 
 	limit := 0
@@ -131,25 +204,57 @@ func api_query(w http.ResponseWriter, r *http.Request) {
 	if limitStr != "" {
 		limitI64, err := strconv.ParseUint(limitStr, 10, 0)
 		if err != nil {
-			err := jsonEncoder.Encode(ErrorResponse{Error: "invalid limit"})
-			if err != nil {
-				logger.Error("error in jsonEncoder.Encode", "err", err)
-			}
-			w.WriteHeader(http.StatusBadRequest)
-			return
+			return nil, "invalid limit"
 		}
 		limit = int(limitI64)
 	}
 
-	raw_results := dictmgr.LookupHTML(query, conf, mode, flags, limit)
-	// pass resultFlags to LookupHTML
+	envelope, ok := boolParam(r, "envelope")
+	if !ok {
+		return nil, "invalid envelope"
+	}
+
+	grouped, ok := boolParam(r, "grouped")
+	if !ok {
+		return nil, "invalid grouped"
+	}
+
+	detectLang := conf.DetectQueryLang
+	if r.FormValue("detect_lang") != "" {
+		detectLang, ok = boolParam(r, "detect_lang")
+		if !ok {
+			return nil, "invalid detect_lang"
+		}
+	}
+
+	collapse := conf.CollapseDuplicates
+	if r.FormValue("collapse") != "" {
+		collapse, ok = boolParam(r, "collapse")
+		if !ok {
+			return nil, "invalid collapse"
+		}
+	}
+
+	plan := dictmgr.ParseQuery(query, mode, detectLang)
+	plan.CollapseDuplicates = collapse
+
+	return &queryParams{
+		query:    query,
+		plan:     plan,
+		group:    group,
+		flags:    flags,
+		limit:    limit,
+		envelope: envelope,
+		grouped:  grouped,
+	}, ""
+}
+
+func newResults(raw_results []common.SearchResultIface) ([]Result, error) {
 	results := make([]Result, len(raw_results))
 	for i, res := range raw_results {
 		header, err := headerlib.GetHeader(headerTpl, res)
 		if err != nil {
-			logger.Error("Error formatting header label", "err", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return nil, err
 		}
 		results[i] = Result{
 			DictName:        res.DictName(),
@@ -158,11 +263,105 @@ func api_query(w http.ResponseWriter, r *http.Request) {
 			EntryIndex:      res.EntryIndex(),
 			Score:           res.Score(),
 			HeaderHTML:      header,
+			Via:             dictmgr.ResultVia(res),
+			AlsoIn:          dictmgr.ResultAlsoIn(res),
 		}
 		// entry.ResourceDir()
 	}
+	return results, nil
+}
+
+func newQueryResponse(
+	lookupResult *dictmgr.LookupResult,
+	grouped bool,
+) (*QueryResponse, error) {
+	response := &QueryResponse{
+		Suggestions:   lookupResult.Suggestions,
+		RemappedQuery: lookupResult.RemappedQuery,
+		TimedOut:      lookupResult.TimedOut,
+		Direction:     lookupResult.Direction,
+		Notice:        lookupResult.Notice,
+	}
+	if !grouped {
+		results, err := newResults(lookupResult.Results)
+		if err != nil {
+			return nil, err
+		}
+		response.Results = results
+		return response, nil
+	}
+	groups := dictmgr.GroupResults(lookupResult.Results, lookupResult.DictOrder, conf)
+	response.Groups = make([]ResultGroup, len(groups))
+	for i, group := range groups {
+		results, err := newResults(group.Results)
+		if err != nil {
+			return nil, err
+		}
+		response.Groups[i] = ResultGroup{
+			Term:    group.Term,
+			Score:   group.Score(),
+			Results: results,
+		}
+	}
+	return response, nil
+}
+
+func api_query(w http.ResponseWriter, r *http.Request) {
+	t := time.Now()
+
+	jsonEncoder := json.NewEncoder(w)
+	w.Header().Set("Content-Type", "application/json")
+
+	params, errMsg := parseQueryParams(r)
+	if params == nil {
+		writeBadRequest(w, errMsg)
+		return
+	}
+	query := params.query
+
+	lookupResult, err := dictmgr.LookupHTMLContext(
+		r.Context(),
+		params.plan,
+		conf,
+		params.group,
+		params.flags,
+		params.limit,
+	)
+	if err != nil {
+		// client has closed the connection (or sent another request)
+		logger.Debug("LookupHTML cancelled", "err", err, "query", query)
+		return
+	}
+	if len(lookupResult.TimedOut) > 0 {
+		w.Header().Set(header_timedOut, joinEscaped(lookupResult.TimedOut))
+	}
+	if len(lookupResult.Suggestions) > 0 {
+		w.Header().Set(header_suggestions, joinEscaped(lookupResult.Suggestions))
+	}
+	if lookupResult.RemappedQuery != "" {
+		w.Header().Set(header_remappedQuery, url.QueryEscape(lookupResult.RemappedQuery))
+	}
+	if lookupResult.Direction != "" {
+		w.Header().Set(header_direction, url.QueryEscape(lookupResult.Direction))
+	}
+	if lookupResult.Notice != "" {
+		w.Header().Set(header_notice, url.QueryEscape(lookupResult.Notice))
+	}
+	response, err := newQueryResponse(lookupResult, params.grouped)
+	if err != nil {
+		logger.Error("Error formatting header label", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 	logger.Info("LookupHTML running time", "dt", time.Since(t), "query", query)
-	err := jsonEncoder.Encode(results)
+	var responseData any = response.Results
+	if params.grouped {
+		responseData = response.Groups
+	}
+	if params.envelope {
+		responseData = response
+	}
+	err = jsonEncoder.Encode(responseData)
 	if err != nil {
 		logger.Error("error in jsonEncoder.Encode", "err", err)
 		err2 := jsonEncoder.Encode(ErrorResponse{Error: err.Error()})
@@ -174,11 +373,25 @@ func api_query(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func api_random(w http.ResponseWriter, _ *http.Request) {
+func api_random(w http.ResponseWriter, r *http.Request) {
 	jsonEncoder := json.NewEncoder(w)
 	w.Header().Set("Content-Type", "application/json")
 
-	entry := dictmgr.RandomEntry(conf, resultFlags)
+	group, ok := groupParam(r)
+	if !ok {
+		writeBadRequest(w, "invalid group")
+		return
+	}
+
+	entry := dictmgr.RandomEntry(conf, group, resultFlags)
+	if entry == nil {
+		w.WriteHeader(http.StatusNotFound)
+		err := jsonEncoder.Encode(ErrorResponse{Error: "no entry found"})
+		if err != nil {
+			logger.Error("error in jsonEncoder.Encode", "err", err)
+		}
+		return
+	}
 	err := jsonEncoder.Encode(Result{
 		DictName:        entry.DictName(),
 		Terms:           entry.Terms(),
@@ -199,11 +412,15 @@ func api_random(w http.ResponseWriter, _ *http.Request) {
 
 type homeTemplateParams struct {
 	Config *config.Config
+
+	// DictGroups are names of dictionary groups
+	DictGroups []string
 }
 
 func home(w http.ResponseWriter, _ *http.Request) {
 	err := homeTpl.Execute(w, homeTemplateParams{
-		Config: conf,
+		Config:     conf,
+		DictGroups: dictmgr.DictGroupNames(),
 	})
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -215,32 +432,35 @@ func dictRes(w http.ResponseWriter, r *http.Request) {
 	dictName := r.FormValue("dictName")
 	path := r.FormValue("path")
 	if dictName == "" {
-		writeMsg(w, "missing dictName")
-		w.WriteHeader(http.StatusBadRequest)
+		http.Error(w, "missing dictName", http.StatusBadRequest)
 		return
 	}
 	if path == "" {
-		writeMsg(w, "missing path")
-		w.WriteHeader(http.StatusBadRequest)
+		http.Error(w, "missing path", http.StatusBadRequest)
 		return
 	}
 	fpath, ok := dictmgr.DictResFile(dictName, path)
 	if !ok {
-		writeMsg(w, "file not found")
-		w.WriteHeader(http.StatusNotFound)
+		data, ok := dictmgr.DictResData(dictName, path)
+		if !ok {
+			http.Error(w, "file not found", http.StatusNotFound)
+			return
+		}
+		http.ServeContent(w, r, path, time.Now(), bytes.NewReader(data))
 		return
 	}
 	file, err := os.Open(fpath)
 	if err != nil {
-		writeMsg(w, "file not found")
-		w.WriteHeader(http.StatusNotFound)
+		http.Error(w, "file not found", http.StatusNotFound)
 		return
 	}
+	defer file.Close()
 	http.ServeContent(w, r, "", time.Now(), file)
 }
 
 func addWebHandlers() {
 	http.HandleFunc("/"+path_api_query, api_query)
+	http.HandleFunc("/"+path_api_query_stream, api_query_stream)
 	http.HandleFunc("/"+path_api_random, api_random)
 	http.HandleFunc("/", home)
 	http.HandleFunc(dictmgr.DictResPathBase, dictRes)
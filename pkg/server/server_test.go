@@ -0,0 +1,213 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr/dictmgrtest"
+	"github.com/ilius/is/v2"
+)
+
+func setupTestServer(t *testing.T) {
+	t.Helper()
+	prevConf := conf
+	t.Cleanup(func() {
+		conf = prevConf
+	})
+	conf = dictmgrtest.Config()
+	err := loadHeaderTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dictmgrtest.Install(
+		t,
+		dictmgrtest.New("d1", dictmgrtest.E("apples", "plural of apple")),
+		dictmgrtest.New("d2", dictmgrtest.E("apple", "a fruit")),
+	)
+	dictmgrtest.InstallGroup("g1", "d1")
+}
+
+func TestApiQuery(t *testing.T) {
+	is := is.New(t)
+	setupTestServer(t)
+	{
+		w := httptest.NewRecorder()
+		api_query(w, httptest.NewRequest("GET", "/api/query?query=apple&mode=startWith", nil))
+		is.Equal(w.Code, http.StatusOK)
+		results := []Result{}
+		is.NotErr(json.Unmarshal(w.Body.Bytes(), &results))
+		is.Equal(len(results), 2)
+		is.Equal(results[0].DictName, "d2")
+		is.Equal(results[0].Terms, []string{"apple"})
+		is.Equal(results[0].DefinitionsHTML, []string{"a fruit<br/>\n"})
+		is.Equal(results[1].DictName, "d1")
+	}
+	{
+		w := httptest.NewRecorder()
+		api_query(w, httptest.NewRequest("GET", "/api/query?query=apple&mode=startWith&group=g1&envelope=1", nil))
+		is.Equal(w.Code, http.StatusOK)
+		response := QueryResponse{}
+		is.NotErr(json.Unmarshal(w.Body.Bytes(), &response))
+		is.Equal(len(response.Results), 1)
+		is.Equal(response.Results[0].DictName, "d1")
+	}
+	for _, params := range []string{
+		"",
+		"query=apple&mode=foo",
+		"query=apple&group=foo",
+		"query=apple&limit=x",
+		// definition index is disabled
+		"query=apple&mode=definition",
+	} {
+		w := httptest.NewRecorder()
+		api_query(w, httptest.NewRequest("GET", "/api/query?"+params, nil))
+		is.Msg(params).Equal(w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestApiQueryCollapse(t *testing.T) {
+	is := is.New(t)
+	setupTestServer(t)
+	dictmgrtest.Install(
+		t,
+		dictmgrtest.New("d1", dictmgrtest.E("apple", "a fruit")),
+		dictmgrtest.New("d2", dictmgrtest.E("apple", "a fruit")),
+	)
+	for params, count := range map[string]int{
+		// duplicates are collapsed by default
+		"":            1,
+		"&collapse=1": 1,
+		"&collapse=0": 2,
+	} {
+		w := httptest.NewRecorder()
+		api_query(w, httptest.NewRequest("GET", "/api/query?query=apple"+params, nil))
+		is.Msg(params).Equal(w.Code, http.StatusOK)
+		results := []Result{}
+		is.NotErr(json.Unmarshal(w.Body.Bytes(), &results))
+		is.Msg(params).Equal(len(results), count)
+		if count == 1 {
+			is.Equal(results[0].DictName, "d1")
+			is.Equal(results[0].AlsoIn, []string{"d2"})
+		}
+	}
+}
+
+func TestApiRandom(t *testing.T) {
+	is := is.New(t)
+	setupTestServer(t)
+	{
+		w := httptest.NewRecorder()
+		api_random(w, httptest.NewRequest("GET", "/api/random?group=g1", nil))
+		is.Equal(w.Code, http.StatusOK)
+		result := Result{}
+		is.NotErr(json.Unmarshal(w.Body.Bytes(), &result))
+		is.Equal(result.DictName, "d1")
+		is.Equal(result.Terms, []string{"apples"})
+	}
+	{
+		w := httptest.NewRecorder()
+		api_random(w, httptest.NewRequest("GET", "/api/random?group=foo", nil))
+		is.Equal(w.Code, http.StatusBadRequest)
+	}
+	dictmgrtest.Install(t)
+	{
+		w := httptest.NewRecorder()
+		api_random(w, httptest.NewRequest("GET", "/api/random", nil))
+		is.Equal(w.Code, http.StatusNotFound)
+	}
+}
+
+func TestApiQueryStream(t *testing.T) {
+	is := is.New(t)
+	setupTestServer(t)
+	{
+		w := httptest.NewRecorder()
+		api_query_stream(w, httptest.NewRequest("GET", "/api/query/stream?query=apple&mode=startWith", nil))
+		is.Equal(w.Code, http.StatusOK)
+		is.Equal(w.Header().Get("Content-Type"), contentType_ndjson)
+		lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+		is.Equal(len(lines), 3)
+		dictNames := []string{}
+		for _, line := range lines[:2] {
+			msg := StreamMessage{}
+			is.NotErr(json.Unmarshal([]byte(line), &msg))
+			is.True(msg.Final == nil)
+			is.Equal(len(msg.Results), 1)
+			dictNames = append(dictNames, msg.DictName)
+		}
+		slices.Sort(dictNames)
+		is.Equal(dictNames, []string{"d1", "d2"})
+		msg := StreamMessage{}
+		is.NotErr(json.Unmarshal([]byte(lines[2]), &msg))
+		is.True(msg.Final != nil)
+		is.Equal(len(msg.Final.Results), 2)
+		is.Equal(msg.Final.Results[0].DictName, "d2")
+		is.Equal(msg.Final.Results[0].Terms, []string{"apple"})
+		is.Equal(msg.Final.Results[1].DictName, "d1")
+	}
+	{
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/query/stream?query=apple&mode=startWith&group=g1", nil)
+		r.Header.Set("Accept", contentType_eventStream)
+		api_query_stream(w, r)
+		is.Equal(w.Code, http.StatusOK)
+		is.Equal(w.Header().Get("Content-Type"), contentType_eventStream)
+		events := strings.Split(strings.TrimSuffix(w.Body.String(), "\n\n"), "\n\n")
+		is.Equal(len(events), 2)
+		is.True(strings.HasPrefix(events[0], "event: dict\ndata: "))
+		data, ok := strings.CutPrefix(events[1], "event: end\ndata: ")
+		is.True(ok)
+		msg := StreamMessage{}
+		is.NotErr(json.Unmarshal([]byte(data), &msg))
+		is.Equal(len(msg.Final.Results), 1)
+		is.Equal(msg.Final.Results[0].DictName, "d1")
+	}
+	{
+		w := httptest.NewRecorder()
+		api_query_stream(w, httptest.NewRequest("GET", "/api/query/stream?query=xyz&mode=startWith", nil))
+		is.Equal(w.Code, http.StatusOK)
+		msg := StreamMessage{}
+		is.NotErr(json.Unmarshal(w.Body.Bytes(), &msg))
+		is.True(msg.Final != nil)
+		is.Equal(len(msg.Final.Results), 0)
+	}
+	{
+		w := httptest.NewRecorder()
+		api_query_stream(w, httptest.NewRequest("GET", "/api/query/stream?query=apple&mode=foo", nil))
+		is.Equal(w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHome(t *testing.T) {
+	is := is.New(t)
+	setupTestServer(t)
+	is.NotErr(loadIndexTemplate())
+	for _, maxResults := range []int{0, 40} {
+		conf.MaxResultsTotal = maxResults
+		w := httptest.NewRecorder()
+		home(w, httptest.NewRequest("GET", "/", nil))
+		is.Equal(w.Code, http.StatusOK)
+		body := w.Body.String()
+		is.Equal(strings.Contains(body, "del results["), maxResults > 0)
+		is.Equal(strings.Contains(body, "del results[40:]"), maxResults == 40)
+	}
+}
+
+func TestDictRes(t *testing.T) {
+	is := is.New(t)
+	setupTestServer(t)
+	for params, code := range map[string]int{
+		"path=a.png":             http.StatusBadRequest,
+		"dictName=d1":            http.StatusBadRequest,
+		"dictName=d1&path=a.png": http.StatusNotFound,
+		"dictName=xx&path=a.png": http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		dictRes(w, httptest.NewRequest("GET", "/dict-res?"+params, nil))
+		is.Msg(params).Equal(w.Code, code)
+	}
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ilius/ayandict/v2/pkg/dictmgr"
+)
+
+const (
+	contentType_ndjson      = "application/x-ndjson"
+	contentType_eventStream = "text/event-stream"
+)
+
+// StreamMessage is sent by api/query/stream for each dictionary
+// as soon as it is finished searching, and then once with Final,
+// which is the same as response of api/query with envelope=1
+type StreamMessage struct {
+	DictName string         `json:"dictName,omitempty"`
+	Results  []Result       `json:"results,omitempty"`
+	Final    *QueryResponse `json:"final,omitempty"`
+}
+
+// streamWriter writes messages as newline-delimited JSON (NDJSON)
+// or as Server-Sent Events (SSE)
+type streamWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	sse     bool
+}
+
+func (sw *streamWriter) write(event string, data []byte) error {
+	var err error
+	if sw.sse {
+		_, err = sw.w.Write([]byte("event: " + event + "\ndata: "))
+		if err != nil {
+			return err
+		}
+		_, err = sw.w.Write(data)
+		if err != nil {
+			return err
+		}
+		_, err = sw.w.Write([]byte("\n\n"))
+	} else {
+		_, err = sw.w.Write(append(data, '\n'))
+	}
+	if err != nil {
+		return err
+	}
+	if sw.flusher != nil {
+		sw.flusher.Flush()
+	}
+	return nil
+}
+
+func wantsEventStream(r *http.Request) bool {
+	switch r.FormValue("format") {
+	case "sse":
+		return true
+	case "ndjson":
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), contentType_eventStream)
+}
+
+// api_query_stream is like api_query, but sends results of each dictionary
+// as soon as it is finished, so they can be shown before all dictionaries
+// are finished, and then sends the same response as api_query
+func api_query_stream(w http.ResponseWriter, r *http.Request) {
+	t := time.Now()
+
+	params, errMsg := parseQueryParams(r)
+	if params == nil {
+		w.Header().Set("Content-Type", "application/json")
+		writeBadRequest(w, errMsg)
+		return
+	}
+	query := params.query
+
+	sw := &streamWriter{
+		w:   w,
+		sse: wantsEventStream(r),
+	}
+	sw.flusher, _ = w.(http.Flusher)
+	if sw.sse {
+		w.Header().Set("Content-Type", contentType_eventStream)
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", contentType_ndjson)
+	}
+
+	ch := dictmgr.LookupHTMLStream(
+		r.Context(),
+		params.plan,
+		conf,
+		params.group,
+		params.flags,
+		params.limit,
+	)
+	for event := range ch {
+		msg, err := newStreamMessage(event, params.grouped)
+		if err != nil {
+			logger.Error("Error formatting header label", "err", err)
+			continue
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			logger.Error("error in json.Marshal", "err", err)
+			continue
+		}
+		name := "dict"
+		if msg.Final != nil {
+			name = "end"
+		}
+		err = sw.write(name, data)
+		if err != nil {
+			// client has closed the connection
+			logger.Debug("error writing to stream", "err", err, "query", query)
+			return
+		}
+	}
+	if r.Context().Err() != nil {
+		logger.Debug("LookupHTMLStream cancelled", "query", query)
+		return
+	}
+	logger.Info("LookupHTMLStream running time", "dt", time.Since(t), "query", query)
+}
+
+func newStreamMessage(event *dictmgr.StreamEvent, grouped bool) (*StreamMessage, error) {
+	if event.Final != nil {
+		response, err := newQueryResponse(event.Final, grouped)
+		if err != nil {
+			return nil, err
+		}
+		return &StreamMessage{Final: response}, nil
+	}
+	results, err := newResults(event.Dict.Results)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamMessage{
+		DictName: event.Dict.DictName,
+		Results:  results,
+	}, nil
+}